@@ -0,0 +1,174 @@
+package codecs
+
+import (
+	"log/slog"
+)
+
+// AV1PayloadDescriptor holds the layers and the key frame status of an AV1
+// packet, as read from its dependency descriptor header extension.
+type AV1PayloadDescriptor struct {
+	StartOfFrame  bool
+	EndOfFrame    bool
+	SpatialLayer  uint8
+	TemporalLayer uint8
+
+	// Parsed values.
+	IsKeyFrame bool
+
+	DependencyDescriptor *DependencyDescriptor
+}
+
+// ParseAV1 builds the payload descriptor of an AV1 packet from its dependency
+// descriptor. It returns nil if no dependency descriptor is given.
+func ParseAV1(dependencyDescriptor *DependencyDescriptor) *AV1PayloadDescriptor {
+	if dependencyDescriptor == nil {
+		return nil
+	}
+
+	return &AV1PayloadDescriptor{
+		StartOfFrame:  dependencyDescriptor.StartOfFrame,
+		EndOfFrame:    dependencyDescriptor.EndOfFrame,
+		SpatialLayer:  dependencyDescriptor.SpatialId,
+		TemporalLayer: dependencyDescriptor.TemporalId,
+		// The template dependency structure is sent along with key frames.
+		IsKeyFrame:           dependencyDescriptor.TemplateDependencyStructurePresent,
+		DependencyDescriptor: dependencyDescriptor,
+	}
+}
+
+func (p *AV1PayloadDescriptor) Dump() {
+	slog.Debug("AV1PayloadDescriptor",
+		"startOfFrame", p.StartOfFrame,
+		"endOfFrame", p.EndOfFrame,
+		"spatialLayer", p.SpatialLayer,
+		"temporalLayer", p.TemporalLayer,
+		"isKeyFrame", p.IsKeyFrame,
+	)
+}
+
+// AV1PayloadDescriptorHandler decides whether AV1 packets are forwarded
+// according to the spatial and temporal layers of the encoding context.
+type AV1PayloadDescriptorHandler struct {
+	payloadDescriptor *AV1PayloadDescriptor
+}
+
+func NewAV1PayloadDescriptorHandler(payloadDescriptor *AV1PayloadDescriptor) *AV1PayloadDescriptorHandler {
+	return &AV1PayloadDescriptorHandler{
+		payloadDescriptor: payloadDescriptor,
+	}
+}
+
+func (h *AV1PayloadDescriptorHandler) Dump() {
+	h.payloadDescriptor.Dump()
+}
+
+// Process switches the current layers of the context towards the target ones
+// when the packet allows it, and returns ok as false if the packet must be
+// dropped. The marker is set on the last packet of the highest forwarded
+// spatial layer.
+func (h *AV1PayloadDescriptorHandler) Process(context *EncodingContext, data []byte) (marker, ok bool) {
+	p := h.payloadDescriptor
+
+	packetSpatialLayer := int16(p.SpatialLayer)
+	packetTemporalLayer := int16(p.TemporalLayer)
+	tmpSpatialLayer := context.GetCurrentSpatialLayer()
+	tmpTemporalLayer := context.GetCurrentTemporalLayer()
+
+	// If packet spatial or temporal layer is higher than maximum announced one,
+	// drop the packet.
+	if packetSpatialLayer >= int16(context.GetSpatialLayers()) ||
+		packetTemporalLayer >= int16(context.GetTemporalLayers()) {
+		slog.Warn("too high packet layers",
+			"spatialLayer", packetSpatialLayer, "temporalLayer", packetTemporalLayer)
+		return false, false
+	}
+
+	if context.GetTargetSpatialLayer() > context.GetCurrentSpatialLayer() {
+		// Upgrade current spatial layer if needed.
+		if p.IsKeyFrame {
+			tmpSpatialLayer = context.GetTargetSpatialLayer()
+			tmpTemporalLayer = 0 // Just in case.
+		}
+	} else if context.GetTargetSpatialLayer() < context.GetCurrentSpatialLayer() {
+		// Downgrade current spatial layer if needed.
+		if context.IsKSvc() {
+			// In K-SVC we must wait for a keyframe.
+			if p.IsKeyFrame {
+				tmpSpatialLayer = context.GetTargetSpatialLayer()
+				tmpTemporalLayer = 0 // Just in case.
+			}
+		} else if packetSpatialLayer == context.GetTargetSpatialLayer() && p.EndOfFrame {
+			// In full SVC we do not need a keyframe.
+			tmpSpatialLayer = context.GetTargetSpatialLayer()
+			tmpTemporalLayer = 0 // Just in case.
+		}
+	}
+
+	// Filter spatial layers higher than current one.
+	if packetSpatialLayer > tmpSpatialLayer {
+		return false, false
+	}
+
+	if context.GetTargetTemporalLayer() > context.GetCurrentTemporalLayer() {
+		// Upgrade current temporal layer if needed.
+		if packetTemporalLayer >= context.GetCurrentTemporalLayer()+1 &&
+			(context.GetCurrentTemporalLayer() == -1 || h.isSwitch()) {
+			tmpTemporalLayer = packetTemporalLayer
+		}
+	} else if context.GetTargetTemporalLayer() < context.GetCurrentTemporalLayer() {
+		// Downgrade current temporal layer if needed.
+		if packetTemporalLayer == context.GetTargetTemporalLayer() && p.EndOfFrame {
+			tmpTemporalLayer = context.GetTargetTemporalLayer()
+		}
+	}
+
+	// Filter temporal layers higher than current one.
+	if packetTemporalLayer > tmpTemporalLayer {
+		return false, false
+	}
+
+	// Set marker bit if needed.
+	if packetSpatialLayer == tmpSpatialLayer && p.EndOfFrame {
+		marker = true
+	}
+
+	// Update current layers if needed.
+	if tmpSpatialLayer != context.GetCurrentSpatialLayer() {
+		context.SetCurrentSpatialLayer(tmpSpatialLayer)
+	}
+	if tmpTemporalLayer != context.GetCurrentTemporalLayer() {
+		context.SetCurrentTemporalLayer(tmpTemporalLayer)
+	}
+
+	return marker, true
+}
+
+// isSwitch tells whether the frame is a switch point for some decode target,
+// so upper temporal layers can be forwarded from it on.
+// Frames without decode target indications are considered switch points.
+func (h *AV1PayloadDescriptorHandler) isSwitch() bool {
+	dd := h.payloadDescriptor.DependencyDescriptor
+	if len(dd.Dtis) == 0 {
+		return true
+	}
+	for _, dti := range dd.Dtis {
+		if dti == DecodeTargetIndicationSwitch {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *AV1PayloadDescriptorHandler) Restore(data []byte) {}
+
+func (h *AV1PayloadDescriptorHandler) GetSpatialLayer() uint8 {
+	return h.payloadDescriptor.SpatialLayer
+}
+
+func (h *AV1PayloadDescriptorHandler) GetTemporalLayer() uint8 {
+	return h.payloadDescriptor.TemporalLayer
+}
+
+func (h *AV1PayloadDescriptorHandler) IsKeyFrame() bool {
+	return h.payloadDescriptor.IsKeyFrame
+}
@@ -0,0 +1,105 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+func processAV1(t *testing.T, context *codecs.EncodingContext, parser *codecs.DependencyDescriptorParser, descriptor testDependencyDescriptor) (marker, ok bool) {
+	dd, err := parser.Parse(descriptor.data())
+	require.NoError(t, err)
+
+	packet := TestPacket{payload: []byte{0x10, 0xaa}, dependencyDescriptor: dd}
+	handler := codecs.GetPayloadDescriptorHandler("video/AV1", packet)
+	require.NotNil(t, handler)
+	return handler.Process(context, packet.payload)
+}
+
+func TestAV1(t *testing.T) {
+	t.Run("requires a dependency descriptor", func(t *testing.T) {
+		require.True(t, codecs.CanBeKeyFrame("video/AV1"))
+		require.Nil(t, codecs.GetPayloadDescriptorHandler("video/AV1", TestPacket{payload: []byte{0x10, 0xaa}}))
+	})
+
+	t.Run("switches temporal layers on switch frames", func(t *testing.T) {
+		parser := codecs.NewDependencyDescriptorParser()
+		context := codecs.NewEncodingContext(codecs.EncodingContextParams{SpatialLayers: 1, TemporalLayers: 2})
+		context.SetTargetSpatialLayer(0)
+		context.SetTargetTemporalLayer(1)
+
+		// Key frame.
+		dd, err := parser.Parse(testDependencyDescriptor{startOfFrame: true, endOfFrame: true, structure: &l1t2Structure}.data())
+		require.NoError(t, err)
+		handler := codecs.GetPayloadDescriptorHandler("video/AV1", TestPacket{dependencyDescriptor: dd})
+		require.True(t, handler.IsKeyFrame())
+
+		marker, ok := handler.Process(context, nil)
+		require.True(t, ok)
+		require.True(t, marker)
+		require.EqualValues(t, 0, context.GetCurrentSpatialLayer())
+		require.EqualValues(t, 0, context.GetCurrentTemporalLayer())
+
+		// Temporal layer 1 frame, a switch point of the second decode target.
+		marker, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, templateId: 2, frameNumber: 2})
+		require.True(t, ok)
+		require.False(t, marker)
+		require.EqualValues(t, 1, context.GetCurrentTemporalLayer())
+
+		// Downgrade on the end of a temporal layer 0 frame.
+		context.SetTargetTemporalLayer(0)
+
+		_, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, templateId: 1, frameNumber: 3})
+		require.True(t, ok)
+		require.EqualValues(t, 1, context.GetCurrentTemporalLayer())
+
+		_, ok = processAV1(t, context, parser, testDependencyDescriptor{endOfFrame: true, templateId: 1, frameNumber: 3})
+		require.True(t, ok)
+		require.EqualValues(t, 0, context.GetCurrentTemporalLayer())
+
+		_, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, templateId: 2, frameNumber: 4})
+		require.False(t, ok)
+	})
+
+	t.Run("switches spatial layers up on key frames", func(t *testing.T) {
+		parser := codecs.NewDependencyDescriptorParser()
+		context := codecs.NewEncodingContext(codecs.EncodingContextParams{SpatialLayers: 2, TemporalLayers: 1})
+		context.SetTargetSpatialLayer(0)
+		context.SetTargetTemporalLayer(0)
+
+		marker, ok := processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, structure: &l2t1Structure})
+		require.True(t, ok)
+		require.True(t, marker)
+
+		_, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, templateId: 2})
+		require.False(t, ok)
+
+		// No upgrade until the next key frame.
+		context.SetTargetSpatialLayer(1)
+
+		_, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, templateId: 3, frameNumber: 1})
+		require.False(t, ok)
+		require.EqualValues(t, 0, context.GetCurrentSpatialLayer())
+
+		marker, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, frameNumber: 2, structure: &l2t1Structure})
+		require.True(t, ok)
+		require.False(t, marker)
+		require.EqualValues(t, 1, context.GetCurrentSpatialLayer())
+
+		marker, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, templateId: 2, frameNumber: 2})
+		require.True(t, ok)
+		require.True(t, marker)
+
+		// Full SVC downgrade on the end of a lower spatial layer frame.
+		context.SetTargetSpatialLayer(0)
+
+		marker, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, templateId: 1, frameNumber: 3})
+		require.True(t, ok)
+		require.True(t, marker)
+		require.EqualValues(t, 0, context.GetCurrentSpatialLayer())
+
+		_, ok = processAV1(t, context, parser, testDependencyDescriptor{startOfFrame: true, endOfFrame: true, templateId: 3, frameNumber: 3})
+		require.False(t, ok)
+	})
+}
@@ -1,5 +1,11 @@
 package codecs
 
+import (
+	"strings"
+
+	"golang.org/x/exp/constraints"
+)
+
 type PayloadDescriptor interface {
 	Dump()
 }
@@ -17,16 +23,47 @@ type EncodingContext struct {
 	currentSpatialLayer  int16
 	currentTemporalLayer int16
 	ignoreDtx            bool
+	syncRequired         bool
+
+	pictureIdManager       SeqManager[uint16]
+	tl0PictureIndexManager SeqManager[uint8]
 }
 
-func NewEncodingContext(params EncodingContextParams) *EncodingContext {
-	return &EncodingContext{
+// SeqManager keeps the values rewritten in payload descriptors continuous when
+// some of them are dropped.
+type SeqManager[T constraints.Unsigned] interface {
+	Sync(input T)
+	Drop(input T)
+	Input(input T) (output T, ok bool)
+	GetMaxInput() T
+}
+
+// WithPictureIdManager sets the manager of the 15 bits picture ids.
+func WithPictureIdManager(manager SeqManager[uint16]) func(*EncodingContext) {
+	return func(ec *EncodingContext) {
+		ec.pictureIdManager = manager
+	}
+}
+
+// WithTl0PictureIndexManager sets the manager of the TL0PICIDX values.
+func WithTl0PictureIndexManager(manager SeqManager[uint8]) func(*EncodingContext) {
+	return func(ec *EncodingContext) {
+		ec.tl0PictureIndexManager = manager
+	}
+}
+
+func NewEncodingContext(params EncodingContextParams, options ...func(*EncodingContext)) *EncodingContext {
+	ec := &EncodingContext{
 		params:               params,
 		targetSpatialLayer:   -1,
 		targetTemporalLayer:  -1,
 		currentSpatialLayer:  -1,
 		currentTemporalLayer: -1,
 	}
+	for _, o := range options {
+		o(ec)
+	}
+	return ec
 }
 
 func (ec *EncodingContext) GetSpatialLayers() uint8 {
@@ -81,6 +118,12 @@ func (ec *EncodingContext) SetIgnoreDtx(ignore bool) {
 	ec.ignoreDtx = ignore
 }
 
+// SyncRequired tells the context that the stream is being synced again, so
+// rewritten payload descriptor values must be synced too.
+func (ec *EncodingContext) SyncRequired() {
+	ec.syncRequired = true
+}
+
 type PayloadDescriptorHandler interface {
 	Dump()
 	Process(context *EncodingContext, data []byte) (marker, ok bool)
@@ -89,3 +132,79 @@ type PayloadDescriptorHandler interface {
 	GetTemporalLayer() uint8
 	IsKeyFrame() bool
 }
+
+// CanBeKeyFrame tells whether key frames of the given codec can be detected by
+// a PayloadDescriptorHandler.
+func CanBeKeyFrame(mimeType string) bool {
+	switch strings.ToLower(mimeType) {
+	case "video/vp8", "video/vp9", "video/h264", "video/h265", "video/av1":
+		return true
+	default:
+		return false
+	}
+}
+
+// Packet gives access to the parts of a RTP packet needed to parse its payload
+// descriptor.
+type Packet interface {
+	GetPayload() []byte
+	// ReadFrameMarking returns the value of the frame marking header extension.
+	ReadFrameMarking() (frameMarking []byte, ok bool)
+	// ReadDependencyDescriptor returns the parsed value of the dependency
+	// descriptor header extension.
+	ReadDependencyDescriptor() (dependencyDescriptor *DependencyDescriptor, ok bool)
+}
+
+// GetPayloadDescriptorHandler parses the payload of the given packet. It
+// returns nil if the codec is not supported or the payload is invalid.
+func GetPayloadDescriptorHandler(mimeType string, packet Packet) PayloadDescriptorHandler {
+	switch strings.ToLower(mimeType) {
+	case "video/vp8":
+		if payloadDescriptor := ParseVP8(packet.GetPayload()); payloadDescriptor != nil {
+			return NewVP8PayloadDescriptorHandler(payloadDescriptor)
+		}
+	case "video/vp9":
+		if payloadDescriptor := ParseVP9(packet.GetPayload()); payloadDescriptor != nil {
+			return NewVP9PayloadDescriptorHandler(payloadDescriptor)
+		}
+	case "video/h264":
+		frameMarking, _ := packet.ReadFrameMarking()
+		if payloadDescriptor := ParseH264(packet.GetPayload(), frameMarking); payloadDescriptor != nil {
+			return NewH264PayloadDescriptorHandler(payloadDescriptor)
+		}
+	case "video/h265":
+		if payloadDescriptor := ParseH265(packet.GetPayload()); payloadDescriptor != nil {
+			return NewH265PayloadDescriptorHandler(payloadDescriptor)
+		}
+	case "video/av1":
+		dependencyDescriptor, _ := packet.ReadDependencyDescriptor()
+		if payloadDescriptor := ParseAV1(dependencyDescriptor); payloadDescriptor != nil {
+			return NewAV1PayloadDescriptorHandler(payloadDescriptor)
+		}
+	case "audio/opus", "audio/multiopus":
+		if payloadDescriptor := ParseOpus(packet.GetPayload()); payloadDescriptor != nil {
+			return NewOpusPayloadDescriptorHandler(payloadDescriptor)
+		}
+	}
+	return nil
+}
+
+// isSeqLowerThan compares sequence numbers of the given number of bits.
+func isSeqLowerThan[T constraints.Unsigned](lhs, rhs T, bits int) bool {
+	maxValue := T(1)<<bits - 1
+	lhs &= maxValue
+	rhs &= maxValue
+
+	return ((rhs > lhs) && (rhs-lhs <= maxValue/2)) ||
+		((lhs > rhs) && (lhs-rhs > maxValue/2))
+}
+
+// isSeqHigherThan compares sequence numbers of the given number of bits.
+func isSeqHigherThan[T constraints.Unsigned](lhs, rhs T, bits int) bool {
+	maxValue := T(1)<<bits - 1
+	lhs &= maxValue
+	rhs &= maxValue
+
+	return ((lhs > rhs) && (lhs-rhs <= maxValue/2)) ||
+		((rhs > lhs) && (rhs-lhs > maxValue/2))
+}
@@ -0,0 +1,21 @@
+package codecs_test
+
+import "github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+
+type TestPacket struct {
+	payload              []byte
+	frameMarking         []byte
+	dependencyDescriptor *codecs.DependencyDescriptor
+}
+
+func (p TestPacket) GetPayload() []byte {
+	return p.payload
+}
+
+func (p TestPacket) ReadFrameMarking() ([]byte, bool) {
+	return p.frameMarking, len(p.frameMarking) > 0
+}
+
+func (p TestPacket) ReadDependencyDescriptor() (*codecs.DependencyDescriptor, bool) {
+	return p.dependencyDescriptor, p.dependencyDescriptor != nil
+}
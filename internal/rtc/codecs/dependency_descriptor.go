@@ -0,0 +1,329 @@
+package codecs
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var (
+	ErrDependencyDescriptorTooShort         = errors.New("dependency descriptor too short")
+	ErrDependencyDescriptorNoStructure      = errors.New("dependency descriptor without template dependency structure")
+	ErrDependencyDescriptorInvalidTemplate  = errors.New("dependency descriptor with invalid frame dependency template id")
+	ErrDependencyDescriptorInvalidStructure = errors.New("invalid template dependency structure")
+)
+
+// Decode target indications.
+const (
+	DecodeTargetIndicationNotPresent  uint8 = 0
+	DecodeTargetIndicationDiscardable uint8 = 1
+	DecodeTargetIndicationSwitch      uint8 = 2
+	DecodeTargetIndicationRequired    uint8 = 3
+)
+
+// FrameDependencyTemplate is a template of the template dependency structure.
+type FrameDependencyTemplate struct {
+	SpatialId   uint8
+	TemporalId  uint8
+	Dtis        []uint8
+	Fdiffs      []uint16
+	ChainFdiffs []uint8
+}
+
+// RenderResolution is the resolution of a spatial layer.
+type RenderResolution struct {
+	Width  uint16
+	Height uint16
+}
+
+// TemplateDependencyStructure is the structure sent in key frames and
+// referenced by the following dependency descriptors.
+type TemplateDependencyStructure struct {
+	TemplateIdOffset        uint8
+	DecodeTargetCount       uint8
+	ChainCount              uint8
+	Templates               []FrameDependencyTemplate
+	DecodeTargetProtectedBy []uint8
+	DecodeTargetSpatialIds  []uint8
+	DecodeTargetTemporalIds []uint8
+	MaxSpatialId            uint8
+	MaxTemporalId           uint8
+	RenderResolutions       []RenderResolution
+}
+
+// DependencyDescriptor is the RTP header extension defined in the appendix A of
+// the AV1 RTP specification.
+type DependencyDescriptor struct {
+	StartOfFrame               bool
+	EndOfFrame                 bool
+	FrameDependencyTemplateId  uint8
+	FrameNumber                uint16
+	SpatialId                  uint8
+	TemporalId                 uint8
+	Dtis                       []uint8
+	Fdiffs                     []uint16
+	ChainFdiffs                []uint8
+	ActiveDecodeTargetsBitmask uint32
+
+	// TemplateDependencyStructurePresent is set if the descriptor carries a new
+	// template dependency structure, which happens in key frames.
+	TemplateDependencyStructurePresent bool
+}
+
+// DependencyDescriptorParser parses the dependency descriptors of a stream,
+// keeping the last received template dependency structure.
+type DependencyDescriptorParser struct {
+	structure                  *TemplateDependencyStructure
+	activeDecodeTargetsBitmask uint32
+}
+
+func NewDependencyDescriptorParser() *DependencyDescriptorParser {
+	return &DependencyDescriptorParser{}
+}
+
+// GetTemplateDependencyStructure returns the last received template
+// dependency structure, or nil if none.
+func (p *DependencyDescriptorParser) GetTemplateDependencyStructure() *TemplateDependencyStructure {
+	return p.structure
+}
+
+// Parse parses the given dependency descriptor header extension value.
+func (p *DependencyDescriptorParser) Parse(data []byte) (*DependencyDescriptor, error) {
+	if len(data) < 3 {
+		return nil, ErrDependencyDescriptorTooShort
+	}
+
+	r := &bitReader{data: data}
+	dd := &DependencyDescriptor{}
+
+	// Mandatory descriptor fields.
+	dd.StartOfFrame = r.readBits(1) == 1
+	dd.EndOfFrame = r.readBits(1) == 1
+	dd.FrameDependencyTemplateId = uint8(r.readBits(6))
+	dd.FrameNumber = uint16(r.readBits(16))
+
+	var (
+		activeDecodeTargetsPresent bool
+		customDtis                 bool
+		customFdiffs               bool
+		customChains               bool
+	)
+
+	structure := p.structure
+
+	// Extended descriptor fields.
+	if len(data) > 3 {
+		dd.TemplateDependencyStructurePresent = r.readBits(1) == 1
+		activeDecodeTargetsPresent = r.readBits(1) == 1
+		customDtis = r.readBits(1) == 1
+		customFdiffs = r.readBits(1) == 1
+		customChains = r.readBits(1) == 1
+
+		if dd.TemplateDependencyStructurePresent {
+			var err error
+			if structure, err = readTemplateDependencyStructure(r); err != nil {
+				return nil, err
+			}
+			p.activeDecodeTargetsBitmask = 1<<structure.DecodeTargetCount - 1
+		}
+	}
+
+	if structure == nil {
+		return nil, ErrDependencyDescriptorNoStructure
+	}
+
+	if activeDecodeTargetsPresent {
+		p.activeDecodeTargetsBitmask = r.readBits(int(structure.DecodeTargetCount))
+	}
+
+	// Frame dependency definition.
+	templateIndex := (int(dd.FrameDependencyTemplateId) + 64 - int(structure.TemplateIdOffset)) % 64
+	if templateIndex >= len(structure.Templates) {
+		return nil, ErrDependencyDescriptorInvalidTemplate
+	}
+
+	template := structure.Templates[templateIndex]
+	dd.SpatialId = template.SpatialId
+	dd.TemporalId = template.TemporalId
+
+	if customDtis {
+		dd.Dtis = make([]uint8, structure.DecodeTargetCount)
+		for i := range dd.Dtis {
+			dd.Dtis[i] = uint8(r.readBits(2))
+		}
+	} else {
+		dd.Dtis = template.Dtis
+	}
+
+	if customFdiffs {
+		for nextFdiffSize := r.readBits(2); nextFdiffSize > 0; nextFdiffSize = r.readBits(2) {
+			dd.Fdiffs = append(dd.Fdiffs, uint16(r.readBits(4*int(nextFdiffSize))+1))
+		}
+	} else {
+		dd.Fdiffs = template.Fdiffs
+	}
+
+	if customChains {
+		dd.ChainFdiffs = make([]uint8, structure.ChainCount)
+		for i := range dd.ChainFdiffs {
+			dd.ChainFdiffs[i] = uint8(r.readBits(8))
+		}
+	} else {
+		dd.ChainFdiffs = template.ChainFdiffs
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// Keep the new structure once the whole descriptor has been read.
+	p.structure = structure
+	dd.ActiveDecodeTargetsBitmask = p.activeDecodeTargetsBitmask
+
+	return dd, nil
+}
+
+func readTemplateDependencyStructure(r *bitReader) (*TemplateDependencyStructure, error) {
+	s := &TemplateDependencyStructure{}
+
+	s.TemplateIdOffset = uint8(r.readBits(6))
+	s.DecodeTargetCount = uint8(r.readBits(5)) + 1
+
+	// Template layers.
+	var spatialId, temporalId uint8
+	for {
+		if len(s.Templates) == 64 {
+			return nil, ErrDependencyDescriptorInvalidStructure
+		}
+
+		s.Templates = append(s.Templates, FrameDependencyTemplate{
+			SpatialId:  spatialId,
+			TemporalId: temporalId,
+		})
+
+		nextLayerIdc := r.readBits(2)
+		if nextLayerIdc == 3 || r.err != nil {
+			break
+		}
+
+		switch nextLayerIdc {
+		case 1:
+			temporalId++
+			s.MaxTemporalId = max(s.MaxTemporalId, temporalId)
+		case 2:
+			temporalId = 0
+			spatialId++
+		}
+	}
+	s.MaxSpatialId = spatialId
+
+	// Template decode target indications.
+	for i := range s.Templates {
+		s.Templates[i].Dtis = make([]uint8, s.DecodeTargetCount)
+		for dt := range s.Templates[i].Dtis {
+			s.Templates[i].Dtis[dt] = uint8(r.readBits(2))
+		}
+	}
+
+	// Template frame diffs.
+	for i := range s.Templates {
+		for r.readBits(1) == 1 {
+			s.Templates[i].Fdiffs = append(s.Templates[i].Fdiffs, uint16(r.readBits(4))+1)
+		}
+	}
+
+	// Template chains.
+	s.ChainCount = uint8(r.readNonSymmetric(uint32(s.DecodeTargetCount) + 1))
+	if s.ChainCount > 0 {
+		s.DecodeTargetProtectedBy = make([]uint8, s.DecodeTargetCount)
+		for dt := range s.DecodeTargetProtectedBy {
+			s.DecodeTargetProtectedBy[dt] = uint8(r.readNonSymmetric(uint32(s.ChainCount)))
+		}
+		for i := range s.Templates {
+			s.Templates[i].ChainFdiffs = make([]uint8, s.ChainCount)
+			for c := range s.Templates[i].ChainFdiffs {
+				s.Templates[i].ChainFdiffs[c] = uint8(r.readBits(4))
+			}
+		}
+	}
+
+	// Decode target layers, the highest layers of the templates which are
+	// part of each decode target.
+	s.DecodeTargetSpatialIds = make([]uint8, s.DecodeTargetCount)
+	s.DecodeTargetTemporalIds = make([]uint8, s.DecodeTargetCount)
+	for dt := 0; dt < int(s.DecodeTargetCount); dt++ {
+		for _, template := range s.Templates {
+			if template.Dtis[dt] != DecodeTargetIndicationNotPresent {
+				s.DecodeTargetSpatialIds[dt] = max(s.DecodeTargetSpatialIds[dt], template.SpatialId)
+				s.DecodeTargetTemporalIds[dt] = max(s.DecodeTargetTemporalIds[dt], template.TemporalId)
+			}
+		}
+	}
+
+	// Render resolutions.
+	if r.readBits(1) == 1 {
+		for i := 0; i <= int(s.MaxSpatialId); i++ {
+			s.RenderResolutions = append(s.RenderResolutions, RenderResolution{
+				Width:  uint16(r.readBits(16)) + 1,
+				Height: uint16(r.readBits(16)) + 1,
+			})
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return s, nil
+}
+
+func (dd *DependencyDescriptor) Dump() {
+	slog.Debug("DependencyDescriptor",
+		"startOfFrame", dd.StartOfFrame,
+		"endOfFrame", dd.EndOfFrame,
+		"frameDependencyTemplateId", dd.FrameDependencyTemplateId,
+		"frameNumber", dd.FrameNumber,
+		"spatialId", dd.SpatialId,
+		"temporalId", dd.TemporalId,
+		"dtis", dd.Dtis,
+		"fdiffs", dd.Fdiffs,
+		"chainFdiffs", dd.ChainFdiffs,
+		"activeDecodeTargetsBitmask", dd.ActiveDecodeTargetsBitmask,
+		"templateDependencyStructurePresent", dd.TemplateDependencyStructurePresent,
+	)
+}
+
+// bitReader reads big endian bit fields. Reading past the end sets err and
+// returns zeros.
+type bitReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *bitReader) readBits(n int) (value uint32) {
+	for i := 0; i < n; i++ {
+		if r.offset >= len(r.data)*8 {
+			r.err = ErrDependencyDescriptorTooShort
+			return 0
+		}
+		bit := (r.data[r.offset/8] >> (7 - r.offset%8)) & 0x01
+		value = value<<1 | uint32(bit)
+		r.offset++
+	}
+	return
+}
+
+// readNonSymmetric reads a non-symmetric unsigned encoded integer with a
+// maximum value of n-1.
+func (r *bitReader) readNonSymmetric(n uint32) uint32 {
+	w := 0
+	for x := n; x != 0; x >>= 1 {
+		w++
+	}
+	m := uint32(1)<<w - n
+	v := r.readBits(w - 1)
+	if v < m {
+		return v
+	}
+	return v<<1 - m + r.readBits(1)
+}
@@ -0,0 +1,264 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+// testBitWriter writes big endian bit fields.
+type testBitWriter struct {
+	data   []byte
+	offset int
+}
+
+func (w *testBitWriter) write(value uint32, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.offset%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[w.offset/8] |= byte((value>>i)&0x01) << (7 - w.offset%8)
+		w.offset++
+	}
+}
+
+func (w *testBitWriter) writeBool(value bool) {
+	if value {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+}
+
+// writeNonSymmetric writes v as a non-symmetric unsigned integer with a
+// maximum value of n-1.
+func (w *testBitWriter) writeNonSymmetric(v, n uint32) {
+	bits := 0
+	for x := n; x != 0; x >>= 1 {
+		bits++
+	}
+	m := uint32(1)<<bits - n
+	if v < m {
+		w.write(v, bits-1)
+		return
+	}
+	w.write((v+m)>>1, bits-1)
+	w.write((v+m)&0x01, 1)
+}
+
+type testTemplate struct {
+	// nextLayerIdc tells the layers of the next template: 0 same layers, 1 next
+	// temporal layer, 2 next spatial layer, 3 no more templates.
+	nextLayerIdc uint32
+	dtis         []uint32
+	fdiffs       []uint32
+	chainFdiffs  []uint32
+}
+
+type testStructure struct {
+	templateIdOffset        uint32
+	decodeTargetCount       uint32
+	templates               []testTemplate
+	chainCount              uint32
+	decodeTargetProtectedBy []uint32
+	resolutions             [][2]uint32
+}
+
+func (s testStructure) write(w *testBitWriter) {
+	w.write(s.templateIdOffset, 6)
+	w.write(s.decodeTargetCount-1, 5)
+	for _, t := range s.templates {
+		w.write(t.nextLayerIdc, 2)
+	}
+	for _, t := range s.templates {
+		for _, dti := range t.dtis {
+			w.write(dti, 2)
+		}
+	}
+	for _, t := range s.templates {
+		for _, fdiff := range t.fdiffs {
+			w.write(1, 1)
+			w.write(fdiff-1, 4)
+		}
+		w.write(0, 1)
+	}
+	w.writeNonSymmetric(s.chainCount, s.decodeTargetCount+1)
+	if s.chainCount > 0 {
+		for _, chain := range s.decodeTargetProtectedBy {
+			w.writeNonSymmetric(chain, s.chainCount)
+		}
+		for _, t := range s.templates {
+			for _, chainFdiff := range t.chainFdiffs {
+				w.write(chainFdiff, 4)
+			}
+		}
+	}
+	w.writeBool(len(s.resolutions) > 0)
+	for _, resolution := range s.resolutions {
+		w.write(resolution[0]-1, 16)
+		w.write(resolution[1]-1, 16)
+	}
+}
+
+// l1t2Structure is a single spatial layer and two temporal layers structure,
+// with a decode target per temporal layer protected by a single chain.
+var l1t2Structure = testStructure{
+	decodeTargetCount: 2,
+	templates: []testTemplate{
+		{nextLayerIdc: 0, dtis: []uint32{2, 2}, chainFdiffs: []uint32{0}},
+		{nextLayerIdc: 1, dtis: []uint32{3, 3}, fdiffs: []uint32{2}, chainFdiffs: []uint32{2}},
+		{nextLayerIdc: 3, dtis: []uint32{0, 2}, fdiffs: []uint32{1}, chainFdiffs: []uint32{1}},
+	},
+	chainCount:              1,
+	decodeTargetProtectedBy: []uint32{0, 0},
+	resolutions:             [][2]uint32{{640, 360}},
+}
+
+// l2t1Structure is a two spatial layers and single temporal layer structure.
+var l2t1Structure = testStructure{
+	decodeTargetCount: 2,
+	templates: []testTemplate{
+		{nextLayerIdc: 0, dtis: []uint32{2, 2}},
+		{nextLayerIdc: 2, dtis: []uint32{3, 3}, fdiffs: []uint32{2}},
+		{nextLayerIdc: 0, dtis: []uint32{0, 2}, fdiffs: []uint32{1}},
+		{nextLayerIdc: 3, dtis: []uint32{0, 3}, fdiffs: []uint32{1, 2}},
+	},
+}
+
+type testDependencyDescriptor struct {
+	startOfFrame bool
+	endOfFrame   bool
+	templateId   uint32
+	frameNumber  uint32
+	structure    *testStructure
+	customFdiffs []uint32
+}
+
+func (d testDependencyDescriptor) data() []byte {
+	w := &testBitWriter{}
+	w.writeBool(d.startOfFrame)
+	w.writeBool(d.endOfFrame)
+	w.write(d.templateId, 6)
+	w.write(d.frameNumber, 16)
+
+	if d.structure == nil && len(d.customFdiffs) == 0 {
+		return w.data
+	}
+
+	w.writeBool(d.structure != nil)
+	w.write(0, 2)                        // Active decode targets and custom dtis.
+	w.writeBool(len(d.customFdiffs) > 0) // Custom fdiffs.
+	w.write(0, 1)                        // Custom chains.
+
+	if d.structure != nil {
+		d.structure.write(w)
+	}
+	if len(d.customFdiffs) > 0 {
+		for _, fdiff := range d.customFdiffs {
+			w.write(2, 2)
+			w.write(fdiff-1, 8)
+		}
+		w.write(0, 2)
+	}
+
+	return w.data
+}
+
+func TestDependencyDescriptor(t *testing.T) {
+	t.Run("parses the template dependency structure", func(t *testing.T) {
+		parser := codecs.NewDependencyDescriptorParser()
+
+		dd, err := parser.Parse(testDependencyDescriptor{
+			startOfFrame: true,
+			endOfFrame:   true,
+			frameNumber:  1,
+			structure:    &l1t2Structure,
+		}.data())
+		require.NoError(t, err)
+		require.True(t, dd.StartOfFrame)
+		require.True(t, dd.EndOfFrame)
+		require.EqualValues(t, 1, dd.FrameNumber)
+		require.True(t, dd.TemplateDependencyStructurePresent)
+		require.EqualValues(t, 0, dd.SpatialId)
+		require.EqualValues(t, 0, dd.TemporalId)
+		require.Equal(t, []uint8{2, 2}, dd.Dtis)
+		require.Empty(t, dd.Fdiffs)
+		require.Equal(t, []uint8{0}, dd.ChainFdiffs)
+		require.EqualValues(t, 0x03, dd.ActiveDecodeTargetsBitmask)
+
+		structure := parser.GetTemplateDependencyStructure()
+		require.NotNil(t, structure)
+		require.EqualValues(t, 2, structure.DecodeTargetCount)
+		require.EqualValues(t, 1, structure.ChainCount)
+		require.Len(t, structure.Templates, 3)
+		require.EqualValues(t, 0, structure.MaxSpatialId)
+		require.EqualValues(t, 1, structure.MaxTemporalId)
+		require.Equal(t, []uint8{0, 0}, structure.DecodeTargetProtectedBy)
+		require.Equal(t, []uint8{0, 0}, structure.DecodeTargetSpatialIds)
+		require.Equal(t, []uint8{0, 1}, structure.DecodeTargetTemporalIds)
+		require.Equal(t, []codecs.RenderResolution{{Width: 640, Height: 360}}, structure.RenderResolutions)
+		require.Equal(t, codecs.FrameDependencyTemplate{
+			SpatialId:   0,
+			TemporalId:  1,
+			Dtis:        []uint8{0, 2},
+			Fdiffs:      []uint16{1},
+			ChainFdiffs: []uint8{1},
+		}, structure.Templates[2])
+	})
+
+	t.Run("uses the last received structure and custom fdiffs", func(t *testing.T) {
+		parser := codecs.NewDependencyDescriptorParser()
+
+		// The structure is discarded if the descriptor is invalid.
+		_, err := parser.Parse(testDependencyDescriptor{templateId: 5, structure: &l1t2Structure}.data())
+		require.ErrorIs(t, err, codecs.ErrDependencyDescriptorInvalidTemplate)
+		require.Nil(t, parser.GetTemplateDependencyStructure())
+
+		_, err = parser.Parse(testDependencyDescriptor{structure: &l1t2Structure}.data())
+		require.NoError(t, err)
+
+		dd, err := parser.Parse(testDependencyDescriptor{templateId: 2, frameNumber: 2}.data())
+		require.NoError(t, err)
+		require.False(t, dd.TemplateDependencyStructurePresent)
+		require.EqualValues(t, 1, dd.TemporalId)
+		require.Equal(t, []uint16{1}, dd.Fdiffs)
+
+		dd, err = parser.Parse(testDependencyDescriptor{templateId: 1, frameNumber: 3, customFdiffs: []uint32{3, 200}}.data())
+		require.NoError(t, err)
+		require.EqualValues(t, 0, dd.TemporalId)
+		require.Equal(t, []uint16{3, 200}, dd.Fdiffs)
+	})
+
+	t.Run("honors the template id offset", func(t *testing.T) {
+		parser := codecs.NewDependencyDescriptorParser()
+		structure := l1t2Structure
+		structure.templateIdOffset = 62
+
+		_, err := parser.Parse(testDependencyDescriptor{templateId: 62, structure: &structure}.data())
+		require.NoError(t, err)
+
+		dd, err := parser.Parse(testDependencyDescriptor{templateId: 0}.data())
+		require.NoError(t, err)
+		require.EqualValues(t, 1, dd.TemporalId)
+
+		_, err = parser.Parse(testDependencyDescriptor{templateId: 1}.data())
+		require.ErrorIs(t, err, codecs.ErrDependencyDescriptorInvalidTemplate)
+	})
+
+	t.Run("rejects invalid descriptors", func(t *testing.T) {
+		parser := codecs.NewDependencyDescriptorParser()
+
+		_, err := parser.Parse([]byte{0xc0, 0x00})
+		require.ErrorIs(t, err, codecs.ErrDependencyDescriptorTooShort)
+
+		_, err = parser.Parse(testDependencyDescriptor{}.data())
+		require.ErrorIs(t, err, codecs.ErrDependencyDescriptorNoStructure)
+
+		// Truncated structure.
+		data := testDependencyDescriptor{structure: &l1t2Structure}.data()
+		_, err = parser.Parse(data[:5])
+		require.ErrorIs(t, err, codecs.ErrDependencyDescriptorTooShort)
+		require.Nil(t, parser.GetTemplateDependencyStructure())
+	})
+}
@@ -0,0 +1,215 @@
+package codecs
+
+import (
+	"encoding/binary"
+	"log/slog"
+)
+
+// H264 NAL unit types.
+const (
+	h264NalTypeIdr   = 5
+	h264NalTypeSps   = 7
+	h264NalTypePps   = 8
+	h264NalTypeStapA = 24
+	h264NalTypeFuA   = 28
+	h264NalTypeFuB   = 29
+)
+
+// H264PayloadDescriptor holds the frame marking values and the key frame
+// status of a H264 packet.
+type H264PayloadDescriptor struct {
+	// Frame marking fields.
+	S         bool // Start of frame.
+	E         bool // End of frame.
+	I         bool // Independent frame.
+	D         bool // Discardable frame.
+	B         bool // Base layer sync.
+	Tid       uint8
+	Lid       uint8
+	Tl0PicIdx uint8
+
+	// Parsed values.
+	IsKeyFrame   bool
+	HasTid       bool
+	HasLid       bool
+	HasTl0PicIdx bool
+}
+
+// ParseH264 inspects the given payload and the optional frame marking header
+// extension value. It returns nil if the payload is invalid.
+func ParseH264(data []byte, frameMarking []byte) *H264PayloadDescriptor {
+	if len(data) < 2 {
+		return nil
+	}
+
+	p := &H264PayloadDescriptor{}
+
+	// Use frame marking.
+	if len(frameMarking) > 0 && len(frameMarking) <= 3 {
+		b := frameMarking[0]
+		p.S = (b>>7)&0x01 == 1
+		p.E = (b>>6)&0x01 == 1
+		p.I = (b>>5)&0x01 == 1
+		p.D = (b>>4)&0x01 == 1
+		p.B = (b>>3)&0x01 == 1
+		p.Tid = b & 0x07
+		p.HasTid = true
+
+		if len(frameMarking) >= 2 {
+			p.HasLid = true
+			p.Lid = frameMarking[1]
+		}
+
+		if len(frameMarking) == 3 {
+			p.HasTl0PicIdx = true
+			p.Tl0PicIdx = frameMarking[2]
+		}
+
+		// Detect key frame.
+		if p.S && p.I {
+			p.IsKeyFrame = true
+		}
+	}
+
+	// Some encoders produce wrong frame marking values (without I set in key
+	// frames), so always inspect the payload if the key frame was not detected
+	// above.
+	if !p.IsKeyFrame {
+		p.IsKeyFrame = isH264KeyFrame(data)
+	}
+
+	return p
+}
+
+func isH264KeyFrameNal(nal uint8) bool {
+	switch nal {
+	case h264NalTypeIdr, h264NalTypeSps, h264NalTypePps:
+		return true
+	default:
+		return false
+	}
+}
+
+func isH264KeyFrame(data []byte) bool {
+	nal := data[0] & 0x1F
+
+	switch nal {
+	// Aggregation packet, STAP-A.
+	case h264NalTypeStapA:
+		offset := 1
+
+		// Iterate NAL units.
+		for len(data)-offset >= 3 {
+			naluSize := int(binary.BigEndian.Uint16(data[offset:]))
+			subnal := data[offset+2] & 0x1F
+
+			if isH264KeyFrameNal(subnal) {
+				return true
+			}
+
+			// Check if there is room for the indicated NAL unit size.
+			if len(data)-offset < naluSize+2 {
+				break
+			}
+
+			offset += naluSize + 2
+		}
+
+		return false
+
+	// Fragmentation unit, FU-A or FU-B.
+	case h264NalTypeFuA, h264NalTypeFuB:
+		subnal := data[1] & 0x1F
+		startBit := data[1] & 0x80
+
+		return isH264KeyFrameNal(subnal) && startBit == 0x80
+
+	// Single NAL unit packet.
+	default:
+		return isH264KeyFrameNal(nal)
+	}
+}
+
+func (p *H264PayloadDescriptor) Dump() {
+	slog.Debug("H264PayloadDescriptor",
+		"s", p.S,
+		"e", p.E,
+		"i", p.I,
+		"d", p.D,
+		"b", p.B,
+		"tid", p.Tid,
+		"lid", p.Lid,
+		"tl0PicIdx", p.Tl0PicIdx,
+		"isKeyFrame", p.IsKeyFrame,
+		"hasTid", p.HasTid,
+		"hasLid", p.HasLid,
+		"hasTl0PicIdx", p.HasTl0PicIdx,
+	)
+}
+
+// H264PayloadDescriptorHandler decides whether H264 packets are forwarded
+// according to the temporal layers of the encoding context.
+type H264PayloadDescriptorHandler struct {
+	payloadDescriptor *H264PayloadDescriptor
+}
+
+func NewH264PayloadDescriptorHandler(payloadDescriptor *H264PayloadDescriptor) *H264PayloadDescriptorHandler {
+	return &H264PayloadDescriptorHandler{
+		payloadDescriptor: payloadDescriptor,
+	}
+}
+
+func (h *H264PayloadDescriptorHandler) Dump() {
+	h.payloadDescriptor.Dump()
+}
+
+// Process returns ok as false if the packet belongs to a temporal layer that
+// must not be forwarded.
+func (h *H264PayloadDescriptorHandler) Process(context *EncodingContext, data []byte) (marker, ok bool) {
+	p := h.payloadDescriptor
+
+	// Check if the payload should contain temporal layer info.
+	if context.GetTemporalLayers() > 1 && !p.HasTid {
+		slog.Debug("stream is supposed to have >1 temporal layers but does not have tid field")
+	}
+
+	if p.HasTid && int16(p.Tid) > context.GetTargetTemporalLayer() {
+		return false, false
+	} else if p.HasTid && int16(p.Tid) > context.GetCurrentTemporalLayer() && !p.B {
+		// Upgrade required. Drop current packet if base flag is not set.
+		return false, false
+	}
+
+	// Update/fix current temporal layer.
+	if p.HasTid && int16(p.Tid) > context.GetCurrentTemporalLayer() {
+		context.SetCurrentTemporalLayer(int16(p.Tid))
+	} else if !p.HasTid {
+		context.SetCurrentTemporalLayer(0)
+	}
+
+	if context.GetCurrentTemporalLayer() > context.GetTargetTemporalLayer() {
+		context.SetCurrentTemporalLayer(context.GetTargetTemporalLayer())
+	}
+
+	return false, true
+}
+
+func (h *H264PayloadDescriptorHandler) Restore(data []byte) {}
+
+func (h *H264PayloadDescriptorHandler) GetSpatialLayer() uint8 {
+	if h.payloadDescriptor.HasLid {
+		return h.payloadDescriptor.Lid
+	}
+	return 0
+}
+
+func (h *H264PayloadDescriptorHandler) GetTemporalLayer() uint8 {
+	if h.payloadDescriptor.HasTid {
+		return h.payloadDescriptor.Tid
+	}
+	return 0
+}
+
+func (h *H264PayloadDescriptorHandler) IsKeyFrame() bool {
+	return h.payloadDescriptor.IsKeyFrame
+}
@@ -0,0 +1,83 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestH264(t *testing.T) {
+	t.Run("detects key frames in the payload", func(t *testing.T) {
+		testCases := []struct {
+			name       string
+			payload    []byte
+			isKeyFrame bool
+		}{
+			{"single IDR", []byte{0x65, 0x88, 0x80}, true},
+			{"single SPS", []byte{0x67, 0x42, 0x00}, true},
+			{"single PPS", []byte{0x68, 0xce, 0x3c}, true},
+			{"single non IDR", []byte{0x41, 0x9a, 0x00}, false},
+			{"STAP-A with SPS", []byte{0x78, 0x00, 0x02, 0x06, 0x05, 0x00, 0x02, 0x67, 0x42}, true},
+			{"STAP-A without key frame", []byte{0x78, 0x00, 0x02, 0x41, 0x9a, 0x00, 0x02, 0x41, 0x9b}, false},
+			{"STAP-A with truncated NAL unit", []byte{0x78, 0x00, 0x10, 0x41, 0x9a, 0x00, 0x02, 0x67, 0x42}, false},
+			{"FU-A start of IDR", []byte{0x7c, 0x85, 0x88}, true},
+			{"FU-A middle of IDR", []byte{0x7c, 0x05, 0x88}, false},
+			{"FU-A start of non IDR", []byte{0x7c, 0x81, 0x9a}, false},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				payloadDescriptor := codecs.ParseH264(tc.payload, nil)
+				require.NotNil(t, payloadDescriptor)
+				require.Equal(t, tc.isKeyFrame, payloadDescriptor.IsKeyFrame)
+				require.False(t, payloadDescriptor.HasTid)
+			})
+		}
+
+		require.Nil(t, codecs.ParseH264([]byte{0x65}, nil))
+	})
+
+	t.Run("reads the frame marking header extension", func(t *testing.T) {
+		// S, I and B set, TID 2, LID 1, TL0PICIDX 7.
+		payloadDescriptor := codecs.ParseH264([]byte{0x41, 0x9a}, []byte{0xaa, 0x01, 0x07})
+		require.NotNil(t, payloadDescriptor)
+		require.True(t, payloadDescriptor.IsKeyFrame)
+		require.True(t, payloadDescriptor.HasTid)
+		require.EqualValues(t, 2, payloadDescriptor.Tid)
+		require.True(t, payloadDescriptor.HasLid)
+		require.EqualValues(t, 1, payloadDescriptor.Lid)
+		require.True(t, payloadDescriptor.HasTl0PicIdx)
+		require.EqualValues(t, 7, payloadDescriptor.Tl0PicIdx)
+
+		// Short form without I set, key frame detected from the payload.
+		payloadDescriptor = codecs.ParseH264([]byte{0x65, 0x88}, []byte{0x80})
+		require.True(t, payloadDescriptor.IsKeyFrame)
+		require.False(t, payloadDescriptor.HasLid)
+	})
+
+	t.Run("drops temporal layers above the target one", func(t *testing.T) {
+		context := codecs.NewEncodingContext(codecs.EncodingContextParams{SpatialLayers: 1, TemporalLayers: 3})
+		context.SetTargetTemporalLayer(1)
+		context.SetCurrentTemporalLayer(0)
+
+		process := func(frameMarking byte) bool {
+			packet := TestPacket{payload: []byte{0x41, 0x9a}, frameMarking: []byte{frameMarking}}
+			handler := codecs.GetPayloadDescriptorHandler("video/H264", packet)
+			require.NotNil(t, handler)
+			_, ok := handler.Process(context, packet.payload)
+			return ok
+		}
+
+		// TID 2.
+		require.False(t, process(0x82))
+		// TID 1 without base layer sync.
+		require.False(t, process(0x81))
+		require.EqualValues(t, 0, context.GetCurrentTemporalLayer())
+		// TID 1 with base layer sync.
+		require.True(t, process(0x89))
+		require.EqualValues(t, 1, context.GetCurrentTemporalLayer())
+		require.True(t, process(0x80))
+		require.True(t, process(0x81))
+	})
+}
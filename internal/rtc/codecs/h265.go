@@ -0,0 +1,171 @@
+package codecs
+
+import (
+	"encoding/binary"
+	"log/slog"
+)
+
+// H265 NAL unit types.
+const (
+	h265NalTypeTsaN      = 2
+	h265NalTypeTsaR      = 3
+	h265NalTypeStsaN     = 4
+	h265NalTypeStsaR     = 5
+	h265NalTypeBlaWLp    = 16
+	h265NalTypeCraNut    = 21
+	h265NalTypeIrapVcl23 = 23
+	h265NalTypeVps       = 32
+	h265NalTypeSps       = 33
+	h265NalTypePps       = 34
+	h265NalTypeAp        = 48
+	h265NalTypeFu        = 49
+)
+
+// H265PayloadDescriptor holds the NAL unit header values and the key frame
+// status of a H265 packet.
+type H265PayloadDescriptor struct {
+	// NAL unit header fields.
+	NalType uint8
+	LayerId uint8
+	Tid     uint8 // TemporalId, nuh_temporal_id_plus1 minus 1.
+
+	// Parsed values.
+	IsKeyFrame bool
+	// IsSwitchingPoint is set for TSA and STSA pictures, from which upper
+	// temporal layers can be decoded.
+	IsSwitchingPoint bool
+}
+
+// ParseH265 inspects the NAL unit headers of the given payload. It returns nil
+// if the payload is invalid.
+func ParseH265(data []byte) *H265PayloadDescriptor {
+	if len(data) < 3 {
+		return nil
+	}
+
+	p := &H265PayloadDescriptor{
+		NalType: (data[0] >> 1) & 0x3F,
+		LayerId: (data[0]&0x01)<<5 | data[1]>>3,
+	}
+
+	tidPlusOne := data[1] & 0x07
+	if tidPlusOne == 0 {
+		return nil
+	}
+	p.Tid = tidPlusOne - 1
+
+	switch p.NalType {
+	// Aggregation packet.
+	case h265NalTypeAp:
+		offset := 2
+
+		// Iterate NAL units.
+		for len(data)-offset >= 4 {
+			naluSize := int(binary.BigEndian.Uint16(data[offset:]))
+			subnal := (data[offset+2] >> 1) & 0x3F
+
+			p.setNalType(subnal)
+
+			// Check if there is room for the indicated NAL unit size.
+			if len(data)-offset < naluSize+2 {
+				break
+			}
+
+			offset += naluSize + 2
+		}
+
+	// Fragmentation unit, only the first fragment starts the NAL unit.
+	case h265NalTypeFu:
+		subnal := data[2] & 0x3F
+		startBit := data[2] & 0x80
+
+		if startBit == 0x80 {
+			p.setNalType(subnal)
+		}
+
+	// Single NAL unit packet.
+	default:
+		p.setNalType(p.NalType)
+	}
+
+	return p
+}
+
+func (p *H265PayloadDescriptor) setNalType(nal uint8) {
+	switch {
+	// IRAP pictures: BLA, IDR and CRA.
+	case nal >= h265NalTypeBlaWLp && nal <= h265NalTypeIrapVcl23:
+		p.IsKeyFrame = true
+	// Parameter sets.
+	case nal == h265NalTypeVps, nal == h265NalTypeSps, nal == h265NalTypePps:
+		p.IsKeyFrame = true
+	case nal >= h265NalTypeTsaN && nal <= h265NalTypeStsaR:
+		p.IsSwitchingPoint = true
+	}
+}
+
+func (p *H265PayloadDescriptor) Dump() {
+	slog.Debug("H265PayloadDescriptor",
+		"nalType", p.NalType,
+		"layerId", p.LayerId,
+		"tid", p.Tid,
+		"isKeyFrame", p.IsKeyFrame,
+		"isSwitchingPoint", p.IsSwitchingPoint,
+	)
+}
+
+// H265PayloadDescriptorHandler decides whether H265 packets are forwarded
+// according to the temporal layers of the encoding context.
+type H265PayloadDescriptorHandler struct {
+	payloadDescriptor *H265PayloadDescriptor
+}
+
+func NewH265PayloadDescriptorHandler(payloadDescriptor *H265PayloadDescriptor) *H265PayloadDescriptorHandler {
+	return &H265PayloadDescriptorHandler{
+		payloadDescriptor: payloadDescriptor,
+	}
+}
+
+func (h *H265PayloadDescriptorHandler) Dump() {
+	h.payloadDescriptor.Dump()
+}
+
+// Process returns ok as false if the packet belongs to a temporal layer that
+// must not be forwarded.
+func (h *H265PayloadDescriptorHandler) Process(context *EncodingContext, data []byte) (marker, ok bool) {
+	p := h.payloadDescriptor
+	tid := int16(p.Tid)
+
+	if tid > context.GetTargetTemporalLayer() {
+		return false, false
+	} else if tid > context.GetCurrentTemporalLayer() &&
+		context.GetCurrentTemporalLayer() != -1 && !p.IsKeyFrame && !p.IsSwitchingPoint {
+		// Upgrade required. Drop current packet if it is not a switching point.
+		return false, false
+	}
+
+	// Update current temporal layer.
+	if tid > context.GetCurrentTemporalLayer() {
+		context.SetCurrentTemporalLayer(tid)
+	}
+
+	if context.GetCurrentTemporalLayer() > context.GetTargetTemporalLayer() {
+		context.SetCurrentTemporalLayer(context.GetTargetTemporalLayer())
+	}
+
+	return false, true
+}
+
+func (h *H265PayloadDescriptorHandler) Restore(data []byte) {}
+
+func (h *H265PayloadDescriptorHandler) GetSpatialLayer() uint8 {
+	return h.payloadDescriptor.LayerId
+}
+
+func (h *H265PayloadDescriptorHandler) GetTemporalLayer() uint8 {
+	return h.payloadDescriptor.Tid
+}
+
+func (h *H265PayloadDescriptorHandler) IsKeyFrame() bool {
+	return h.payloadDescriptor.IsKeyFrame
+}
@@ -0,0 +1,103 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+// h265NalHeader returns the two bytes NAL unit header of the given type and
+// temporal id.
+func h265NalHeader(nalType, tid uint8) []byte {
+	return []byte{nalType << 1, tid + 1}
+}
+
+func TestH265(t *testing.T) {
+	t.Run("detects key frames in the payload", func(t *testing.T) {
+		ap := func(nalTypes ...uint8) []byte {
+			data := h265NalHeader(48, 0)
+			for _, nalType := range nalTypes {
+				data = append(data, 0x00, 0x03)
+				data = append(data, h265NalHeader(nalType, 0)...)
+				data = append(data, 0xaa)
+			}
+			return data
+		}
+		fu := func(start bool, nalType uint8) []byte {
+			fuHeader := nalType
+			if start {
+				fuHeader |= 0x80
+			}
+			return append(h265NalHeader(49, 0), fuHeader, 0xaa)
+		}
+
+		testCases := []struct {
+			name       string
+			payload    []byte
+			isKeyFrame bool
+		}{
+			{"single IDR_W_RADL", append(h265NalHeader(19, 0), 0xaa), true},
+			{"single IDR_N_LP", append(h265NalHeader(20, 0), 0xaa), true},
+			{"single CRA", append(h265NalHeader(21, 0), 0xaa), true},
+			{"single BLA_W_LP", append(h265NalHeader(16, 0), 0xaa), true},
+			{"single VPS", append(h265NalHeader(32, 0), 0xaa), true},
+			{"single SPS", append(h265NalHeader(33, 0), 0xaa), true},
+			{"single PPS", append(h265NalHeader(34, 0), 0xaa), true},
+			{"single TRAIL_R", append(h265NalHeader(1, 0), 0xaa), false},
+			{"AP with parameter sets", ap(32, 33, 34), true},
+			{"AP without key frame", ap(1, 1), false},
+			{"FU start of IDR", fu(true, 19), true},
+			{"FU middle of IDR", fu(false, 19), false},
+			{"FU start of TRAIL_R", fu(true, 1), false},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				payloadDescriptor := codecs.ParseH265(tc.payload)
+				require.NotNil(t, payloadDescriptor)
+				require.Equal(t, tc.isKeyFrame, payloadDescriptor.IsKeyFrame)
+			})
+		}
+
+		// Too short.
+		require.Nil(t, codecs.ParseH265(h265NalHeader(19, 0)))
+		// Forbidden zero temporal id plus one.
+		require.Nil(t, codecs.ParseH265([]byte{19 << 1, 0x00, 0xaa}))
+	})
+
+	t.Run("reads the temporal id from the NAL unit header", func(t *testing.T) {
+		payloadDescriptor := codecs.ParseH265(append(h265NalHeader(3, 2), 0xaa))
+		require.NotNil(t, payloadDescriptor)
+		require.EqualValues(t, 3, payloadDescriptor.NalType)
+		require.EqualValues(t, 2, payloadDescriptor.Tid)
+		require.EqualValues(t, 0, payloadDescriptor.LayerId)
+		require.True(t, payloadDescriptor.IsSwitchingPoint)
+	})
+
+	t.Run("drops temporal layers above the target one", func(t *testing.T) {
+		context := codecs.NewEncodingContext(codecs.EncodingContextParams{SpatialLayers: 1, TemporalLayers: 3})
+		context.SetTargetTemporalLayer(1)
+		context.SetCurrentTemporalLayer(0)
+
+		process := func(nalType, tid uint8) bool {
+			packet := TestPacket{payload: append(h265NalHeader(nalType, tid), 0xaa)}
+			handler := codecs.GetPayloadDescriptorHandler("video/H265", packet)
+			require.NotNil(t, handler)
+			require.EqualValues(t, tid, handler.GetTemporalLayer())
+			_, ok := handler.Process(context, packet.payload)
+			return ok
+		}
+
+		// TID 2.
+		require.False(t, process(3, 2))
+		// TID 1 not being a switching point.
+		require.False(t, process(1, 1))
+		require.EqualValues(t, 0, context.GetCurrentTemporalLayer())
+		// TID 1 TSA.
+		require.True(t, process(3, 1))
+		require.EqualValues(t, 1, context.GetCurrentTemporalLayer())
+		require.True(t, process(1, 0))
+		require.True(t, process(1, 1))
+	})
+}
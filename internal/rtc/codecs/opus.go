@@ -0,0 +1,88 @@
+package codecs
+
+import (
+	"log/slog"
+)
+
+// OpusPayloadDescriptor holds the TOC byte values and the DTX status of an
+// Opus packet.
+type OpusPayloadDescriptor struct {
+	// TOC byte fields.
+	Config uint8
+	Stereo bool
+	Code   uint8
+
+	// Parsed values.
+	IsDtx bool
+}
+
+// ParseOpus parses the TOC byte of the given payload. It returns nil if the
+// payload is empty.
+func ParseOpus(data []byte) *OpusPayloadDescriptor {
+	if len(data) < 1 {
+		return nil
+	}
+
+	p := &OpusPayloadDescriptor{}
+
+	b := data[0]
+	p.Config = b >> 3
+	p.Stereo = (b>>2)&0x01 == 1
+	p.Code = b & 0x03
+
+	// Encoders send 1 or 2 bytes packets (a TOC byte with empty frames)
+	// during silence when DTX is enabled.
+	if len(data) <= 2 {
+		p.IsDtx = true
+	}
+
+	return p
+}
+
+func (p *OpusPayloadDescriptor) Dump() {
+	slog.Debug("OpusPayloadDescriptor",
+		"config", p.Config,
+		"stereo", p.Stereo,
+		"code", p.Code,
+		"isDtx", p.IsDtx,
+	)
+}
+
+// OpusPayloadDescriptorHandler drops DTX packets if the encoding context
+// ignores them.
+type OpusPayloadDescriptorHandler struct {
+	payloadDescriptor *OpusPayloadDescriptor
+}
+
+func NewOpusPayloadDescriptorHandler(payloadDescriptor *OpusPayloadDescriptor) *OpusPayloadDescriptorHandler {
+	return &OpusPayloadDescriptorHandler{
+		payloadDescriptor: payloadDescriptor,
+	}
+}
+
+func (h *OpusPayloadDescriptorHandler) Dump() {
+	h.payloadDescriptor.Dump()
+}
+
+// Process returns ok as false if the packet is a DTX one and the context
+// ignores DTX.
+func (h *OpusPayloadDescriptorHandler) Process(context *EncodingContext, data []byte) (marker, ok bool) {
+	if h.payloadDescriptor.IsDtx && context.GetIgnoreDtx() {
+		return false, false
+	}
+	return false, true
+}
+
+func (h *OpusPayloadDescriptorHandler) Restore(data []byte) {}
+
+func (h *OpusPayloadDescriptorHandler) GetSpatialLayer() uint8 {
+	return 0
+}
+
+func (h *OpusPayloadDescriptorHandler) GetTemporalLayer() uint8 {
+	return 0
+}
+
+func (h *OpusPayloadDescriptorHandler) IsKeyFrame() bool {
+	return false
+}
@@ -0,0 +1,41 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpus(t *testing.T) {
+	t.Run("parses the TOC byte and detects DTX packets", func(t *testing.T) {
+		payloadDescriptor := codecs.ParseOpus([]byte{0x7d, 0x01, 0x02})
+		require.NotNil(t, payloadDescriptor)
+		require.EqualValues(t, 15, payloadDescriptor.Config)
+		require.True(t, payloadDescriptor.Stereo)
+		require.EqualValues(t, 1, payloadDescriptor.Code)
+		require.False(t, payloadDescriptor.IsDtx)
+
+		require.True(t, codecs.ParseOpus([]byte{0x78}).IsDtx)
+		require.True(t, codecs.ParseOpus([]byte{0x78, 0x00}).IsDtx)
+		require.Nil(t, codecs.ParseOpus(nil))
+	})
+
+	t.Run("drops DTX packets if the context ignores DTX", func(t *testing.T) {
+		context := codecs.NewEncodingContext(codecs.EncodingContextParams{})
+		dtx := codecs.GetPayloadDescriptorHandler("audio/opus", TestPacket{payload: []byte{0x78}})
+		require.NotNil(t, dtx)
+		speech := codecs.GetPayloadDescriptorHandler("audio/multiopus", TestPacket{payload: []byte{0x78, 0x01, 0x02}})
+		require.NotNil(t, speech)
+
+		_, ok := dtx.Process(context, nil)
+		require.True(t, ok)
+
+		context.SetIgnoreDtx(true)
+
+		_, ok = dtx.Process(context, nil)
+		require.False(t, ok)
+		_, ok = speech.Process(context, nil)
+		require.True(t, ok)
+	})
+}
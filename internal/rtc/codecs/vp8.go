@@ -0,0 +1,308 @@
+package codecs
+
+import (
+	"encoding/binary"
+	"log/slog"
+)
+
+// VP8PayloadDescriptor is the VP8 payload descriptor as defined in RFC 7741.
+type VP8PayloadDescriptor struct {
+	// Required fields.
+	Extended       bool
+	NonReference   bool
+	Start          bool
+	PartitionIndex uint8
+
+	// Optional field flags.
+	I bool // PictureID present.
+	L bool // TL0PICIDX present.
+	T bool // TID present.
+	K bool // KEYIDX present.
+
+	// Optional fields.
+	PictureId       uint16
+	Tl0PictureIndex uint8
+	TlIndex         uint8
+	Y               bool
+	KeyIndex        uint8
+
+	// Parsed values.
+	IsKeyFrame           bool
+	HasPictureId         bool
+	HasOneBytePictureId  bool
+	HasTwoBytesPictureId bool
+	HasTl0PictureIndex   bool
+	HasTlIndex           bool
+}
+
+// ParseVP8 parses the VP8 payload descriptor at the beginning of the given
+// payload. It returns nil if the payload is invalid.
+func ParseVP8(data []byte) *VP8PayloadDescriptor {
+	if len(data) < 1 {
+		return nil
+	}
+
+	p := &VP8PayloadDescriptor{}
+	offset := 0
+
+	b := data[offset]
+	p.Extended = (b>>7)&0x01 == 1
+	p.NonReference = (b>>5)&0x01 == 1
+	p.Start = (b>>4)&0x01 == 1
+	p.PartitionIndex = b & 0x07
+
+	if p.Extended {
+		offset++
+		if len(data) < offset+1 {
+			return nil
+		}
+
+		b = data[offset]
+		p.I = (b>>7)&0x01 == 1
+		p.L = (b>>6)&0x01 == 1
+		p.T = (b>>5)&0x01 == 1
+		p.K = (b>>4)&0x01 == 1
+	}
+
+	if p.I {
+		offset++
+		if len(data) < offset+1 {
+			return nil
+		}
+
+		b = data[offset]
+
+		// M bit set, 15 bits picture id.
+		if (b>>7)&0x01 == 1 {
+			offset++
+			if len(data) < offset+1 {
+				return nil
+			}
+
+			p.HasTwoBytesPictureId = true
+			p.PictureId = uint16(b&0x7F)<<8 | uint16(data[offset])
+		} else {
+			p.HasOneBytePictureId = true
+			p.PictureId = uint16(b & 0x7F)
+		}
+
+		p.HasPictureId = true
+	}
+
+	if p.L {
+		offset++
+		if len(data) < offset+1 {
+			return nil
+		}
+
+		p.HasTl0PictureIndex = true
+		p.Tl0PictureIndex = data[offset]
+	}
+
+	if p.T || p.K {
+		offset++
+		if len(data) < offset+1 {
+			return nil
+		}
+
+		b = data[offset]
+		p.HasTlIndex = p.T
+		p.TlIndex = (b >> 6) & 0x03
+		p.Y = (b>>5)&0x01 == 1
+		p.KeyIndex = b & 0x1F
+	}
+
+	// Detect key frame: P bit of the VP8 payload header unset in the first
+	// packet of the first partition.
+	offset++
+	if len(data) >= offset+1 && p.Start && p.PartitionIndex == 0 && data[offset]&0x01 == 0 {
+		p.IsKeyFrame = true
+	}
+
+	return p
+}
+
+func (p *VP8PayloadDescriptor) Dump() {
+	slog.Debug("VP8PayloadDescriptor",
+		"extended", p.Extended,
+		"nonReference", p.NonReference,
+		"start", p.Start,
+		"partitionIndex", p.PartitionIndex,
+		"i", p.I,
+		"l", p.L,
+		"t", p.T,
+		"k", p.K,
+		"pictureId", p.PictureId,
+		"tl0PictureIndex", p.Tl0PictureIndex,
+		"tlIndex", p.TlIndex,
+		"y", p.Y,
+		"keyIndex", p.KeyIndex,
+		"isKeyFrame", p.IsKeyFrame,
+		"hasPictureId", p.HasPictureId,
+		"hasOneBytePictureId", p.HasOneBytePictureId,
+		"hasTwoBytesPictureId", p.HasTwoBytesPictureId,
+		"hasTl0PictureIndex", p.HasTl0PictureIndex,
+		"hasTlIndex", p.HasTlIndex,
+	)
+}
+
+// Encode writes the given picture id and TL0PICIDX into the payload
+// descriptor at the beginning of data.
+func (p *VP8PayloadDescriptor) Encode(data []byte, pictureId uint16, tl0PictureIndex uint8) {
+	// Nothing to do.
+	if !p.Extended {
+		return
+	}
+
+	offset := 2
+
+	if p.I {
+		if p.HasTwoBytesPictureId {
+			binary.BigEndian.PutUint16(data[offset:], pictureId)
+			data[offset] |= 0x80
+			offset += 2
+		} else if p.HasOneBytePictureId {
+			data[offset] = uint8(pictureId & 0x7F)
+			offset++
+		}
+	}
+
+	if p.L {
+		data[offset] = tl0PictureIndex
+	}
+}
+
+// Restore writes back the original picture id and TL0PICIDX.
+func (p *VP8PayloadDescriptor) Restore(data []byte) {
+	if p.HasPictureId && p.HasTl0PictureIndex {
+		p.Encode(data, p.PictureId, p.Tl0PictureIndex)
+	}
+}
+
+// VP8PayloadDescriptorHandler decides whether VP8 packets are forwarded
+// according to the temporal layers of the encoding context.
+type VP8PayloadDescriptorHandler struct {
+	payloadDescriptor *VP8PayloadDescriptor
+}
+
+func NewVP8PayloadDescriptorHandler(payloadDescriptor *VP8PayloadDescriptor) *VP8PayloadDescriptorHandler {
+	return &VP8PayloadDescriptorHandler{
+		payloadDescriptor: payloadDescriptor,
+	}
+}
+
+func (h *VP8PayloadDescriptorHandler) Dump() {
+	h.payloadDescriptor.Dump()
+}
+
+// Process rewrites the picture id and TL0PICIDX so they stay continuous
+// across dropped packets. It returns ok as false if the packet must be
+// dropped.
+func (h *VP8PayloadDescriptorHandler) Process(context *EncodingContext, data []byte) (marker, ok bool) {
+	p := h.payloadDescriptor
+
+	// Check if the payload should contain temporal layer info.
+	if context.GetTemporalLayers() > 1 && !p.HasTlIndex {
+		slog.Debug("stream is supposed to have >1 temporal layers but does not have TlIndex field")
+	}
+
+	// Without managers the descriptor values can not be rewritten.
+	if context.pictureIdManager == nil || context.tl0PictureIndexManager == nil {
+		if p.HasTlIndex && int16(p.TlIndex) > context.GetTargetTemporalLayer() {
+			return false, false
+		}
+		h.updateCurrentTemporalLayer(context)
+		return false, true
+	}
+
+	// Check whether pictureId and tl0PictureIndex sync is required.
+	if context.syncRequired && p.HasPictureId && p.HasTl0PictureIndex {
+		context.pictureIdManager.Sync(p.PictureId - 1)
+		context.tl0PictureIndexManager.Sync(p.Tl0PictureIndex - 1)
+		context.syncRequired = false
+	}
+
+	// Incremental pictureId. Check the temporal layer.
+	if p.HasPictureId && p.HasTlIndex && p.HasTl0PictureIndex &&
+		isSeqHigherThan(p.PictureId, context.pictureIdManager.GetMaxInput(), 15) {
+		if int16(p.TlIndex) > context.GetTargetTemporalLayer() {
+			context.pictureIdManager.Drop(p.PictureId)
+
+			// Discard tl0PictureIndex drop since the same tl0PictureIndex is used
+			// for temporal layers > 0.
+			if p.TlIndex == 0 {
+				context.tl0PictureIndexManager.Drop(p.Tl0PictureIndex)
+			}
+
+			return false, false
+		} else if int16(p.TlIndex) > context.GetCurrentTemporalLayer() && !p.Y {
+			// Upgrade required. Drop current packet if layer sync flag is not
+			// set.
+			context.pictureIdManager.Drop(p.PictureId)
+
+			return false, false
+		}
+	}
+
+	// Update pictureId and tl0PictureIndex values.
+	var (
+		pictureId       uint16
+		tl0PictureIndex uint8
+	)
+
+	// Do not send a dropped pictureId.
+	if p.HasPictureId {
+		if pictureId, ok = context.pictureIdManager.Input(p.PictureId); !ok {
+			return false, false
+		}
+	}
+
+	// Do not send a dropped tl0PictureIndex.
+	if p.HasTl0PictureIndex {
+		if tl0PictureIndex, ok = context.tl0PictureIndexManager.Input(p.Tl0PictureIndex); !ok {
+			return false, false
+		}
+	}
+
+	h.updateCurrentTemporalLayer(context)
+
+	if p.HasPictureId && p.HasTl0PictureIndex {
+		p.Encode(data, pictureId, tl0PictureIndex)
+	}
+
+	return false, true
+}
+
+func (h *VP8PayloadDescriptorHandler) updateCurrentTemporalLayer(context *EncodingContext) {
+	p := h.payloadDescriptor
+
+	// Update/fix current temporal layer.
+	if p.HasTlIndex && int16(p.TlIndex) > context.GetCurrentTemporalLayer() {
+		context.SetCurrentTemporalLayer(int16(p.TlIndex))
+	} else if !p.HasTlIndex {
+		context.SetCurrentTemporalLayer(0)
+	}
+
+	if context.GetCurrentTemporalLayer() > context.GetTargetTemporalLayer() {
+		context.SetCurrentTemporalLayer(context.GetTargetTemporalLayer())
+	}
+}
+
+func (h *VP8PayloadDescriptorHandler) Restore(data []byte) {
+	h.payloadDescriptor.Restore(data)
+}
+
+func (h *VP8PayloadDescriptorHandler) GetSpatialLayer() uint8 {
+	return 0
+}
+
+func (h *VP8PayloadDescriptorHandler) GetTemporalLayer() uint8 {
+	if h.payloadDescriptor.HasTlIndex {
+		return h.payloadDescriptor.TlIndex
+	}
+	return 0
+}
+
+func (h *VP8PayloadDescriptorHandler) IsKeyFrame() bool {
+	return h.payloadDescriptor.IsKeyFrame
+}
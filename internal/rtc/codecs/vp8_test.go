@@ -0,0 +1,133 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+func createVP8Payload(pictureId uint16, tl0PictureIndex, tlIndex uint8, layerSync, keyFrame bool) []byte {
+	tid := tlIndex << 6
+	if layerSync {
+		tid |= 0x20
+	}
+	p := byte(0x01)
+	if keyFrame {
+		p = 0x00
+	}
+	return []byte{0x90, 0xe0, 0x80 | byte(pictureId>>8), byte(pictureId), tl0PictureIndex, tid, p, 0x01, 0x02}
+}
+
+func createVP8EncodingContext() *codecs.EncodingContext {
+	context := codecs.NewEncodingContext(codecs.EncodingContextParams{SpatialLayers: 1, TemporalLayers: 3},
+		codecs.WithPictureIdManager(rtc.NewSeqManager[uint16](15)),
+		codecs.WithTl0PictureIndexManager(rtc.NewSeqManager[uint8]()),
+	)
+	context.SetTargetTemporalLayer(0)
+	context.SetCurrentTemporalLayer(0)
+	context.SyncRequired()
+	return context
+}
+
+func TestVP8(t *testing.T) {
+	t.Run("parses the payload descriptor", func(t *testing.T) {
+		payloadDescriptor := codecs.ParseVP8([]byte{0x90, 0xe0, 0x80, 0x01, 0x00, 0x20, 0x00})
+		require.NotNil(t, payloadDescriptor)
+
+		require.True(t, payloadDescriptor.Extended)
+		require.True(t, payloadDescriptor.Start)
+		require.EqualValues(t, 0, payloadDescriptor.PartitionIndex)
+		require.True(t, payloadDescriptor.HasTwoBytesPictureId)
+		require.EqualValues(t, 1, payloadDescriptor.PictureId)
+		require.True(t, payloadDescriptor.HasTl0PictureIndex)
+		require.EqualValues(t, 0, payloadDescriptor.Tl0PictureIndex)
+		require.True(t, payloadDescriptor.HasTlIndex)
+		require.EqualValues(t, 0, payloadDescriptor.TlIndex)
+		require.True(t, payloadDescriptor.Y)
+		require.True(t, payloadDescriptor.IsKeyFrame)
+
+		// One byte picture id, no layer info.
+		payloadDescriptor = codecs.ParseVP8([]byte{0x80, 0x80, 0x7f, 0x01})
+		require.NotNil(t, payloadDescriptor)
+		require.True(t, payloadDescriptor.HasOneBytePictureId)
+		require.EqualValues(t, 0x7f, payloadDescriptor.PictureId)
+		require.False(t, payloadDescriptor.HasTlIndex)
+		require.False(t, payloadDescriptor.IsKeyFrame)
+	})
+
+	t.Run("rejects truncated payload descriptors", func(t *testing.T) {
+		require.Nil(t, codecs.ParseVP8(nil))
+		require.Nil(t, codecs.ParseVP8([]byte{0x90}))
+		require.Nil(t, codecs.ParseVP8([]byte{0x90, 0x80, 0x80}))
+		require.Nil(t, codecs.ParseVP8([]byte{0x90, 0xc0, 0x01}))
+	})
+
+	t.Run("encodes and restores picture id and tl0PictureIndex", func(t *testing.T) {
+		data := createVP8Payload(1000, 20, 1, false, false)
+		payloadDescriptor := codecs.ParseVP8(data)
+		require.NotNil(t, payloadDescriptor)
+
+		payloadDescriptor.Encode(data, 30000, 99)
+
+		encoded := codecs.ParseVP8(data)
+		require.EqualValues(t, 30000, encoded.PictureId)
+		require.EqualValues(t, 99, encoded.Tl0PictureIndex)
+		require.EqualValues(t, 1, encoded.TlIndex)
+
+		payloadDescriptor.Restore(data)
+		require.Equal(t, createVP8Payload(1000, 20, 1, false, false), data)
+	})
+
+	t.Run("drops temporal layers above the target one keeping picture ids continuous", func(t *testing.T) {
+		context := createVP8EncodingContext()
+
+		packets := []struct {
+			data            []byte
+			forwarded       bool
+			pictureId       uint16
+			tl0PictureIndex uint8
+		}{
+			{createVP8Payload(100, 10, 0, true, true), true, 1, 1},
+			{createVP8Payload(101, 10, 2, true, false), false, 0, 0},
+			{createVP8Payload(102, 10, 1, true, false), false, 0, 0},
+			{createVP8Payload(103, 11, 0, false, false), true, 2, 2},
+			{createVP8Payload(104, 11, 2, true, false), false, 0, 0},
+			{createVP8Payload(105, 12, 0, false, false), true, 3, 3},
+		}
+
+		for _, packet := range packets {
+			handler := codecs.GetPayloadDescriptorHandler("video/VP8", TestPacket{payload: packet.data})
+			require.NotNil(t, handler)
+
+			_, ok := handler.Process(context, packet.data)
+			require.Equal(t, packet.forwarded, ok)
+
+			if ok {
+				payloadDescriptor := codecs.ParseVP8(packet.data)
+				require.Equal(t, packet.pictureId, payloadDescriptor.PictureId)
+				require.Equal(t, packet.tl0PictureIndex, payloadDescriptor.Tl0PictureIndex)
+
+				handler.Restore(packet.data)
+			}
+		}
+
+		require.EqualValues(t, 0, context.GetCurrentTemporalLayer())
+	})
+
+	t.Run("upgrades the temporal layer on layer sync packets", func(t *testing.T) {
+		context := createVP8EncodingContext()
+		context.SetTargetTemporalLayer(2)
+
+		for _, data := range [][]byte{
+			createVP8Payload(200, 1, 0, true, true),
+			createVP8Payload(201, 1, 2, false, false),
+			createVP8Payload(202, 1, 1, true, false),
+		} {
+			codecs.GetPayloadDescriptorHandler("video/VP8", TestPacket{payload: data}).Process(context, data)
+		}
+
+		require.EqualValues(t, 1, context.GetCurrentTemporalLayer())
+	})
+}
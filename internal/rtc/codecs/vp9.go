@@ -0,0 +1,380 @@
+package codecs
+
+import (
+	"encoding/binary"
+	"log/slog"
+)
+
+// VP9ScalabilityStructure is the SS data of the VP9 payload descriptor.
+type VP9ScalabilityStructure struct {
+	SpatialLayers uint8
+	Widths        []uint16
+	Heights       []uint16
+	PictureGroups []VP9PictureGroup
+}
+
+// VP9PictureGroup describes a picture of the group of pictures in the
+// scalability structure.
+type VP9PictureGroup struct {
+	TemporalLayer    uint8
+	SwitchingUpPoint bool
+	PDiffs           []uint8
+}
+
+// VP9PayloadDescriptor is the VP9 payload descriptor as defined in RFC 9628.
+type VP9PayloadDescriptor struct {
+	// Header.
+	I bool // PictureID present.
+	P bool // Inter-picture predicted frame.
+	L bool // Layer indices present.
+	F bool // Flexible mode.
+	B bool // Start of frame.
+	E bool // End of frame.
+	V bool // Scalability structure present.
+	Z bool // Not a reference for upper spatial layers.
+
+	// Extension fields.
+	PictureId            uint16
+	SlIndex              uint8
+	TlIndex              uint8
+	Tl0PictureIndex      uint8
+	SwitchingUpPoint     bool
+	InterLayerDependency bool
+	PDiffs               []uint8
+	Ss                   *VP9ScalabilityStructure
+
+	// Parsed values.
+	IsKeyFrame           bool
+	HasPictureId         bool
+	HasOneBytePictureId  bool
+	HasTwoBytesPictureId bool
+	HasTl0PictureIndex   bool
+	HasSlIndex           bool
+	HasTlIndex           bool
+}
+
+// ParseVP9 parses the VP9 payload descriptor at the beginning of the given
+// payload. It returns nil if the payload is invalid.
+func ParseVP9(data []byte) *VP9PayloadDescriptor {
+	if len(data) < 1 {
+		return nil
+	}
+
+	p := &VP9PayloadDescriptor{}
+	offset := 0
+
+	b := data[offset]
+	p.I = (b>>7)&0x01 == 1
+	p.P = (b>>6)&0x01 == 1
+	p.L = (b>>5)&0x01 == 1
+	p.F = (b>>4)&0x01 == 1
+	p.B = (b>>3)&0x01 == 1
+	p.E = (b>>2)&0x01 == 1
+	p.V = (b>>1)&0x01 == 1
+	p.Z = b&0x01 == 1
+
+	// Picture ID.
+	if p.I {
+		offset++
+		if len(data) < offset+1 {
+			return nil
+		}
+
+		b = data[offset]
+
+		// M bit set, 15 bits picture id.
+		if (b>>7)&0x01 == 1 {
+			offset++
+			if len(data) < offset+1 {
+				return nil
+			}
+
+			p.HasTwoBytesPictureId = true
+			p.PictureId = uint16(b&0x7F)<<8 | uint16(data[offset])
+		} else {
+			p.HasOneBytePictureId = true
+			p.PictureId = uint16(b & 0x7F)
+		}
+
+		p.HasPictureId = true
+	}
+
+	// Layer indices.
+	if p.L {
+		offset++
+		if len(data) < offset+1 {
+			return nil
+		}
+
+		b = data[offset]
+		p.TlIndex = (b >> 5) & 0x07
+		p.SwitchingUpPoint = (b>>4)&0x01 == 1
+		p.SlIndex = (b >> 1) & 0x07
+		p.InterLayerDependency = b&0x01 == 1
+		p.HasTlIndex = true
+		p.HasSlIndex = true
+
+		// TL0PICIDX is only present in non-flexible mode.
+		if !p.F {
+			offset++
+			if len(data) < offset+1 {
+				return nil
+			}
+
+			p.Tl0PictureIndex = data[offset]
+			p.HasTl0PictureIndex = true
+		}
+	}
+
+	// Reference indices, only present in flexible mode.
+	if p.P && p.F {
+		for n := true; n; {
+			// At most 3 reference indices.
+			if len(p.PDiffs) == 3 {
+				return nil
+			}
+
+			offset++
+			if len(data) < offset+1 {
+				return nil
+			}
+
+			b = data[offset]
+			p.PDiffs = append(p.PDiffs, b>>1)
+			n = b&0x01 == 1
+		}
+	}
+
+	// Scalability structure.
+	if p.V {
+		offset++
+		if len(data) < offset+1 {
+			return nil
+		}
+
+		b = data[offset]
+		ss := &VP9ScalabilityStructure{
+			SpatialLayers: (b >> 5) + 1,
+		}
+		hasResolutions := (b>>4)&0x01 == 1
+		hasPictureGroups := (b>>3)&0x01 == 1
+
+		if hasResolutions {
+			if len(data) < offset+1+4*int(ss.SpatialLayers) {
+				return nil
+			}
+
+			for i := 0; i < int(ss.SpatialLayers); i++ {
+				ss.Widths = append(ss.Widths, binary.BigEndian.Uint16(data[offset+1:]))
+				ss.Heights = append(ss.Heights, binary.BigEndian.Uint16(data[offset+3:]))
+				offset += 4
+			}
+		}
+
+		if hasPictureGroups {
+			offset++
+			if len(data) < offset+1 {
+				return nil
+			}
+
+			numPictureGroups := int(data[offset])
+
+			for i := 0; i < numPictureGroups; i++ {
+				offset++
+				if len(data) < offset+1 {
+					return nil
+				}
+
+				b = data[offset]
+				pictureGroup := VP9PictureGroup{
+					TemporalLayer:    (b >> 5) & 0x07,
+					SwitchingUpPoint: (b>>4)&0x01 == 1,
+				}
+				numRefs := int((b >> 2) & 0x03)
+
+				if len(data) < offset+1+numRefs {
+					return nil
+				}
+
+				pictureGroup.PDiffs = append(pictureGroup.PDiffs, data[offset+1:offset+1+numRefs]...)
+				offset += numRefs
+
+				ss.PictureGroups = append(ss.PictureGroups, pictureGroup)
+			}
+		}
+
+		p.Ss = ss
+	}
+
+	// Detect key frame: start of a non inter-picture predicted frame in the
+	// base spatial layer.
+	if !p.P && p.B && p.SlIndex == 0 {
+		p.IsKeyFrame = true
+	}
+
+	return p
+}
+
+func (p *VP9PayloadDescriptor) Dump() {
+	slog.Debug("VP9PayloadDescriptor",
+		"i", p.I,
+		"p", p.P,
+		"l", p.L,
+		"f", p.F,
+		"b", p.B,
+		"e", p.E,
+		"v", p.V,
+		"z", p.Z,
+		"pictureId", p.PictureId,
+		"slIndex", p.SlIndex,
+		"tlIndex", p.TlIndex,
+		"tl0PictureIndex", p.Tl0PictureIndex,
+		"switchingUpPoint", p.SwitchingUpPoint,
+		"interLayerDependency", p.InterLayerDependency,
+		"pDiffs", p.PDiffs,
+		"isKeyFrame", p.IsKeyFrame,
+		"hasPictureId", p.HasPictureId,
+		"hasOneBytePictureId", p.HasOneBytePictureId,
+		"hasTwoBytesPictureId", p.HasTwoBytesPictureId,
+		"hasTl0PictureIndex", p.HasTl0PictureIndex,
+		"hasSlIndex", p.HasSlIndex,
+		"hasTlIndex", p.HasTlIndex,
+	)
+}
+
+// VP9PayloadDescriptorHandler decides whether VP9 packets are forwarded
+// according to the spatial and temporal layers of the encoding context.
+type VP9PayloadDescriptorHandler struct {
+	payloadDescriptor *VP9PayloadDescriptor
+}
+
+func NewVP9PayloadDescriptorHandler(payloadDescriptor *VP9PayloadDescriptor) *VP9PayloadDescriptorHandler {
+	return &VP9PayloadDescriptorHandler{
+		payloadDescriptor: payloadDescriptor,
+	}
+}
+
+func (h *VP9PayloadDescriptorHandler) Dump() {
+	h.payloadDescriptor.Dump()
+}
+
+// Process switches the current layers of the context towards the target ones
+// when the packet allows it, and returns ok as false if the packet must be
+// dropped. The marker is set on the last packet of the highest forwarded
+// spatial layer.
+func (h *VP9PayloadDescriptorHandler) Process(context *EncodingContext, data []byte) (marker, ok bool) {
+	p := h.payloadDescriptor
+
+	packetSpatialLayer := int16(h.GetSpatialLayer())
+	packetTemporalLayer := int16(h.GetTemporalLayer())
+	tmpSpatialLayer := context.GetCurrentSpatialLayer()
+	tmpTemporalLayer := context.GetCurrentTemporalLayer()
+
+	// If packet spatial or temporal layer is higher than maximum announced one,
+	// drop the packet.
+	if packetSpatialLayer >= int16(context.GetSpatialLayers()) ||
+		packetTemporalLayer >= int16(context.GetTemporalLayers()) {
+		slog.Warn("too high packet layers",
+			"spatialLayer", packetSpatialLayer, "temporalLayer", packetTemporalLayer)
+		return false, false
+	}
+
+	hasPictureIdManager := context.pictureIdManager != nil && p.HasPictureId
+
+	// Check whether pictureId sync is required.
+	if hasPictureIdManager && context.syncRequired {
+		context.pictureIdManager.Sync(p.PictureId - 1)
+		context.syncRequired = false
+	}
+
+	isOldPacket := hasPictureIdManager &&
+		isSeqLowerThan(p.PictureId, context.pictureIdManager.GetMaxInput(), 15)
+
+	if context.GetTargetSpatialLayer() > context.GetCurrentSpatialLayer() {
+		// Upgrade current spatial layer if needed.
+		if p.IsKeyFrame {
+			tmpSpatialLayer = context.GetTargetSpatialLayer()
+			tmpTemporalLayer = 0 // Just in case.
+		}
+	} else if context.GetTargetSpatialLayer() < context.GetCurrentSpatialLayer() {
+		// Downgrade current spatial layer if needed.
+		if context.IsKSvc() {
+			// In K-SVC we must wait for a keyframe.
+			if p.IsKeyFrame {
+				tmpSpatialLayer = context.GetTargetSpatialLayer()
+				tmpTemporalLayer = 0 // Just in case.
+			}
+		} else if packetSpatialLayer == context.GetTargetSpatialLayer() && p.E {
+			// In full SVC we do not need a keyframe.
+			tmpSpatialLayer = context.GetTargetSpatialLayer()
+			tmpTemporalLayer = 0 // Just in case.
+		}
+	}
+
+	// Filter spatial layers higher than current one (or old packet).
+	if packetSpatialLayer > tmpSpatialLayer && !isOldPacket {
+		return false, false
+	}
+
+	// Check and handle temporal layer (unless old packet).
+	if !isOldPacket {
+		if context.GetTargetTemporalLayer() > context.GetCurrentTemporalLayer() {
+			// Upgrade current temporal layer if needed.
+			if packetTemporalLayer >= context.GetCurrentTemporalLayer()+1 &&
+				(context.GetCurrentTemporalLayer() == -1 || p.SwitchingUpPoint) &&
+				p.E {
+				tmpTemporalLayer = packetTemporalLayer
+			}
+		} else if context.GetTargetTemporalLayer() < context.GetCurrentTemporalLayer() {
+			// Downgrade current temporal layer if needed.
+			if packetTemporalLayer == context.GetTargetTemporalLayer() && p.E {
+				tmpTemporalLayer = context.GetTargetTemporalLayer()
+			}
+		}
+
+		// Filter temporal layers higher than current one.
+		if packetTemporalLayer > tmpTemporalLayer {
+			return false, false
+		}
+	}
+
+	// Set marker bit if needed.
+	if packetSpatialLayer == tmpSpatialLayer && p.E {
+		marker = true
+	}
+
+	// Update the pictureId manager.
+	if hasPictureIdManager {
+		context.pictureIdManager.Input(p.PictureId)
+	}
+
+	// Update current layers if needed.
+	if tmpSpatialLayer != context.GetCurrentSpatialLayer() {
+		context.SetCurrentSpatialLayer(tmpSpatialLayer)
+	}
+	if tmpTemporalLayer != context.GetCurrentTemporalLayer() {
+		context.SetCurrentTemporalLayer(tmpTemporalLayer)
+	}
+
+	return marker, true
+}
+
+func (h *VP9PayloadDescriptorHandler) Restore(data []byte) {}
+
+func (h *VP9PayloadDescriptorHandler) GetSpatialLayer() uint8 {
+	if h.payloadDescriptor.HasSlIndex {
+		return h.payloadDescriptor.SlIndex
+	}
+	return 0
+}
+
+func (h *VP9PayloadDescriptorHandler) GetTemporalLayer() uint8 {
+	if h.payloadDescriptor.HasTlIndex {
+		return h.payloadDescriptor.TlIndex
+	}
+	return 0
+}
+
+func (h *VP9PayloadDescriptorHandler) IsKeyFrame() bool {
+	return h.payloadDescriptor.IsKeyFrame
+}
@@ -0,0 +1,182 @@
+package codecs_test
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+type testVP9Packet struct {
+	pictureId        uint16
+	spatialLayer     uint8
+	temporalLayer    uint8
+	interPicture     bool
+	switchingUpPoint bool
+}
+
+// payload returns a non flexible mode single packet frame.
+func (p testVP9Packet) payload() []byte {
+	b := byte(0x80 | 0x20 | 0x08 | 0x04)
+	if p.interPicture {
+		b |= 0x40
+	}
+	layers := p.temporalLayer<<5 | p.spatialLayer<<1
+	if p.switchingUpPoint {
+		layers |= 0x10
+	}
+	return []byte{b, 0x80 | byte(p.pictureId>>8), byte(p.pictureId), layers, 0x00, 0xaa, 0xbb}
+}
+
+func createVP9EncodingContext(ksvc bool) *codecs.EncodingContext {
+	context := codecs.NewEncodingContext(codecs.EncodingContextParams{SpatialLayers: 3, TemporalLayers: 3, Ksvc: ksvc},
+		codecs.WithPictureIdManager(rtc.NewSeqManager[uint16](15)),
+	)
+	context.SetTargetSpatialLayer(2)
+	context.SetTargetTemporalLayer(2)
+	context.SyncRequired()
+	return context
+}
+
+func processVP9(t *testing.T, context *codecs.EncodingContext, packet testVP9Packet) (marker, ok bool) {
+	data := packet.payload()
+	handler := codecs.GetPayloadDescriptorHandler("video/VP9", TestPacket{payload: data})
+	require.NotNil(t, handler)
+	return handler.Process(context, data)
+}
+
+func TestVP9(t *testing.T) {
+	t.Run("parses non flexible mode payload descriptor", func(t *testing.T) {
+		payloadDescriptor := codecs.ParseVP9([]byte{0xac, 0x81, 0x02, 0x53, 0x07, 0xff})
+		require.NotNil(t, payloadDescriptor)
+
+		require.True(t, payloadDescriptor.I)
+		require.False(t, payloadDescriptor.P)
+		require.True(t, payloadDescriptor.B)
+		require.True(t, payloadDescriptor.E)
+		require.True(t, payloadDescriptor.HasTwoBytesPictureId)
+		require.EqualValues(t, 0x0102, payloadDescriptor.PictureId)
+		require.EqualValues(t, 2, payloadDescriptor.TlIndex)
+		require.True(t, payloadDescriptor.SwitchingUpPoint)
+		require.EqualValues(t, 1, payloadDescriptor.SlIndex)
+		require.True(t, payloadDescriptor.InterLayerDependency)
+		require.True(t, payloadDescriptor.HasTl0PictureIndex)
+		require.EqualValues(t, 7, payloadDescriptor.Tl0PictureIndex)
+
+		// Not in the base spatial layer.
+		require.False(t, payloadDescriptor.IsKeyFrame)
+	})
+
+	t.Run("parses flexible mode reference indices", func(t *testing.T) {
+		payloadDescriptor := codecs.ParseVP9([]byte{0xf8, 0x05, 0x20, 0x03, 0x04, 0xff})
+		require.NotNil(t, payloadDescriptor)
+
+		require.True(t, payloadDescriptor.F)
+		require.True(t, payloadDescriptor.HasOneBytePictureId)
+		require.EqualValues(t, 5, payloadDescriptor.PictureId)
+		require.False(t, payloadDescriptor.HasTl0PictureIndex)
+		require.Equal(t, []uint8{1, 2}, payloadDescriptor.PDiffs)
+		require.False(t, payloadDescriptor.IsKeyFrame)
+
+		// More than 3 reference indices.
+		require.Nil(t, codecs.ParseVP9([]byte{0xf8, 0x05, 0x20, 0x03, 0x03, 0x03, 0x03}))
+	})
+
+	t.Run("parses the scalability structure", func(t *testing.T) {
+		payloadDescriptor := codecs.ParseVP9([]byte{
+			0x0a,
+			0x38,                   // N_S=2, Y, G.
+			0x01, 0x40, 0x00, 0xb4, // 320x180.
+			0x02, 0x80, 0x01, 0x68, // 640x360.
+			0x02,       // N_G=2.
+			0x04, 0x01, // T=0, R=1.
+			0x30, // T=1, U.
+			0xff,
+		})
+		require.NotNil(t, payloadDescriptor)
+		require.True(t, payloadDescriptor.IsKeyFrame)
+
+		ss := payloadDescriptor.Ss
+		require.NotNil(t, ss)
+		require.EqualValues(t, 2, ss.SpatialLayers)
+		require.Equal(t, []uint16{320, 640}, ss.Widths)
+		require.Equal(t, []uint16{180, 360}, ss.Heights)
+		require.Equal(t, []codecs.VP9PictureGroup{
+			{TemporalLayer: 0, PDiffs: []uint8{1}},
+			{TemporalLayer: 1, SwitchingUpPoint: true},
+		}, ss.PictureGroups)
+	})
+
+	t.Run("rejects truncated payload descriptors", func(t *testing.T) {
+		require.Nil(t, codecs.ParseVP9(nil))
+		require.Nil(t, codecs.ParseVP9([]byte{0x80, 0x80}))
+		require.Nil(t, codecs.ParseVP9([]byte{0x20, 0x00}))
+		require.Nil(t, codecs.ParseVP9([]byte{0x02, 0x10, 0x01}))
+	})
+
+	t.Run("full svc switches spatial layers up on key frames and down on frame end", func(t *testing.T) {
+		context := createVP9EncodingContext(false)
+
+		// Key frame.
+		for sl, expectedMarker := range []bool{false, false, true} {
+			marker, ok := processVP9(t, context, testVP9Packet{pictureId: 1, spatialLayer: uint8(sl)})
+			require.True(t, ok)
+			require.Equal(t, expectedMarker, marker)
+		}
+		require.EqualValues(t, 2, context.GetCurrentSpatialLayer())
+		require.EqualValues(t, 0, context.GetCurrentTemporalLayer())
+
+		// Temporal layer upgrade on switching up point.
+		for sl := uint8(0); sl < 3; sl++ {
+			_, ok := processVP9(t, context, testVP9Packet{pictureId: 2, spatialLayer: sl, temporalLayer: 1, interPicture: true, switchingUpPoint: true})
+			require.True(t, ok)
+		}
+		require.EqualValues(t, 1, context.GetCurrentTemporalLayer())
+
+		// Spatial downgrade does not need a key frame.
+		context.SetTargetSpatialLayer(0)
+		context.SetTargetTemporalLayer(0)
+
+		marker, ok := processVP9(t, context, testVP9Packet{pictureId: 3, spatialLayer: 0, interPicture: true})
+		require.True(t, ok)
+		require.True(t, marker)
+
+		_, ok = processVP9(t, context, testVP9Packet{pictureId: 3, spatialLayer: 1, interPicture: true})
+		require.False(t, ok)
+
+		_, ok = processVP9(t, context, testVP9Packet{pictureId: 4, spatialLayer: 0, temporalLayer: 1, interPicture: true})
+		require.False(t, ok)
+
+		require.EqualValues(t, 0, context.GetCurrentSpatialLayer())
+		require.EqualValues(t, 0, context.GetCurrentTemporalLayer())
+	})
+
+	t.Run("k-svc waits for a key frame to switch spatial layer down", func(t *testing.T) {
+		context := createVP9EncodingContext(true)
+
+		for sl := uint8(0); sl < 3; sl++ {
+			processVP9(t, context, testVP9Packet{pictureId: 1, spatialLayer: sl})
+		}
+		require.EqualValues(t, 2, context.GetCurrentSpatialLayer())
+
+		context.SetTargetSpatialLayer(0)
+
+		marker, ok := processVP9(t, context, testVP9Packet{pictureId: 2, spatialLayer: 0, interPicture: true})
+		require.True(t, ok)
+		require.False(t, marker)
+		require.EqualValues(t, 2, context.GetCurrentSpatialLayer())
+
+		marker, ok = processVP9(t, context, testVP9Packet{pictureId: 3, spatialLayer: 0})
+		require.True(t, ok)
+		require.True(t, marker)
+		require.EqualValues(t, 0, context.GetCurrentSpatialLayer())
+	})
+
+	t.Run("drops packets with layers above the announced ones", func(t *testing.T) {
+		context := createVP9EncodingContext(false)
+
+		_, ok := processVP9(t, context, testVP9Packet{pictureId: 1, spatialLayer: 3})
+		require.False(t, ok)
+	})
+}
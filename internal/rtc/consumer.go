@@ -0,0 +1,297 @@
+package rtc
+
+import (
+	"log/slog"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+type ConsumerType string
+
+const (
+	ConsumerTypeSimple    ConsumerType = "simple"
+	ConsumerTypeSimulcast ConsumerType = "simulcast"
+	ConsumerTypeSvc       ConsumerType = "svc"
+	ConsumerTypePipe      ConsumerType = "pipe"
+)
+
+type ConsumerListener interface {
+	OnConsumerSendRtpPacket(consumer Consumer, packet *RtpPacket)
+	// OnConsumerRetransmitRtpPacket is called with a packet resent in response
+	// to a NACK, RTX encoded if the consumer uses RTX.
+	OnConsumerRetransmitRtpPacket(consumer Consumer, packet *RtpPacket)
+	OnConsumerKeyFrameRequested(consumer Consumer, mappedSsrc uint32)
+	// OnConsumerScore is called when the score of the consumer or of the
+	// Producer streams it forwards changes.
+	OnConsumerScore(consumer Consumer, score ConsumerScore)
+	// OnConsumerNeedBitrateChange is called when a consumer whose bitrate is
+	// externally managed needs the available bitrate to be redistributed.
+	OnConsumerNeedBitrateChange(consumer Consumer)
+	// OnConsumerNeedZeroBitrate is called when a consumer whose bitrate is
+	// externally managed stops sending.
+	OnConsumerNeedZeroBitrate(consumer Consumer)
+}
+
+// ConsumerScore holds the 0 to 10 quality scores of a Consumer.
+type ConsumerScore struct {
+	// Score is the score of the stream sent to the remote endpoint.
+	Score uint8
+	// ProducerScore is the score of the Producer stream being forwarded.
+	ProducerScore uint8
+	// ProducerScores are the scores of all the Producer streams, zero for the
+	// ones not received yet.
+	ProducerScores []uint8
+}
+
+type ConsumerLayers struct {
+	SpatialLayer  uint8
+	TemporalLayer uint8
+}
+
+type ConsumerOptions struct {
+	ProducerId string
+	Kind       MediaKind
+
+	// RtpParameters are the parameters used to send the stream to the remote
+	// endpoint.
+	RtpParameters RtpParameters
+
+	// ConsumableRtpEncodings are the encodings of the Producer, with their
+	// mapped ssrcs.
+	ConsumableRtpEncodings []RtpEncodingParameters
+
+	Paused          bool
+	ProducerPaused  bool
+	PreferredLayers *ConsumerLayers
+	IgnoreDtx       bool
+}
+
+// Consumer sends the media of a Producer to a remote endpoint. Methods are not
+// safe for concurrent use; the owning transport serializes calls.
+type Consumer interface {
+	Id() string
+	ProducerId() string
+	Kind() MediaKind
+	Type() ConsumerType
+	GetRtpParameters() RtpParameters
+	GetMediaSsrcs() []uint32
+	GetRtxSsrcs() []uint32
+	IsActive() bool
+	IsPaused() bool
+	IsProducerPaused() bool
+	Pause()
+	Resume()
+	ProducerPaused()
+	ProducerResumed()
+	ProducerClosed()
+	ProducerRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32)
+	ProducerNewRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32)
+	// ProducerRtpStreamScore must be called when the score of a Producer
+	// stream changes.
+	ProducerRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8)
+	SendRtpPacket(packet *RtpPacket)
+	ReceiveNack(nackPacket *rtcp.Nack)
+	ReceiveRtcpReceiverReport(report *rtcp.ReceptionReport)
+	// GetRtcp returns the SR and SDES packets of the streams that sent media.
+	GetRtcp(nowMs uint64) []rtcp.Packet
+	RequestKeyFrame()
+	GetScore() ConsumerScore
+	GetStats() []RtpStreamStats
+	Close()
+}
+
+// LayeredConsumer is a Consumer switching between the layers of the Producer,
+// whose bitrate can be managed by the transport.
+type LayeredConsumer interface {
+	Consumer
+	// SetExternallyManagedBitrate lets the transport choose the layers with
+	// IncreaseLayer and ApplyLayers.
+	SetExternallyManagedBitrate()
+	SetPriority(priority uint8)
+	// GetBitratePriority returns 0 if the consumer does not need any bitrate.
+	GetBitratePriority() uint8
+	IncreaseLayer(bitrate uint32) uint32
+	ApplyLayers()
+	GetDesiredBitrate() uint32
+	// GetRtxPaddingPacket returns a padding-only RTX packet to probe for more
+	// available bitrate with, or nil if the consumer cannot send any.
+	GetRtxPaddingPacket(paddingSize uint8) *RtpPacket
+}
+
+// consumer holds the state shared by all Consumer implementations.
+type consumer struct {
+	id                         string
+	producerId                 string
+	kind                       MediaKind
+	typ                        ConsumerType
+	rtpParameters              RtpParameters
+	consumableRtpEncodings     []RtpEncodingParameters
+	listener                   ConsumerListener
+	paused                     bool
+	producerPaused             bool
+	producerClosed             bool
+	supportedCodecPayloadTypes map[uint8]struct{}
+	mediaSsrcs                 []uint32
+	rtxSsrcs                   []uint32
+	headerExtensionIds         RtpHeaderExtensionIds
+	logger                     *slog.Logger
+}
+
+func newConsumer(typ ConsumerType, id string, listener ConsumerListener, options *ConsumerOptions) *consumer {
+	c := &consumer{
+		id:                         id,
+		producerId:                 options.ProducerId,
+		kind:                       options.Kind,
+		typ:                        typ,
+		rtpParameters:              options.RtpParameters,
+		consumableRtpEncodings:     options.ConsumableRtpEncodings,
+		listener:                   listener,
+		paused:                     options.Paused,
+		producerPaused:             options.ProducerPaused,
+		supportedCodecPayloadTypes: make(map[uint8]struct{}),
+		headerExtensionIds:         NewRtpHeaderExtensionIds(options.RtpParameters.HeaderExtensions),
+		logger:                     slog.Default().With("typename", "Consumer", "type", typ, "id", id),
+	}
+
+	for _, codec := range c.rtpParameters.Codecs {
+		if !IsRtxMimeType(codec.MimeType) {
+			c.supportedCodecPayloadTypes[codec.PayloadType] = struct{}{}
+		}
+	}
+
+	for _, encoding := range c.rtpParameters.Encodings {
+		c.mediaSsrcs = append(c.mediaSsrcs, encoding.Ssrc)
+		if encoding.Rtx != nil && encoding.Rtx.Ssrc != 0 {
+			c.rtxSsrcs = append(c.rtxSsrcs, encoding.Rtx.Ssrc)
+		}
+	}
+
+	return c
+}
+
+func (c *consumer) Id() string {
+	return c.id
+}
+
+func (c *consumer) ProducerId() string {
+	return c.producerId
+}
+
+func (c *consumer) Kind() MediaKind {
+	return c.kind
+}
+
+func (c *consumer) Type() ConsumerType {
+	return c.typ
+}
+
+func (c *consumer) GetRtpParameters() RtpParameters {
+	return c.rtpParameters
+}
+
+func (c *consumer) GetMediaSsrcs() []uint32 {
+	return c.mediaSsrcs
+}
+
+func (c *consumer) GetRtxSsrcs() []uint32 {
+	return c.rtxSsrcs
+}
+
+func (c *consumer) IsActive() bool {
+	return !c.paused && !c.producerPaused && !c.producerClosed
+}
+
+func (c *consumer) IsPaused() bool {
+	return c.paused
+}
+
+func (c *consumer) IsProducerPaused() bool {
+	return c.producerPaused
+}
+
+func (c *consumer) isSupportedPayloadType(payloadType uint8) bool {
+	_, ok := c.supportedCodecPayloadTypes[payloadType]
+	return ok
+}
+
+// pause returns false if the consumer was already paused.
+func (c *consumer) pause() bool {
+	if c.paused {
+		return false
+	}
+	c.paused = true
+	return true
+}
+
+// resume returns false if the consumer was not paused.
+func (c *consumer) resume() bool {
+	if !c.paused {
+		return false
+	}
+	c.paused = false
+	return true
+}
+
+// producerPause returns false if the producer was already paused.
+func (c *consumer) producerPause() bool {
+	if c.producerPaused {
+		return false
+	}
+	c.producerPaused = true
+	return true
+}
+
+// producerResume returns false if the producer was not paused.
+func (c *consumer) producerResume() bool {
+	if !c.producerPaused {
+		return false
+	}
+	c.producerPaused = false
+	return true
+}
+
+func (c *consumer) ProducerClosed() {
+	c.producerClosed = true
+}
+
+// createRtpStreamParams returns the parameters of the sending stream for the
+// given encoding of the consumer.
+func (c *consumer) createRtpStreamParams(encodingIdx int) RtpStreamParams {
+	encoding := c.rtpParameters.Encodings[encodingIdx]
+	mediaCodec := c.rtpParameters.GetCodecForEncoding(encoding)
+
+	return newRtpStreamParams(&c.rtpParameters, encodingIdx, mediaCodec)
+}
+
+// getRtcpPackets returns a SR for each given stream that sent media, followed
+// by a SDES packet carrying their CNAMEs.
+func getRtcpPackets(nowMs uint64, rtpStreams ...*RtpStreamSend) []rtcp.Packet {
+	var packets []rtcp.Packet
+
+	sdes := &rtcp.SourceDescription{}
+
+	for _, rtpStream := range rtpStreams {
+		report := rtpStream.GetRtcpSenderReport(nowMs)
+		if report == nil {
+			continue
+		}
+		packets = append(packets, report)
+		sdes.Chunks = append(sdes.Chunks, rtpStream.GetRtcpSdesChunk())
+	}
+
+	if len(sdes.Chunks) > 0 {
+		packets = append(packets, sdes)
+	}
+
+	return packets
+}
+
+// newEncodingContext creates an encoding context able to rewrite the picture
+// ids of the payload descriptors.
+func newEncodingContext(params codecs.EncodingContextParams) *codecs.EncodingContext {
+	return codecs.NewEncodingContext(params,
+		codecs.WithPictureIdManager(NewSeqManager[uint16](15)),
+		codecs.WithTl0PictureIndexManager(NewSeqManager[uint8]()),
+	)
+}
@@ -0,0 +1,136 @@
+package rtc
+
+import "math"
+
+const (
+	// bweBurstDeltaMs groups the packets sent within this time into a single
+	// packet group.
+	bweBurstDeltaMs = 5
+	// bweIncreaseFactor is the multiplicative increase per second of an
+	// estimate while the network is not congested.
+	bweIncreaseFactor = 1.08
+	// bweDecreaseFactor is applied to the incoming bitrate when the network
+	// is overused.
+	bweDecreaseFactor = 0.85
+	// bweMinDecreaseIntervalMs is the minimum time between two decreases of
+	// an estimate.
+	bweMinDecreaseIntervalMs = 300
+)
+
+// bwePacketGroup holds the packets sent within a burst.
+type bwePacketGroup struct {
+	firstSendTimeMs float64
+	lastSendTimeMs  float64
+	lastArrivalMs   float64
+}
+
+// DelayBasedBwe estimates the available bitrate from the one-way delay
+// variations between groups of packets: the trendline overuse detector drives
+// an additive increase multiplicative decrease rate control. Send and arrival
+// times only need to be consistent within each clock.
+type DelayBasedBwe struct {
+	trendline      *TrendlineEstimator
+	currentGroup   *bwePacketGroup
+	previousGroup  *bwePacketGroup
+	bitrate        float64
+	lastUpdateMs   uint64
+	lastDecreaseMs uint64
+}
+
+func NewDelayBasedBwe(initialBitrate uint32) *DelayBasedBwe {
+	return &DelayBasedBwe{
+		trendline: NewTrendlineEstimator(),
+		bitrate:   float64(initialBitrate),
+	}
+}
+
+// IncomingPacket groups the packets sent in bursts and feeds the delay
+// variation between consecutive groups to the trendline estimator.
+func (d *DelayBasedBwe) IncomingPacket(sendTimeMs, arrivalTimeMs float64) {
+	if d.currentGroup == nil {
+		d.currentGroup = &bwePacketGroup{
+			firstSendTimeMs: sendTimeMs,
+			lastSendTimeMs:  sendTimeMs,
+			lastArrivalMs:   arrivalTimeMs,
+		}
+		return
+	}
+
+	// Reordered packet.
+	if sendTimeMs < d.currentGroup.firstSendTimeMs {
+		return
+	}
+
+	if sendTimeMs-d.currentGroup.firstSendTimeMs <= bweBurstDeltaMs {
+		d.currentGroup.lastSendTimeMs = max(d.currentGroup.lastSendTimeMs, sendTimeMs)
+		d.currentGroup.lastArrivalMs = max(d.currentGroup.lastArrivalMs, arrivalTimeMs)
+		return
+	}
+
+	// The current group is complete.
+	if d.previousGroup != nil {
+		d.trendline.Update(
+			d.currentGroup.lastArrivalMs-d.previousGroup.lastArrivalMs,
+			d.currentGroup.lastSendTimeMs-d.previousGroup.lastSendTimeMs,
+			d.currentGroup.lastArrivalMs)
+	}
+
+	d.previousGroup = d.currentGroup
+	d.currentGroup = &bwePacketGroup{
+		firstSendTimeMs: sendTimeMs,
+		lastSendTimeMs:  sendTimeMs,
+		lastArrivalMs:   arrivalTimeMs,
+	}
+}
+
+// Update runs the rate control with the state of the overuse detector and
+// returns the new estimate. The incoming bitrate is the one the packets were
+// received at, zero if not known yet. A zero initial estimate starts at the
+// first known incoming bitrate.
+func (d *DelayBasedBwe) Update(incomingBitrate float64, nowMs uint64) float64 {
+	if d.bitrate == 0 {
+		d.bitrate = incomingBitrate
+		d.lastUpdateMs = nowMs
+		return d.bitrate
+	}
+
+	switch d.trendline.State() {
+	case BandwidthUsageOverusing:
+		if nowMs-d.lastDecreaseMs >= bweMinDecreaseIntervalMs {
+			bitrate := d.bitrate
+			if incomingBitrate > 0 {
+				bitrate = incomingBitrate
+			}
+			d.bitrate = min(d.bitrate, bweDecreaseFactor*bitrate)
+			d.lastDecreaseMs = nowMs
+		}
+	// Let the queues drain.
+	case BandwidthUsageUnderusing:
+	case BandwidthUsageNormal:
+		if d.lastUpdateMs != 0 {
+			d.bitrate = increaseBitrate(d.bitrate, incomingBitrate, nowMs-d.lastUpdateMs)
+		}
+	}
+
+	d.lastUpdateMs = nowMs
+
+	return d.bitrate
+}
+
+func (d *DelayBasedBwe) GetBitrate() float64 {
+	return d.bitrate
+}
+
+func (d *DelayBasedBwe) State() BandwidthUsage {
+	return d.trendline.State()
+}
+
+// increaseBitrate returns the bitrate increased for the elapsed time, never
+// beyond one and a half times the incoming bitrate unless it already was.
+func increaseBitrate(bitrate, incomingBitrate float64, elapsedMs uint64) float64 {
+	increased := bitrate * math.Pow(bweIncreaseFactor, float64(min(elapsedMs, 1000))/1000)
+	if incomingBitrate > 0 {
+		increased = min(increased, max(bitrate, 1.5*incomingBitrate+10000))
+	}
+	return increased
+}
@@ -20,6 +20,8 @@ type TestNackGeneratorInput struct {
 
 type TestPayloadDescriptorHandler struct {
 	isKeyFrame bool
+	// restored counts the calls to Restore().
+	restored int
 }
 
 func NewTestPayloadDescriptorHandler(isKeyFrame bool) *TestPayloadDescriptorHandler {
@@ -32,7 +34,9 @@ func (h *TestPayloadDescriptorHandler) Process(context *codecs.EncodingContext,
 	return true, true
 }
 
-func (h *TestPayloadDescriptorHandler) Restore(data []byte) {}
+func (h *TestPayloadDescriptorHandler) Restore(data []byte) {
+	h.restored++
+}
 
 func (h *TestPayloadDescriptorHandler) GetSpatialLayer() uint8 {
 	return 0
@@ -0,0 +1,259 @@
+package rtc
+
+import (
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+// PipeConsumer forwards every encoding of a Producer unchanged, so that it can
+// be produced again on another router.
+type PipeConsumer struct {
+	*consumer
+	rtpStreams                []*RtpStreamSend
+	mapMappedSsrcSsrc         map[uint32]uint32
+	mapSsrcRtpStream          map[uint32]*RtpStreamSend
+	mapRtpStreamSyncRequired  map[*RtpStreamSend]bool
+	mapRtpStreamRtpSeqManager map[*RtpStreamSend]*SeqManager[uint16]
+	keyFrameSupported         bool
+}
+
+func NewPipeConsumer(id string, listener ConsumerListener, options *ConsumerOptions) *PipeConsumer {
+	c := &PipeConsumer{
+		consumer:                  newConsumer(ConsumerTypePipe, id, listener, options),
+		mapMappedSsrcSsrc:         make(map[uint32]uint32),
+		mapSsrcRtpStream:          make(map[uint32]*RtpStreamSend),
+		mapRtpStreamSyncRequired:  make(map[*RtpStreamSend]bool),
+		mapRtpStreamRtpSeqManager: make(map[*RtpStreamSend]*SeqManager[uint16]),
+	}
+
+	// The consumer encodings must match the consumable ones one by one.
+	if len(c.rtpParameters.Encodings) != len(c.consumableRtpEncodings) {
+		c.logger.Warn("number of rtpParameters.encodings and consumableRtpEncodings do not match",
+			"encodings", len(c.rtpParameters.Encodings), "consumableEncodings", len(c.consumableRtpEncodings))
+	}
+
+	for idx := 0; idx < min(len(c.rtpParameters.Encodings), len(c.consumableRtpEncodings)); idx++ {
+		params := c.createRtpStreamParams(idx)
+		rtpStream := NewRtpStreamSend(c, params)
+
+		if c.IsPaused() || c.IsProducerPaused() {
+			rtpStream.Pause()
+		}
+
+		c.rtpStreams = append(c.rtpStreams, rtpStream)
+		c.mapMappedSsrcSsrc[c.consumableRtpEncodings[idx].Ssrc] = params.Ssrc
+		c.mapSsrcRtpStream[params.Ssrc] = rtpStream
+		c.mapRtpStreamSyncRequired[rtpStream] = true
+		c.mapRtpStreamRtpSeqManager[rtpStream] = NewSeqManager[uint16]()
+
+		if idx == 0 {
+			c.keyFrameSupported = codecs.CanBeKeyFrame(params.MimeType)
+		}
+	}
+
+	return c
+}
+
+func (c *PipeConsumer) Pause() {
+	if !c.pause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *PipeConsumer) Resume() {
+	if !c.resume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *PipeConsumer) ProducerPaused() {
+	if !c.producerPause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *PipeConsumer) ProducerResumed() {
+	if !c.producerResume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *PipeConsumer) ProducerRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	// Do nothing.
+}
+
+func (c *PipeConsumer) ProducerNewRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	// Do nothing.
+}
+
+func (c *PipeConsumer) ProducerRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8) {
+	// Do nothing.
+}
+
+func (c *PipeConsumer) SendRtpPacket(packet *RtpPacket) {
+	if !c.IsActive() {
+		return
+	}
+
+	// NOTE: This may happen if this Consumer supports just some codecs of those
+	// in the corresponding Producer.
+	if !c.isSupportedPayloadType(packet.PayloadType) {
+		return
+	}
+
+	ssrc, ok := c.mapMappedSsrcSsrc[packet.SSRC]
+	if !ok {
+		return
+	}
+
+	rtpStream := c.mapSsrcRtpStream[ssrc]
+	rtpSeqManager := c.mapRtpStreamRtpSeqManager[rtpStream]
+	syncRequired := c.mapRtpStreamSyncRequired[rtpStream]
+
+	// If we need to sync, support key frames and this is not a key frame, ignore
+	// the packet.
+	if syncRequired && c.keyFrameSupported && !packet.IsKeyFrame() {
+		return
+	}
+
+	// Whether this is the first packet after re-sync.
+	isSyncPacket := syncRequired
+
+	// Sync sequence number if required.
+	if isSyncPacket {
+		if packet.IsKeyFrame() {
+			c.logger.Debug("sync key frame received")
+		}
+
+		rtpSeqManager.Sync(packet.SequenceNumber - 1)
+
+		c.mapRtpStreamSyncRequired[rtpStream] = false
+	}
+
+	// Update RTP seq number based on the offset.
+	seq, ok := rtpSeqManager.Input(packet.SequenceNumber)
+	if !ok {
+		return
+	}
+
+	// Save original packet fields.
+	origSsrc := packet.SSRC
+	origSeq := packet.SequenceNumber
+
+	// Rewrite packet.
+	packet.SSRC = ssrc
+	packet.SequenceNumber = seq
+	origHeaderExtensions := packet.MapHeaderExtensions(c.headerExtensionIds, c.rtpParameters.Mid)
+
+	if isSyncPacket {
+		c.logger.Debug("sending sync packet", "ssrc", ssrc, "seq", seq, "origSeq", origSeq)
+	}
+
+	// Process the packet.
+	if rtpStream.ReceivePacket(packet) {
+		// Send the packet.
+		c.listener.OnConsumerSendRtpPacket(c, packet)
+	} else {
+		c.logger.Warn("failed to send packet", "ssrc", ssrc, "seq", seq, "origSeq", origSeq)
+	}
+
+	// Restore packet fields.
+	packet.SSRC = origSsrc
+	packet.SequenceNumber = origSeq
+	packet.RestoreHeaderExtensions(origHeaderExtensions)
+}
+
+func (c *PipeConsumer) ReceiveNack(nackPacket *rtcp.Nack) {
+	if !c.IsActive() {
+		return
+	}
+
+	// May happen that we receive a NACK for an unknown stream.
+	rtpStream, ok := c.mapSsrcRtpStream[nackPacket.MediaSsrc]
+	if !ok {
+		c.logger.Warn("no RtpStreamSend found for received NACK packet", "ssrc", nackPacket.MediaSsrc)
+		return
+	}
+
+	rtpStream.ReceiveNack(nackPacket)
+}
+
+func (c *PipeConsumer) ReceiveRtcpReceiverReport(report *rtcp.ReceptionReport) {
+	rtpStream, ok := c.mapSsrcRtpStream[report.Ssrc]
+	if !ok {
+		c.logger.Warn("no RtpStreamSend found for received RR", "ssrc", report.Ssrc)
+		return
+	}
+
+	rtpStream.ReceiveRtcpReceiverReport(report)
+}
+
+func (c *PipeConsumer) GetRtcp(nowMs uint64) []rtcp.Packet {
+	return getRtcpPackets(nowMs, c.rtpStreams...)
+}
+
+func (c *PipeConsumer) RequestKeyFrame() {
+	if c.kind != MediaKindVideo {
+		return
+	}
+
+	for _, encoding := range c.consumableRtpEncodings {
+		c.listener.OnConsumerKeyFrameRequested(c, encoding.Ssrc)
+	}
+}
+
+// GetScore returns the lowest score of the sending streams. Producer scores
+// are not tracked, the remote Producer computes its own.
+func (c *PipeConsumer) GetScore() ConsumerScore {
+	var score ConsumerScore
+
+	for idx, rtpStream := range c.rtpStreams {
+		if idx == 0 || rtpStream.GetScore() < score.Score {
+			score.Score = rtpStream.GetScore()
+		}
+	}
+
+	return score
+}
+
+func (c *PipeConsumer) GetStats() []RtpStreamStats {
+	stats := make([]RtpStreamStats, 0, len(c.rtpStreams))
+
+	for _, rtpStream := range c.rtpStreams {
+		stats = append(stats, rtpStream.GetStats())
+	}
+
+	return stats
+}
+
+func (c *PipeConsumer) Close() {}
+
+func (c *PipeConsumer) userOnPaused() {
+	for _, rtpStream := range c.rtpStreams {
+		rtpStream.Pause()
+	}
+}
+
+func (c *PipeConsumer) userOnResumed() {
+	for _, rtpStream := range c.rtpStreams {
+		c.mapRtpStreamSyncRequired[rtpStream] = true
+		rtpStream.Resume()
+	}
+
+	// Since we tell the remote Producer to start again, request a key frame.
+	if c.keyFrameSupported {
+		c.RequestKeyFrame()
+	}
+}
+
+func (c *PipeConsumer) OnRtpStreamRetransmitRtpPacket(rtpStream *RtpStreamSend, packet *RtpPacket) {
+	c.listener.OnConsumerRetransmitRtpPacket(c, packet)
+}
+
+func (c *PipeConsumer) OnRtpStreamScore(rtpStream *RtpStreamSend, score, previousScore uint8) {
+	// Do nothing.
+}
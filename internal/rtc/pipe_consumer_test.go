@@ -0,0 +1,89 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createTestPipeConsumerOptions() *ConsumerOptions {
+	return &ConsumerOptions{
+		ProducerId: "p1",
+		Kind:       MediaKindVideo,
+		RtpParameters: RtpParameters{
+			Codecs: []*RtpCodecParameters{
+				{MimeType: "video/VP8", PayloadType: 100, ClockRate: 90000},
+			},
+			Encodings: []RtpEncodingParameters{
+				{Ssrc: 6001, ScalabilityMode: "L1T3"},
+				{Ssrc: 6002, ScalabilityMode: "L1T3"},
+			},
+		},
+		ConsumableRtpEncodings: []RtpEncodingParameters{
+			{Ssrc: 9001, ScalabilityMode: "L1T3"},
+			{Ssrc: 9002, ScalabilityMode: "L1T3"},
+		},
+	}
+}
+
+func TestPipeConsumer(t *testing.T) {
+	t.Run("forwards every encoding with its own sequence numbers", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewPipeConsumer("c1", listener, createTestPipeConsumerOptions())
+
+		require.Equal(t, []uint32{6001, 6002}, consumer.GetMediaSsrcs())
+
+		// Each stream starts with a key frame.
+		packet := createTestRtpPacket(t, 9001, 100, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+
+		packet = createTestRtpPacket(t, 9002, 500, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 101, 100, nil))
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9002, 501, 100, nil))
+
+		// Unknown ssrc and unsupported payload type.
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9003, 1, 100, nil))
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 102, 111, nil))
+
+		require.Equal(t, []TestSentPacket{
+			{ssrc: 6001, seq: 1, timestamp: 100 * 3000},
+			{ssrc: 6002, seq: 1, timestamp: 500 * 3000},
+			{ssrc: 6001, seq: 2, timestamp: 101 * 3000},
+			{ssrc: 6002, seq: 2, timestamp: 501 * 3000},
+		}, listener.sentPackets)
+
+		stats := consumer.GetStats()
+		require.Len(t, stats, 2)
+		require.EqualValues(t, 2, stats[0].PacketCount)
+		require.EqualValues(t, 2, stats[1].PacketCount)
+	})
+
+	t.Run("paused consumer re-syncs every stream on resume", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewPipeConsumer("c1", listener, createTestPipeConsumerOptions())
+
+		packet := createTestRtpPacket(t, 9001, 10, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+
+		consumer.Pause()
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 11, 100, nil))
+
+		consumer.Resume()
+		require.Equal(t, []uint32{9001, 9002}, listener.keyFrameRequests)
+
+		packet = createTestRtpPacket(t, 9001, 50, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(false))
+		consumer.SendRtpPacket(packet)
+
+		packet = createTestRtpPacket(t, 9001, 51, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+
+		require.Equal(t, []uint16{1, 2}, listener.sentSeqs())
+	})
+}
@@ -0,0 +1,515 @@
+package rtc
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+type ReceiveRtpPacketResult int
+
+const (
+	ReceiveRtpPacketResultDiscarded ReceiveRtpPacketResult = iota
+	ReceiveRtpPacketResultMedia
+	ReceiveRtpPacketResultRetransmission
+)
+
+type ProducerListener interface {
+	OnProducerRtpPacketReceived(producer *Producer, packet *RtpPacket)
+	OnProducerNewRtpStream(producer *Producer, rtpStream *RtpStreamRecv, mappedSsrc uint32)
+	OnProducerPaused(producer *Producer)
+	OnProducerResumed(producer *Producer)
+	OnProducerNackRequired(producer *Producer, ssrc uint32, seqNumbers []uint16)
+	OnProducerKeyFrameRequired(producer *Producer, ssrc uint32, useFir bool)
+	// OnProducerRtpStreamScore is called when the score of a stream changes,
+	// GetScores returns the scores of all of them.
+	OnProducerRtpStreamScore(producer *Producer, rtpStream *RtpStreamRecv, score, previousScore uint8)
+}
+
+type ProducerOptions struct {
+	Kind                 MediaKind
+	RtpParameters        RtpParameters
+	RtpMapping           RtpMapping
+	KeyFrameRequestDelay time.Duration
+	Paused               bool
+}
+
+type ProducerScore struct {
+	EncodingIdx int
+	Ssrc        uint32
+	Rid         string
+	Score       uint8
+}
+
+type Producer struct {
+	id                     string
+	kind                   MediaKind
+	rtpParameters          RtpParameters
+	rtpMapping             RtpMapping
+	listener               ProducerListener
+	paused                 bool
+	mu                     sync.Mutex
+	rtpStreamByEncodingIdx []*RtpStreamRecv
+	mapSsrcRtpStream       map[uint32]*RtpStreamRecv
+	mapRtxSsrcRtpStream    map[uint32]*RtpStreamRecv
+	mapRtpStreamMappedSsrc map[*RtpStreamRecv]uint32
+	mapMappedSsrcSsrc      map[uint32]uint32
+	keyFrameRequestManager *KeyFrameRequestManager
+	headerExtensionIds     RtpHeaderExtensionIds
+	logger                 *slog.Logger
+}
+
+func NewProducer(id string, listener ProducerListener, options *ProducerOptions) *Producer {
+	p := &Producer{
+		id:                     id,
+		kind:                   options.Kind,
+		rtpParameters:          options.RtpParameters,
+		rtpMapping:             options.RtpMapping,
+		listener:               listener,
+		paused:                 options.Paused,
+		rtpStreamByEncodingIdx: make([]*RtpStreamRecv, len(options.RtpParameters.Encodings)),
+		mapSsrcRtpStream:       make(map[uint32]*RtpStreamRecv),
+		mapRtxSsrcRtpStream:    make(map[uint32]*RtpStreamRecv),
+		mapRtpStreamMappedSsrc: make(map[*RtpStreamRecv]uint32),
+		mapMappedSsrcSsrc:      make(map[uint32]uint32),
+		headerExtensionIds:     NewRtpHeaderExtensionIds(options.RtpParameters.HeaderExtensions),
+		logger:                 slog.Default().With("typename", "Producer", "id", id),
+	}
+
+	if p.kind == MediaKindVideo {
+		p.keyFrameRequestManager = NewKeyFrameRequestManager(p, options.KeyFrameRequestDelay)
+	}
+
+	return p
+}
+
+func (p *Producer) Id() string {
+	return p.id
+}
+
+func (p *Producer) Kind() MediaKind {
+	return p.kind
+}
+
+func (p *Producer) GetRtpParameters() RtpParameters {
+	return p.rtpParameters
+}
+
+// GetRtpStreams returns the receiving streams indexed by encoding. Streams are
+// created once the first packet of each encoding is received, so entries may
+// be nil.
+func (p *Producer) GetRtpStreams() []*RtpStreamRecv {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*RtpStreamRecv(nil), p.rtpStreamByEncodingIdx...)
+}
+
+func (p *Producer) IsPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.paused
+}
+
+func (p *Producer) Pause() {
+	p.mu.Lock()
+	if p.paused {
+		p.mu.Unlock()
+		return
+	}
+	p.paused = true
+	for _, rtpStream := range p.mapSsrcRtpStream {
+		rtpStream.Pause()
+	}
+	p.mu.Unlock()
+
+	p.listener.OnProducerPaused(p)
+}
+
+func (p *Producer) Resume() {
+	p.mu.Lock()
+	if !p.paused {
+		p.mu.Unlock()
+		return
+	}
+	p.paused = false
+	rtpStreams := make([]*RtpStreamRecv, 0, len(p.mapSsrcRtpStream))
+	for _, rtpStream := range p.mapSsrcRtpStream {
+		rtpStream.Resume()
+		rtpStreams = append(rtpStreams, rtpStream)
+	}
+	p.mu.Unlock()
+
+	p.listener.OnProducerResumed(p)
+
+	// Consumers will need a key frame to start sending again.
+	if p.kind == MediaKindVideo {
+		for _, rtpStream := range rtpStreams {
+			p.keyFrameRequestManager.ForceKeyFrameNeeded(rtpStream.GetSsrc())
+		}
+	}
+}
+
+func (p *Producer) GetScores() []ProducerScore {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var scores []ProducerScore
+
+	for _, rtpStream := range p.rtpStreamByEncodingIdx {
+		if rtpStream == nil {
+			continue
+		}
+		scores = append(scores, ProducerScore{
+			EncodingIdx: rtpStream.GetEncodingIdx(),
+			Ssrc:        rtpStream.GetSsrc(),
+			Rid:         rtpStream.GetRid(),
+			Score:       rtpStream.GetScore(),
+		})
+	}
+
+	return scores
+}
+
+func (p *Producer) GetStats() []RtpStreamStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var stats []RtpStreamStats
+
+	for _, rtpStream := range p.rtpStreamByEncodingIdx {
+		if rtpStream == nil {
+			continue
+		}
+		stats = append(stats, rtpStream.GetStats())
+	}
+
+	return stats
+}
+
+func (p *Producer) ReceiveRtpPacket(packet *RtpPacket) ReceiveRtpPacketResult {
+	packet.SetHeaderExtensionIds(p.headerExtensionIds)
+
+	p.mu.Lock()
+
+	rtpStream, isNew := p.getRtpStream(packet)
+	paused := p.paused
+	mappedSsrc := p.mapRtpStreamMappedSsrc[rtpStream]
+	p.mu.Unlock()
+
+	if rtpStream == nil {
+		p.logger.Warn("no stream found for received packet", "ssrc", packet.GetSsrc())
+		return ReceiveRtpPacketResultDiscarded
+	}
+
+	var result ReceiveRtpPacketResult
+
+	switch packet.GetSsrc() {
+	// Media packet.
+	case rtpStream.GetSsrc():
+		result = ReceiveRtpPacketResultMedia
+
+		p.setPayloadDescriptorHandler(packet, rtpStream)
+
+		if !rtpStream.ReceivePacket(packet) {
+			return ReceiveRtpPacketResultDiscarded
+		}
+
+	// RTX packet, decoded into the original one by the stream.
+	case rtpStream.GetRtxSsrc():
+		result = ReceiveRtpPacketResultRetransmission
+
+		if !rtpStream.ReceiveRtxPacket(packet) {
+			return ReceiveRtpPacketResultDiscarded
+		}
+
+		p.setPayloadDescriptorHandler(packet, rtpStream)
+
+	default:
+		p.logger.Warn("unsupported packet for stream", "ssrc", packet.GetSsrc())
+		return ReceiveRtpPacketResultDiscarded
+	}
+
+	if isNew {
+		p.listener.OnProducerNewRtpStream(p, rtpStream, mappedSsrc)
+
+		// Request a key frame for this stream since we may have lost the first
+		// packets.
+		if p.kind == MediaKindVideo && !packet.IsKeyFrame() && !paused {
+			p.keyFrameRequestManager.ForceKeyFrameNeeded(rtpStream.GetSsrc())
+		}
+	}
+
+	if packet.IsKeyFrame() && p.keyFrameRequestManager != nil {
+		p.keyFrameRequestManager.KeyFrameReceived(packet.GetSsrc())
+	}
+
+	// If paused stop here.
+	if paused {
+		return result
+	}
+
+	p.mangleRtpPacket(packet, mappedSsrc)
+
+	p.listener.OnProducerRtpPacketReceived(p, packet)
+
+	return result
+}
+
+func (p *Producer) ReceiveRtcpSenderReport(report *rtcp.SenderReport) {
+	p.mu.Lock()
+	rtpStream, ok := p.mapSsrcRtpStream[report.Ssrc]
+	p.mu.Unlock()
+
+	if !ok {
+		p.logger.Debug("RtpStream not found for received SR", "ssrc", report.Ssrc)
+		return
+	}
+
+	rtpStream.ReceiveRtcpSenderReport(report)
+}
+
+// GetRtcpReceiverReports returns a report block for every received stream.
+func (p *Producer) GetRtcpReceiverReports() []rtcp.ReceptionReport {
+	p.mu.Lock()
+	rtpStreams := make([]*RtpStreamRecv, 0, len(p.rtpStreamByEncodingIdx))
+	for _, rtpStream := range p.rtpStreamByEncodingIdx {
+		if rtpStream != nil {
+			rtpStreams = append(rtpStreams, rtpStream)
+		}
+	}
+	p.mu.Unlock()
+
+	// Generating the reports may update the scores, so streams are called
+	// without holding mu.
+	reports := make([]rtcp.ReceptionReport, 0, len(rtpStreams))
+	for _, rtpStream := range rtpStreams {
+		reports = append(reports, rtpStream.GetRtcpReceiverReport())
+	}
+
+	return reports
+}
+
+// RequestKeyFrame is called by consumers with the mapped ssrc of the stream
+// they need a key frame for.
+func (p *Producer) RequestKeyFrame(mappedSsrc uint32) {
+	if p.kind != MediaKindVideo || p.IsPaused() {
+		return
+	}
+
+	p.mu.Lock()
+	ssrc, ok := p.mapMappedSsrcSsrc[mappedSsrc]
+	p.mu.Unlock()
+
+	if !ok {
+		p.logger.Warn("given mappedSsrc not found, ignoring", "mappedSsrc", mappedSsrc)
+		return
+	}
+
+	p.keyFrameRequestManager.KeyFrameNeeded(ssrc)
+}
+
+func (p *Producer) Close() {
+	if p.keyFrameRequestManager != nil {
+		p.keyFrameRequestManager.Stop()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, rtpStream := range p.mapSsrcRtpStream {
+		rtpStream.Close()
+	}
+}
+
+func (p *Producer) OnRtpStreamNackRequired(rtpStream *RtpStreamRecv, seqNumbers []uint16) {
+	p.listener.OnProducerNackRequired(p, rtpStream.GetSsrc(), seqNumbers)
+}
+
+func (p *Producer) OnRtpStreamKeyFrameRequired(rtpStream *RtpStreamRecv) {
+	p.listener.OnProducerKeyFrameRequired(p, rtpStream.GetSsrc(), !rtpStream.params.UsePli)
+}
+
+func (p *Producer) OnRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8) {
+	p.listener.OnProducerRtpStreamScore(p, rtpStream, score, previousScore)
+}
+
+func (p *Producer) OnKeyFrameNeeded(keyFrameRequestManager *KeyFrameRequestManager, ssrc uint32) {
+	p.mu.Lock()
+	rtpStream, ok := p.mapSsrcRtpStream[ssrc]
+	p.mu.Unlock()
+
+	if !ok {
+		p.logger.Warn("no associated RtpStream found", "ssrc", ssrc)
+		return
+	}
+
+	rtpStream.RequestKeyFrame()
+}
+
+// getRtpStream looks for the stream the packet belongs to, creating it if the
+// packet matches an encoding with no stream yet. Must be called with mu held.
+func (p *Producer) getRtpStream(packet *RtpPacket) (rtpStream *RtpStreamRecv, isNew bool) {
+	ssrc := packet.GetSsrc()
+	payloadType := packet.PayloadType
+
+	// If stream found in media ssrcs map, return it.
+	if rtpStream, ok := p.mapSsrcRtpStream[ssrc]; ok {
+		return rtpStream, false
+	}
+
+	// If stream found in RTX ssrcs map, return it.
+	if rtpStream, ok := p.mapRtxSsrcRtpStream[ssrc]; ok {
+		return rtpStream, false
+	}
+
+	// Otherwise check our encodings and, if appropriate, create a new stream.
+
+	// First, look for an encoding with matching media ssrc.
+	for idx, encoding := range p.rtpParameters.Encodings {
+		if encoding.Ssrc != ssrc {
+			continue
+		}
+		mediaCodec := p.rtpParameters.GetCodecForEncoding(encoding)
+		if mediaCodec == nil || mediaCodec.PayloadType != payloadType {
+			p.logger.Warn("ignoring packet with unknown payload type", "ssrc", ssrc, "payloadType", payloadType)
+			return nil, false
+		}
+		return p.createRtpStream(packet, mediaCodec, idx), true
+	}
+
+	// Then look for an encoding with matching RTX ssrc. RTX packets never
+	// create a stream, they need the media one to exist.
+	for idx, encoding := range p.rtpParameters.Encodings {
+		if encoding.Rtx == nil || encoding.Rtx.Ssrc != ssrc {
+			continue
+		}
+		rtpStream := p.rtpStreamByEncodingIdx[idx]
+		if rtpStream == nil {
+			p.logger.Debug("ignoring RTX packet for a not yet created stream", "ssrc", ssrc)
+			return nil, false
+		}
+		return p.setRtpStreamRtx(rtpStream, encoding, ssrc), false
+	}
+
+	// Then look for an encoding matching the packet repaired RID value.
+	if rrid, ok := packet.ReadRepairedRid(); ok {
+		for idx, encoding := range p.rtpParameters.Encodings {
+			if encoding.Rid != rrid {
+				continue
+			}
+			rtpStream := p.rtpStreamByEncodingIdx[idx]
+			if rtpStream == nil {
+				p.logger.Debug("ignoring RTX packet for a not yet created stream", "ssrc", ssrc, "rrid", rrid)
+				return nil, false
+			}
+			return p.setRtpStreamRtx(rtpStream, encoding, ssrc), false
+		}
+	}
+
+	// If not found, look for an encoding matching the packet RID value.
+	rid, ok := packet.ReadRid()
+	if !ok {
+		return nil, false
+	}
+	for idx, encoding := range p.rtpParameters.Encodings {
+		if encoding.Rid != rid {
+			continue
+		}
+		// A stream for this encoding already exists with a different ssrc.
+		if p.rtpStreamByEncodingIdx[idx] != nil {
+			p.logger.Warn("ignoring packet with a new ssrc for an existing rid", "ssrc", ssrc, "rid", rid)
+			return nil, false
+		}
+		mediaCodec := p.rtpParameters.GetCodecForEncoding(encoding)
+		if mediaCodec == nil || mediaCodec.PayloadType != payloadType {
+			p.logger.Warn("ignoring packet with unknown payload type", "ssrc", ssrc, "payloadType", payloadType)
+			return nil, false
+		}
+		return p.createRtpStream(packet, mediaCodec, idx), true
+	}
+
+	return nil, false
+}
+
+func (p *Producer) createRtpStream(packet *RtpPacket, mediaCodec *RtpCodecParameters, encodingIdx int) *RtpStreamRecv {
+	ssrc := packet.GetSsrc()
+	encoding := p.rtpParameters.Encodings[encodingIdx]
+
+	var mappedSsrc uint32
+	if encodingIdx < len(p.rtpMapping.Encodings) {
+		mappedSsrc = p.rtpMapping.Encodings[encodingIdx].MappedSsrc
+	}
+
+	params := newRtpStreamParams(&p.rtpParameters, encodingIdx, mediaCodec)
+
+	// The ssrc of RID based encodings is learnt from the packet.
+	params.Ssrc = ssrc
+
+	// Only video streams need key frames.
+	if p.kind == MediaKindAudio {
+		params.UsePli = false
+		params.UseFir = false
+	}
+
+	rtpStream := NewRtpStreamRecv(p, params, 0)
+
+	if p.paused {
+		rtpStream.Pause()
+	}
+
+	p.mapSsrcRtpStream[ssrc] = rtpStream
+	p.rtpStreamByEncodingIdx[encodingIdx] = rtpStream
+	p.mapRtpStreamMappedSsrc[rtpStream] = mappedSsrc
+	p.mapMappedSsrcSsrc[mappedSsrc] = ssrc
+
+	if params.RtxSsrc != 0 {
+		p.mapRtxSsrcRtpStream[params.RtxSsrc] = rtpStream
+	}
+
+	p.logger.Debug("new RtpStreamRecv", "ssrc", ssrc, "mimeType", mediaCodec.MimeType,
+		"rid", encoding.Rid, "mappedSsrc", mappedSsrc)
+
+	return rtpStream
+}
+
+// setRtpStreamRtx associates the given RTX ssrc to the stream of the given
+// encoding. Must be called with mu held.
+func (p *Producer) setRtpStreamRtx(rtpStream *RtpStreamRecv, encoding RtpEncodingParameters, rtxSsrc uint32) *RtpStreamRecv {
+	rtxCodec := p.rtpParameters.GetRtxCodecForEncoding(encoding)
+	if rtxCodec == nil {
+		p.logger.Warn("ignoring RTX packet for an encoding without RTX codec", "ssrc", rtxSsrc)
+		return nil
+	}
+
+	rtpStream.SetRtx(rtxCodec.PayloadType, rtxSsrc)
+	p.mapRtxSsrcRtpStream[rtxSsrc] = rtpStream
+
+	p.logger.Debug("RTX stream learnt", "ssrc", rtpStream.GetSsrc(), "rtxSsrc", rtxSsrc)
+
+	return rtpStream
+}
+
+// setPayloadDescriptorHandler parses the payload descriptor so layers and key
+// frames can be known.
+func (p *Producer) setPayloadDescriptorHandler(packet *RtpPacket, rtpStream *RtpStreamRecv) {
+	packet.SetDependencyDescriptorParser(rtpStream.dependencyDescriptorParser)
+	packet.SetPayloadDescriptorHandler(codecs.GetPayloadDescriptorHandler(rtpStream.GetMimeType(), packet))
+}
+
+// mangleRtpPacket rewrites the packet ssrc and payload type into the ones
+// used inside the router.
+func (p *Producer) mangleRtpPacket(packet *RtpPacket, mappedSsrc uint32) {
+	for _, codec := range p.rtpMapping.Codecs {
+		if codec.PayloadType == packet.PayloadType {
+			packet.PayloadType = codec.MappedPayloadType
+			break
+		}
+	}
+
+	packet.SSRC = mappedSsrc
+}
@@ -0,0 +1,384 @@
+package rtc
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+type TestProducerListener struct {
+	sync.Mutex
+	receivedPackets     []*RtpPacket
+	newRtpStreams       map[uint32]*RtpStreamRecv
+	nackedSeqNumbers    []uint16
+	keyFrameRequired    map[uint32]int
+	pausedCount         int
+	resumedCount        int
+	receivedMappedSsrcs []uint32
+	scores              []uint8
+}
+
+func NewTestProducerListener() *TestProducerListener {
+	return &TestProducerListener{
+		newRtpStreams:    make(map[uint32]*RtpStreamRecv),
+		keyFrameRequired: make(map[uint32]int),
+	}
+}
+
+func (l *TestProducerListener) OnProducerRtpPacketReceived(producer *Producer, packet *RtpPacket) {
+	l.Lock()
+	defer l.Unlock()
+	l.receivedPackets = append(l.receivedPackets, packet)
+	l.receivedMappedSsrcs = append(l.receivedMappedSsrcs, packet.GetSsrc())
+}
+
+func (l *TestProducerListener) OnProducerNewRtpStream(producer *Producer, rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	l.Lock()
+	defer l.Unlock()
+	l.newRtpStreams[mappedSsrc] = rtpStream
+}
+
+func (l *TestProducerListener) OnProducerPaused(producer *Producer) {
+	l.Lock()
+	defer l.Unlock()
+	l.pausedCount++
+}
+
+func (l *TestProducerListener) OnProducerResumed(producer *Producer) {
+	l.Lock()
+	defer l.Unlock()
+	l.resumedCount++
+}
+
+func (l *TestProducerListener) OnProducerNackRequired(producer *Producer, ssrc uint32, seqNumbers []uint16) {
+	l.Lock()
+	defer l.Unlock()
+	l.nackedSeqNumbers = append(l.nackedSeqNumbers, seqNumbers...)
+}
+
+func (l *TestProducerListener) OnProducerKeyFrameRequired(producer *Producer, ssrc uint32, useFir bool) {
+	l.Lock()
+	defer l.Unlock()
+	l.keyFrameRequired[ssrc]++
+}
+
+func (l *TestProducerListener) OnProducerRtpStreamScore(producer *Producer, rtpStream *RtpStreamRecv, score, previousScore uint8) {
+	l.Lock()
+	defer l.Unlock()
+	l.scores = append(l.scores, score)
+}
+
+func createTestRtpPacket(t *testing.T, ssrc uint32, seq uint16, payloadType uint8, extensions map[uint8][]byte) *RtpPacket {
+	packet := rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    payloadType,
+			SequenceNumber: seq,
+			Timestamp:      uint32(seq) * 3000,
+			SSRC:           ssrc,
+		},
+		Payload: []byte{0x01, 0x02, 0x03, 0x04},
+	}
+	for id, payload := range extensions {
+		require.NoError(t, packet.SetExtension(id, payload))
+	}
+	data, err := packet.Marshal()
+	require.NoError(t, err)
+
+	rtpPacket, err := NewRtpPacket(data)
+	require.NoError(t, err)
+
+	return rtpPacket
+}
+
+// createTestRtxPacket creates a RTX packet carrying the given original
+// sequence number.
+func createTestRtxPacket(t *testing.T, ssrc uint32, seq uint16, payloadType uint8, origSeq uint16, extensions map[uint8][]byte) *RtpPacket {
+	packet := createTestRtpPacket(t, ssrc, seq, payloadType, extensions)
+	packet.Payload = append([]byte{byte(origSeq >> 8), byte(origSeq)}, packet.Payload...)
+	packet.Size += 2
+
+	return packet
+}
+
+func createTestVideoProducerOptions() *ProducerOptions {
+	return &ProducerOptions{
+		Kind: MediaKindVideo,
+		RtpParameters: RtpParameters{
+			Codecs: []*RtpCodecParameters{
+				{
+					MimeType:    "video/VP8",
+					PayloadType: 101,
+					ClockRate:   90000,
+					RtcpFeedback: []RtcpFeedback{
+						{Type: "nack"},
+						{Type: "nack", Parameter: "pli"},
+					},
+				},
+				{
+					MimeType:    "video/rtx",
+					PayloadType: 102,
+					ClockRate:   90000,
+					Parameters:  RtpCodecSpecificParameters{Apt: 101},
+				},
+			},
+			HeaderExtensions: []RtpHeaderExtensionParameters{
+				{Uri: RidHeaderExtensionUri, Id: 10},
+			},
+			Encodings: []RtpEncodingParameters{
+				{Ssrc: 1111, Rtx: &RtpEncodingRtx{Ssrc: 1112}, ScalabilityMode: "L1T3"},
+				{Rid: "h"},
+			},
+			Rtcp: RtcpParameters{Cname: "test"},
+		},
+		RtpMapping: RtpMapping{
+			Codecs: []RtpMappingCodec{
+				{PayloadType: 101, MappedPayloadType: 100},
+				{PayloadType: 102, MappedPayloadType: 103},
+			},
+			Encodings: []RtpMappingEncoding{
+				{Ssrc: 1111, MappedSsrc: 9001},
+				{Rid: "h", MappedSsrc: 9002},
+			},
+		},
+	}
+}
+
+func TestProducer(t *testing.T) {
+	t.Run("creates a stream per encoding and mangles packets", func(t *testing.T) {
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		result := producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+		require.Equal(t, ReceiveRtpPacketResultMedia, result)
+
+		result = producer.ReceiveRtpPacket(createTestRtpPacket(t, 2222, 1, 101, map[uint8][]byte{10: []byte("h")}))
+		require.Equal(t, ReceiveRtpPacketResultMedia, result)
+
+		// Unknown ssrc and no rid.
+		result = producer.ReceiveRtpPacket(createTestRtpPacket(t, 3333, 1, 101, nil))
+		require.Equal(t, ReceiveRtpPacketResultDiscarded, result)
+
+		// Known ssrc with wrong payload type on a new stream.
+		listener2 := NewTestProducerListener()
+		producer2 := NewProducer("p2", listener2, createTestVideoProducerOptions())
+		defer producer2.Close()
+		result = producer2.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 96, nil))
+		require.Equal(t, ReceiveRtpPacketResultDiscarded, result)
+
+		listener.Lock()
+		defer listener.Unlock()
+
+		require.Len(t, listener.newRtpStreams, 2)
+		require.EqualValues(t, 1111, listener.newRtpStreams[9001].GetSsrc())
+		require.EqualValues(t, 1112, listener.newRtpStreams[9001].GetRtxSsrc())
+		require.EqualValues(t, 102, listener.newRtpStreams[9001].GetRtxPayloadType())
+		require.EqualValues(t, 3, listener.newRtpStreams[9001].GetTemporalLayers())
+		require.EqualValues(t, 2222, listener.newRtpStreams[9002].GetSsrc())
+		require.Equal(t, "h", listener.newRtpStreams[9002].GetRid())
+
+		require.Equal(t, []uint32{9001, 9002}, listener.receivedMappedSsrcs)
+		for _, packet := range listener.receivedPackets {
+			require.EqualValues(t, 100, packet.PayloadType)
+		}
+
+		// A key frame is requested for every new video stream.
+		require.Equal(t, 1, listener.keyFrameRequired[1111])
+		require.Equal(t, 1, listener.keyFrameRequired[2222])
+	})
+
+	t.Run("paused producer does not forward packets", func(t *testing.T) {
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		producer.Pause()
+		producer.Pause()
+		require.True(t, producer.IsPaused())
+
+		result := producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+		require.Equal(t, ReceiveRtpPacketResultMedia, result)
+
+		producer.Resume()
+		require.False(t, producer.IsPaused())
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 2, 101, nil))
+
+		listener.Lock()
+		defer listener.Unlock()
+
+		require.Equal(t, 1, listener.pausedCount)
+		require.Equal(t, 1, listener.resumedCount)
+		require.Len(t, listener.receivedPackets, 1)
+		// Key frame requested on resume.
+		require.Equal(t, 1, listener.keyFrameRequired[1111])
+	})
+
+	t.Run("pauses and resumes while receiving packets", func(t *testing.T) {
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				producer.Pause()
+				producer.Resume()
+			}
+		}()
+		for seq := uint16(2); seq < 100; seq++ {
+			producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, seq, 101, nil))
+		}
+		<-done
+
+		rtpStream := producer.GetRtpStreams()[0]
+		require.False(t, rtpStream.IsPaused())
+		require.NotZero(t, rtpStream.activeSinceMs.Load())
+	})
+
+	t.Run("missing packets are NACKed", func(t *testing.T) {
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 4, 101, nil))
+
+		listener.Lock()
+		require.Equal(t, []uint16{2, 3}, listener.nackedSeqNumbers)
+		listener.Unlock()
+
+		stats := producer.GetStats()
+		require.Len(t, stats, 1)
+		require.EqualValues(t, 2, stats[0].PacketCount)
+		require.EqualValues(t, 1, stats[0].NackCount)
+		require.EqualValues(t, 2, stats[0].NackPacketCount)
+
+		scores := producer.GetScores()
+		require.Equal(t, []ProducerScore{{EncodingIdx: 0, Ssrc: 1111, Score: 10}}, scores)
+
+		reports := producer.GetRtcpReceiverReports()
+		require.Len(t, reports, 1)
+		require.EqualValues(t, 1111, reports[0].Ssrc)
+		require.EqualValues(t, 2, reports[0].TotalLost)
+
+		// Half of the packets were lost.
+		listener.Lock()
+		require.Equal(t, []uint8{1}, listener.scores)
+		listener.Unlock()
+		require.EqualValues(t, 1, producer.GetScores()[0].Score)
+	})
+
+	t.Run("NACKed packets are recovered from RTX", func(t *testing.T) {
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		// RTX packets do not create streams.
+		result := producer.ReceiveRtpPacket(createTestRtxPacket(t, 1112, 500, 102, 1, nil))
+		require.Equal(t, ReceiveRtpPacketResultDiscarded, result)
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 4, 101, nil))
+
+		result = producer.ReceiveRtpPacket(createTestRtxPacket(t, 1112, 501, 102, 2, nil))
+		require.Equal(t, ReceiveRtpPacketResultRetransmission, result)
+
+		// Already recovered.
+		result = producer.ReceiveRtpPacket(createTestRtxPacket(t, 1112, 502, 102, 2, nil))
+		require.Equal(t, ReceiveRtpPacketResultDiscarded, result)
+
+		// Not NACKed.
+		result = producer.ReceiveRtpPacket(createTestRtxPacket(t, 1112, 503, 102, 10, nil))
+		require.Equal(t, ReceiveRtpPacketResultDiscarded, result)
+
+		// Wrong RTX payload type.
+		result = producer.ReceiveRtpPacket(createTestRtxPacket(t, 1112, 504, 101, 3, nil))
+		require.Equal(t, ReceiveRtpPacketResultDiscarded, result)
+
+		listener.Lock()
+		require.Len(t, listener.receivedPackets, 3)
+		recovered := listener.receivedPackets[2]
+		require.EqualValues(t, 9001, recovered.SSRC)
+		require.EqualValues(t, 2, recovered.SequenceNumber)
+		require.EqualValues(t, 100, recovered.PayloadType)
+		require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, recovered.Payload)
+		listener.Unlock()
+
+		stats := producer.GetStats()
+		require.EqualValues(t, 3, stats[0].PacketCount)
+		require.EqualValues(t, 1, stats[0].PacketsRetransmitted)
+		require.EqualValues(t, 1, stats[0].PacketsRepaired)
+	})
+
+	t.Run("RTX ssrc is learnt from the repaired rid", func(t *testing.T) {
+		options := createTestVideoProducerOptions()
+		options.RtpParameters.HeaderExtensions = append(options.RtpParameters.HeaderExtensions,
+			RtpHeaderExtensionParameters{Uri: RepairedRidHeaderExtensionUri, Id: 11})
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, options)
+		defer producer.Close()
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 2222, 1, 101, map[uint8][]byte{10: []byte("h")}))
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 2222, 3, 101, nil))
+
+		result := producer.ReceiveRtpPacket(createTestRtxPacket(t, 2223, 700, 102, 2, map[uint8][]byte{11: []byte("h")}))
+		require.Equal(t, ReceiveRtpPacketResultRetransmission, result)
+
+		listener.Lock()
+		rtpStream := listener.newRtpStreams[9002]
+		listener.Unlock()
+		require.EqualValues(t, 2223, rtpStream.GetRtxSsrc())
+		require.EqualValues(t, 102, rtpStream.GetRtxPayloadType())
+	})
+
+	t.Run("key frame requests are forwarded by mapped ssrc", func(t *testing.T) {
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+		producer.keyFrameRequestManager.KeyFrameReceived(1111)
+
+		producer.RequestKeyFrame(9001)
+		// Unknown mapped ssrc.
+		producer.RequestKeyFrame(9005)
+
+		listener.Lock()
+		defer listener.Unlock()
+
+		require.Equal(t, 2, listener.keyFrameRequired[1111])
+	})
+
+	t.Run("key frames are detected from the frame marking header extension", func(t *testing.T) {
+		options := createTestVideoProducerOptions()
+		options.RtpParameters.Codecs[0].MimeType = "video/H264"
+		options.RtpParameters.HeaderExtensions = append(options.RtpParameters.HeaderExtensions,
+			RtpHeaderExtensionParameters{Uri: FrameMarkingHeaderExtensionUri, Id: 3})
+
+		listener := NewTestProducerListener()
+		producer := NewProducer("p1", listener, options)
+		defer producer.Close()
+
+		// Start of an independent frame.
+		packet := createTestRtpPacket(t, 1111, 1, 101, map[uint8][]byte{3: {0xa0}})
+		producer.ReceiveRtpPacket(packet)
+		require.True(t, packet.IsKeyFrame())
+
+		packet = createTestRtpPacket(t, 1111, 2, 101, map[uint8][]byte{3: {0x40}})
+		producer.ReceiveRtpPacket(packet)
+		require.False(t, packet.IsKeyFrame())
+
+		listener.Lock()
+		defer listener.Unlock()
+
+		// No key frame was requested for the new stream.
+		require.Zero(t, listener.keyFrameRequired[1111])
+	})
+}
@@ -172,3 +172,7 @@ func (r *rtpDataCounter) GetPacketCount() uint64 {
 func (r *rtpDataCounter) GetBytes() uint64 {
 	return r.rate.bytes
 }
+
+func (r *rtpDataCounter) GetBitrate(nowMs uint64) uint32 {
+	return r.rate.GetRate(nowMs)
+}
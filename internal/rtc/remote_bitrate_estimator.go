@@ -0,0 +1,161 @@
+package rtc
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+const (
+	// RembSendIntervalMs is the interval between two reports of the estimate.
+	RembSendIntervalMs = 1000
+	// rembImmediateDecreaseFactor is the ratio to the last reported estimate
+	// below which a new one is reported right away.
+	rembImmediateDecreaseFactor = 0.97
+	// remoteBitrateEstimatorStreamTimeoutMs is how long a SSRC stays reported
+	// without receiving packets.
+	remoteBitrateEstimatorStreamTimeoutMs = 2000
+	// remoteBitrateEstimatorIncomingBitrateWindowMs is the window the incoming
+	// bitrate is computed on.
+	remoteBitrateEstimatorIncomingBitrateWindowMs = 1000
+	// absSendTimeFractionalBits is the number of fractional bits of the 6.18
+	// fixed point abs-send-time.
+	absSendTimeFractionalBits = 18
+)
+
+type RemoteBitrateEstimatorListener interface {
+	// OnRemoteBitrateEstimatorValue is called with the available incoming
+	// bitrate estimate and the SSRCs it applies to.
+	OnRemoteBitrateEstimatorValue(estimator *RemoteBitrateEstimator, ssrcs []uint32, availableBitrate uint32)
+}
+
+// RemoteBitrateEstimator estimates the available incoming bitrate from the
+// arrival time deltas of the packets carrying the abs-send-time extension, for
+// remote endpoints not supporting transport-wide congestion control. The
+// estimate is meant to be reported to them in REMB packets.
+type RemoteBitrateEstimator struct {
+	listener        RemoteBitrateEstimatorListener
+	mu              sync.Mutex
+	delayBasedBwe   *DelayBasedBwe
+	incomingBitrate *RateCalculator
+	firstPacketAtMs uint64
+	// ssrcs maps the SSRCs of the received streams to the time in ms their
+	// last packet was received at.
+	ssrcs map[uint32]uint64
+	// absSendTime is the unwrapped send time of the last packet in 1/2^18
+	// seconds.
+	absSendTime         int64
+	absSendTimeStarted  bool
+	lastReportedAtMs    uint64
+	lastReportedBitrate uint32
+	closed              bool
+	logger              *slog.Logger
+}
+
+func NewRemoteBitrateEstimator(listener RemoteBitrateEstimatorListener) *RemoteBitrateEstimator {
+	return &RemoteBitrateEstimator{
+		listener: listener,
+		// The estimate starts at the incoming bitrate.
+		delayBasedBwe:   NewDelayBasedBwe(0),
+		incomingBitrate: NewRateCalculator(remoteBitrateEstimatorIncomingBitrateWindowMs, 8000, 100),
+		ssrcs:           make(map[uint32]uint64),
+		logger:          slog.Default().With("typename", "RemoteBitrateEstimator"),
+	}
+}
+
+// IncomingPacket updates the estimate with the packet, if it carries the
+// abs-send-time extension. The packet is read with the given header extension
+// ids, being received before its producer sets them.
+func (e *RemoteBitrateEstimator) IncomingPacket(nowMs uint64, packet *RtpPacket, headerExtensionIds RtpHeaderExtensionIds) {
+	absSendTime, ok := packet.readAbsSendTime(headerExtensionIds.AbsSendTime)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+
+	// Unwrap the 24 bits send time relatively to the last one.
+	if !e.absSendTimeStarted {
+		e.absSendTimeStarted = true
+		e.absSendTime = int64(absSendTime)
+	} else {
+		delta := int64((absSendTime-uint32(e.absSendTime))&0xFFFFFF) << 40 >> 40
+		e.absSendTime += delta
+	}
+	sendTimeMs := float64(e.absSendTime) * 1000 / (1 << absSendTimeFractionalBits)
+
+	if e.firstPacketAtMs == 0 {
+		e.firstPacketAtMs = nowMs
+	}
+	e.ssrcs[packet.GetSsrc()] = nowMs
+	e.incomingBitrate.Update(packet.Size, nowMs)
+	e.delayBasedBwe.IncomingPacket(sendTimeMs, float64(nowMs))
+
+	var incomingBitrate float64
+	if nowMs-e.firstPacketAtMs >= remoteBitrateEstimatorIncomingBitrateWindowMs {
+		incomingBitrate = float64(e.incomingBitrate.GetRate(nowMs))
+	}
+
+	estimate := e.delayBasedBwe.Update(incomingBitrate, nowMs)
+
+	// Not known until the incoming bitrate is.
+	if estimate == 0 {
+		e.mu.Unlock()
+		return
+	}
+
+	availableBitrate := uint32(max(estimate, TransportCcMinBitrate))
+
+	// Report periodically, and right away on a significant decrease.
+	if nowMs-e.lastReportedAtMs < RembSendIntervalMs &&
+		float64(availableBitrate) >= rembImmediateDecreaseFactor*float64(e.lastReportedBitrate) {
+		e.mu.Unlock()
+		return
+	}
+
+	e.lastReportedAtMs = nowMs
+	e.lastReportedBitrate = availableBitrate
+	ssrcs := e.getSsrcs(nowMs)
+
+	e.mu.Unlock()
+
+	e.listener.OnRemoteBitrateEstimatorValue(e, ssrcs, availableBitrate)
+}
+
+// Close stops the estimation, the packets received afterwards being ignored.
+func (e *RemoteBitrateEstimator) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+}
+
+// GetAvailableBitrate returns the last reported estimate, zero if none yet.
+func (e *RemoteBitrateEstimator) GetAvailableBitrate() uint32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.lastReportedBitrate
+}
+
+// getSsrcs forgets the SSRCs not received for a while and returns the others
+// in ascending order. Must be called with mu held.
+func (e *RemoteBitrateEstimator) getSsrcs(nowMs uint64) []uint32 {
+	ssrcs := make([]uint32, 0, len(e.ssrcs))
+
+	for ssrc, lastReceivedAtMs := range e.ssrcs {
+		if nowMs-lastReceivedAtMs > remoteBitrateEstimatorStreamTimeoutMs {
+			delete(e.ssrcs, ssrc)
+			continue
+		}
+		ssrcs = append(ssrcs, ssrc)
+	}
+	slices.Sort(ssrcs)
+
+	return ssrcs
+}
@@ -0,0 +1,153 @@
+package rtc
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/util"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+type testRembValue struct {
+	ssrcs   []uint32
+	bitrate uint32
+}
+
+type TestRemoteBitrateEstimatorListener struct {
+	sync.Mutex
+	values []testRembValue
+}
+
+func (l *TestRemoteBitrateEstimatorListener) OnRemoteBitrateEstimatorValue(estimator *RemoteBitrateEstimator, ssrcs []uint32, availableBitrate uint32) {
+	l.Lock()
+	defer l.Unlock()
+	l.values = append(l.values, testRembValue{ssrcs: ssrcs, bitrate: availableBitrate})
+}
+
+// testAbsSendTimeHeaderExtensionIds are the ids the packets created by
+// createTestAbsSendTimePacket are read with.
+var testAbsSendTimeHeaderExtensionIds = RtpHeaderExtensionIds{AbsSendTime: 3}
+
+// createTestAbsSendTimePacket creates a packet of 1000 bytes of payload sent
+// at the given time, with the abs-send-time extension id 3.
+func createTestAbsSendTimePacket(t *testing.T, ssrc uint32, seq uint16, sendTimeMs uint64) *RtpPacket {
+	absSendTime := util.TimeMsToAbsSendTime(sendTimeMs)
+	packet := rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    101,
+			SequenceNumber: seq,
+			SSRC:           ssrc,
+		},
+		Payload: make([]byte, 1000),
+	}
+	require.NoError(t, packet.SetExtension(3, []byte{byte(absSendTime >> 16), byte(absSendTime >> 8), byte(absSendTime)}))
+	data, err := packet.Marshal()
+	require.NoError(t, err)
+
+	rtpPacket, err := NewRtpPacket(data)
+	require.NoError(t, err)
+
+	return rtpPacket
+}
+
+// runTestRemoteBitrateEstimator sends a packet every 10 ms, from the given
+// send time on, through a simulated network with the given one-way delay.
+func runTestRemoteBitrateEstimator(t *testing.T, estimator *RemoteBitrateEstimator, sendTimeMs uint64, packets int, ssrcs []uint32, delayMs func(i int) uint64) {
+	for i := 0; i < packets; i++ {
+		ssrc := ssrcs[i%len(ssrcs)]
+		estimator.IncomingPacket(sendTimeMs+delayMs(i), createTestAbsSendTimePacket(t, ssrc, uint16(i), sendTimeMs), testAbsSendTimeHeaderExtensionIds)
+		sendTimeMs += 10
+	}
+}
+
+func TestRemoteBitrateEstimator(t *testing.T) {
+	constantDelay := func(i int) uint64 { return 50 }
+
+	t.Run("reports the estimate periodically on a stable network", func(t *testing.T) {
+		listener := &TestRemoteBitrateEstimatorListener{}
+		estimator := NewRemoteBitrateEstimator(listener)
+
+		// Not known until the incoming bitrate is.
+		runTestRemoteBitrateEstimator(t, estimator, 10000, 50, []uint32{1111, 2222}, constantDelay)
+		require.Empty(t, listener.values)
+		require.Zero(t, estimator.GetAvailableBitrate())
+
+		runTestRemoteBitrateEstimator(t, estimator, 10500, 400, []uint32{2222, 1111}, constantDelay)
+
+		require.Len(t, listener.values, 4)
+		for i, value := range listener.values {
+			require.Equal(t, []uint32{1111, 2222}, value.ssrcs)
+			// 1000 bytes every 10 ms.
+			require.GreaterOrEqual(t, value.bitrate, uint32(800000))
+			if i > 0 {
+				require.GreaterOrEqual(t, value.bitrate, listener.values[i-1].bitrate)
+			}
+		}
+		require.Equal(t, listener.values[3].bitrate, estimator.GetAvailableBitrate())
+	})
+
+	t.Run("decreases the estimate right away on congestion", func(t *testing.T) {
+		listener := &TestRemoteBitrateEstimatorListener{}
+		estimator := NewRemoteBitrateEstimator(listener)
+
+		runTestRemoteBitrateEstimator(t, estimator, 10000, 200, []uint32{1111}, constantDelay)
+		require.Len(t, listener.values, 1)
+		stableBitrate := listener.values[0].bitrate
+
+		// The queuing delay grows by 5 ms every packet for 500 ms.
+		runTestRemoteBitrateEstimator(t, estimator, 12000, 50, []uint32{1111}, func(i int) uint64 {
+			return 50 + uint64(i)*5
+		})
+
+		require.Greater(t, len(listener.values), 1)
+		require.Less(t, estimator.GetAvailableBitrate(), stableBitrate)
+	})
+
+	t.Run("unwraps the send time", func(t *testing.T) {
+		listener := &TestRemoteBitrateEstimatorListener{}
+		estimator := NewRemoteBitrateEstimator(listener)
+
+		// The 24 bits send time wraps every 64 seconds.
+		runTestRemoteBitrateEstimator(t, estimator, util.AbsSendTimeWrapMs-1000, 300, []uint32{1111}, constantDelay)
+
+		require.Len(t, listener.values, 2)
+		require.GreaterOrEqual(t, listener.values[1].bitrate, listener.values[0].bitrate)
+		require.Greater(t, estimator.absSendTime, int64(1)<<24)
+	})
+
+	t.Run("forgets the streams not received anymore", func(t *testing.T) {
+		listener := &TestRemoteBitrateEstimatorListener{}
+		estimator := NewRemoteBitrateEstimator(listener)
+
+		runTestRemoteBitrateEstimator(t, estimator, 10000, 150, []uint32{1111, 2222}, constantDelay)
+		runTestRemoteBitrateEstimator(t, estimator, 11500, 300, []uint32{2222}, constantDelay)
+
+		require.Len(t, listener.values, 4)
+		require.Equal(t, []uint32{1111, 2222}, listener.values[2].ssrcs)
+		require.Equal(t, []uint32{2222}, listener.values[3].ssrcs)
+	})
+
+	t.Run("ignores packets without abs-send-time", func(t *testing.T) {
+		listener := &TestRemoteBitrateEstimatorListener{}
+		estimator := NewRemoteBitrateEstimator(listener)
+
+		for i := 0; i < 200; i++ {
+			estimator.IncomingPacket(10000+uint64(i)*10, createTestRtpPacket(t, 1111, uint16(i), 101, nil), testAbsSendTimeHeaderExtensionIds)
+		}
+
+		require.Empty(t, listener.values)
+	})
+
+	t.Run("ignores packets once closed", func(t *testing.T) {
+		listener := &TestRemoteBitrateEstimatorListener{}
+		estimator := NewRemoteBitrateEstimator(listener)
+
+		estimator.Close()
+		runTestRemoteBitrateEstimator(t, estimator, 10000, 200, []uint32{1111}, constantDelay)
+
+		require.Empty(t, listener.values)
+		require.Empty(t, estimator.ssrcs)
+	})
+}
@@ -0,0 +1,70 @@
+package rtc
+
+const (
+	// RetransmissionBufferMaxItems must be a power of two, so sequence numbers
+	// wrapping around keep mapping to consecutive slots.
+	RetransmissionBufferMaxItems = 2048
+
+	RetransmissionBufferMaxVideoAgeMs = 2000
+	RetransmissionBufferMaxAudioAgeMs = 1000
+)
+
+// retransmissionItem is a packet stored for retransmission.
+type retransmissionItem struct {
+	// packet is a copy of the sent packet.
+	packet *RtpPacket
+	// seq is the sequence number of the original packet.
+	seq        uint16
+	storedAtMs uint64
+	// resentAtMs is the last time the packet was resent, or zero.
+	resentAtMs uint64
+	sentTimes  uint8
+}
+
+// RetransmissionBuffer keeps the latest sent packets of a stream so they can
+// be resent when NACKed. It is bounded both by number of packets and by age.
+type RetransmissionBuffer struct {
+	items    [RetransmissionBufferMaxItems]*retransmissionItem
+	maxAgeMs uint64
+}
+
+func NewRetransmissionBuffer(maxAgeMs uint64) *RetransmissionBuffer {
+	return &RetransmissionBuffer{
+		maxAgeMs: maxAgeMs,
+	}
+}
+
+// Insert stores a copy of the given packet, replacing the one stored with the
+// same sequence number or RetransmissionBufferMaxItems packets before.
+func (b *RetransmissionBuffer) Insert(packet *RtpPacket, nowMs uint64) {
+	idx := packet.SequenceNumber % RetransmissionBufferMaxItems
+
+	b.items[idx] = &retransmissionItem{
+		packet:     packet.Clone(),
+		seq:        packet.SequenceNumber,
+		storedAtMs: nowMs,
+	}
+}
+
+// Get returns the item stored for the given sequence number, or nil if it is
+// missing or too old to be resent.
+func (b *RetransmissionBuffer) Get(seq uint16, nowMs uint64) *retransmissionItem {
+	idx := seq % RetransmissionBufferMaxItems
+	item := b.items[idx]
+
+	if item == nil || item.seq != seq {
+		return nil
+	}
+
+	if nowMs-item.storedAtMs > b.maxAgeMs {
+		b.items[idx] = nil
+
+		return nil
+	}
+
+	return item
+}
+
+func (b *RetransmissionBuffer) Clear() {
+	b.items = [RetransmissionBufferMaxItems]*retransmissionItem{}
+}
@@ -0,0 +1,40 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetransmissionBuffer(t *testing.T) {
+	t.Run("keeps a copy of the stored packets", func(t *testing.T) {
+		buffer := NewRetransmissionBuffer(RetransmissionBufferMaxVideoAgeMs)
+		packet := createTestRtpPacket(t, 1111, 10, 100, nil)
+
+		buffer.Insert(packet, 1000)
+		packet.Payload[0] = 0xff
+
+		item := buffer.Get(10, 1000)
+		require.NotNil(t, item)
+		require.EqualValues(t, 0x01, item.packet.Payload[0])
+		require.Nil(t, buffer.Get(11, 1000))
+	})
+
+	t.Run("drops packets older than the max age", func(t *testing.T) {
+		buffer := NewRetransmissionBuffer(RetransmissionBufferMaxAudioAgeMs)
+		buffer.Insert(createTestRtpPacket(t, 1111, 10, 100, nil), 1000)
+
+		require.NotNil(t, buffer.Get(10, 1000+RetransmissionBufferMaxAudioAgeMs))
+		require.Nil(t, buffer.Get(10, 1001+RetransmissionBufferMaxAudioAgeMs))
+		require.Nil(t, buffer.Get(10, 1000))
+	})
+
+	t.Run("newer packets replace the ones too far behind", func(t *testing.T) {
+		buffer := NewRetransmissionBuffer(RetransmissionBufferMaxVideoAgeMs)
+		buffer.Insert(createTestRtpPacket(t, 1111, 10, 100, nil), 1000)
+		buffer.Insert(createTestRtpPacket(t, 1111, 10+RetransmissionBufferMaxItems, 100, nil), 1000)
+
+		require.Nil(t, buffer.Get(10, 1000))
+		require.NotNil(t, buffer.Get(10+RetransmissionBufferMaxItems, 1000))
+	})
+}
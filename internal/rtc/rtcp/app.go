@@ -0,0 +1,59 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+const appNameLength = 4
+
+// App is a RTCP APP packet.
+type App struct {
+	Subtype uint8
+	Ssrc    uint32
+	Name    [appNameLength]byte
+	// Data is the application dependent data, its length must be a multiple
+	// of 4.
+	Data []byte
+}
+
+func (p *App) Unmarshal(data []byte) error {
+	header, body, err := unmarshalHeader(data, PacketTypeAPP)
+	if err != nil {
+		return err
+	}
+	if len(body) < ssrcLength+appNameLength {
+		return ErrPacketTooShort
+	}
+
+	p.Subtype = header.Count
+	p.Ssrc = binary.BigEndian.Uint32(body)
+	copy(p.Name[:], body[ssrcLength:])
+	p.Data = append([]byte(nil), body[ssrcLength+appNameLength:]...)
+
+	return nil
+}
+
+func (p *App) Marshal() ([]byte, error) {
+	if p.Subtype > maxCount {
+		return nil, ErrTooManyItems
+	}
+	if len(p.Data)%4 != 0 {
+		return nil, ErrBadLength
+	}
+
+	buf, err := newPacketBuffer(p.MarshalSize(), p.Subtype, PacketTypeAPP)
+	if err != nil {
+		return nil, err
+	}
+
+	body := buf[headerLength:]
+	binary.BigEndian.PutUint32(body, p.Ssrc)
+	copy(body[ssrcLength:], p.Name[:])
+	copy(body[ssrcLength+appNameLength:], p.Data)
+
+	return buf, nil
+}
+
+func (p *App) MarshalSize() int {
+	return headerLength + ssrcLength + appNameLength + len(p.Data)
+}
@@ -0,0 +1,74 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+// Bye is a RTCP BYE packet.
+type Bye struct {
+	Ssrcs  []uint32
+	Reason string
+}
+
+func (p *Bye) Unmarshal(data []byte) error {
+	header, body, err := unmarshalHeader(data, PacketTypeBYE)
+	if err != nil {
+		return err
+	}
+
+	offset := int(header.Count) * ssrcLength
+	if len(body) < offset {
+		return ErrPacketTooShort
+	}
+
+	p.Ssrcs = nil
+	for i := 0; i < int(header.Count); i++ {
+		p.Ssrcs = append(p.Ssrcs, binary.BigEndian.Uint32(body[i*ssrcLength:]))
+	}
+
+	p.Reason = ""
+	if offset < len(body) {
+		length := int(body[offset])
+		if len(body)-offset-1 < length {
+			return ErrPacketTooShort
+		}
+		p.Reason = string(body[offset+1 : offset+1+length])
+	}
+
+	return nil
+}
+
+func (p *Bye) Marshal() ([]byte, error) {
+	if len(p.Ssrcs) > maxCount {
+		return nil, ErrTooManyItems
+	}
+	if len(p.Reason) > 255 {
+		return nil, ErrTextTooLong
+	}
+
+	buf, err := newPacketBuffer(p.MarshalSize(), uint8(len(p.Ssrcs)), PacketTypeBYE)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := headerLength
+	for _, ssrc := range p.Ssrcs {
+		binary.BigEndian.PutUint32(buf[offset:], ssrc)
+		offset += ssrcLength
+	}
+
+	if len(p.Reason) > 0 {
+		buf[offset] = uint8(len(p.Reason))
+		copy(buf[offset+1:], p.Reason)
+	}
+
+	return buf, nil
+}
+
+func (p *Bye) MarshalSize() int {
+	size := headerLength + len(p.Ssrcs)*ssrcLength
+	if len(p.Reason) > 0 {
+		size += padTo4(1 + len(p.Reason))
+	}
+	return size
+}
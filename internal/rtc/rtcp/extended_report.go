@@ -0,0 +1,209 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+// XR report block types.
+const (
+	XrBlockTypeRrtr uint8 = 4
+	XrBlockTypeDlrr uint8 = 5
+)
+
+const (
+	xrBlockHeaderLength = 4
+	rrtrBlockLength     = 8
+	dlrrItemLength      = 12
+)
+
+// XrBlock is a report block of a RTCP XR packet.
+type XrBlock interface {
+	BlockType() uint8
+	// marshalSize returns the length of the block, header included.
+	marshalSize() int
+	// marshalTo writes the block, header included.
+	marshalTo(buf []byte)
+}
+
+func marshalXrBlockHeader(buf []byte, blockType, typeSpecific uint8, size int) {
+	buf[0] = blockType
+	buf[1] = typeSpecific
+	binary.BigEndian.PutUint16(buf[2:], uint16(size/4-1))
+}
+
+// ReceiverReferenceTimeBlock is a XR Receiver Reference Time report block of
+// RFC 3611.
+type ReceiverReferenceTimeBlock struct {
+	NtpSec  uint32
+	NtpFrac uint32
+}
+
+func (b *ReceiverReferenceTimeBlock) BlockType() uint8 {
+	return XrBlockTypeRrtr
+}
+
+func (b *ReceiverReferenceTimeBlock) marshalSize() int {
+	return xrBlockHeaderLength + rrtrBlockLength
+}
+
+func (b *ReceiverReferenceTimeBlock) marshalTo(buf []byte) {
+	marshalXrBlockHeader(buf, XrBlockTypeRrtr, 0, b.marshalSize())
+	binary.BigEndian.PutUint32(buf[4:], b.NtpSec)
+	binary.BigEndian.PutUint32(buf[8:], b.NtpFrac)
+}
+
+// DlrrItem is a sub-block of a DLRR report block.
+type DlrrItem struct {
+	Ssrc uint32
+	// LastRr is the middle 32 bits of the NTP timestamp of the last RRTR
+	// received from the source.
+	LastRr uint32
+	// DelaySinceLastRr is expressed in 1/65536 seconds.
+	DelaySinceLastRr uint32
+}
+
+// DlrrBlock is a XR Delay since Last Receiver Report report block of RFC 3611.
+type DlrrBlock struct {
+	Items []DlrrItem
+}
+
+func (b *DlrrBlock) BlockType() uint8 {
+	return XrBlockTypeDlrr
+}
+
+func (b *DlrrBlock) marshalSize() int {
+	return xrBlockHeaderLength + len(b.Items)*dlrrItemLength
+}
+
+func (b *DlrrBlock) marshalTo(buf []byte) {
+	marshalXrBlockHeader(buf, XrBlockTypeDlrr, 0, b.marshalSize())
+	for i, item := range b.Items {
+		offset := xrBlockHeaderLength + i*dlrrItemLength
+		binary.BigEndian.PutUint32(buf[offset:], item.Ssrc)
+		binary.BigEndian.PutUint32(buf[offset+4:], item.LastRr)
+		binary.BigEndian.PutUint32(buf[offset+8:], item.DelaySinceLastRr)
+	}
+}
+
+// UnknownXrBlock is a report block of an unsupported type, kept as is.
+type UnknownXrBlock struct {
+	Type         uint8
+	TypeSpecific uint8
+	// Data is the block content, its length must be a multiple of 4.
+	Data []byte
+}
+
+func (b *UnknownXrBlock) BlockType() uint8 {
+	return b.Type
+}
+
+func (b *UnknownXrBlock) marshalSize() int {
+	return xrBlockHeaderLength + len(b.Data)
+}
+
+func (b *UnknownXrBlock) marshalTo(buf []byte) {
+	marshalXrBlockHeader(buf, b.Type, b.TypeSpecific, b.marshalSize())
+	copy(buf[xrBlockHeaderLength:], b.Data)
+}
+
+// ExtendedReport is a RTCP XR packet.
+type ExtendedReport struct {
+	Ssrc   uint32
+	Blocks []XrBlock
+}
+
+func (p *ExtendedReport) Unmarshal(data []byte) error {
+	_, body, err := unmarshalHeader(data, PacketTypeXR)
+	if err != nil {
+		return err
+	}
+	if len(body) < ssrcLength {
+		return ErrPacketTooShort
+	}
+
+	p.Ssrc = binary.BigEndian.Uint32(body)
+	p.Blocks = nil
+
+	for offset := ssrcLength; offset < len(body); {
+		if len(body)-offset < xrBlockHeaderLength {
+			return ErrPacketTooShort
+		}
+
+		blockType := body[offset]
+		typeSpecific := body[offset+1]
+		length := (int(binary.BigEndian.Uint16(body[offset+2:]))) * 4
+
+		if len(body)-offset-xrBlockHeaderLength < length {
+			return ErrPacketTooShort
+		}
+
+		content := body[offset+xrBlockHeaderLength : offset+xrBlockHeaderLength+length]
+
+		switch blockType {
+		case XrBlockTypeRrtr:
+			if len(content) != rrtrBlockLength {
+				return ErrBadLength
+			}
+			p.Blocks = append(p.Blocks, &ReceiverReferenceTimeBlock{
+				NtpSec:  binary.BigEndian.Uint32(content),
+				NtpFrac: binary.BigEndian.Uint32(content[4:]),
+			})
+
+		case XrBlockTypeDlrr:
+			if len(content)%dlrrItemLength != 0 {
+				return ErrBadLength
+			}
+			block := &DlrrBlock{}
+			for i := 0; i < len(content); i += dlrrItemLength {
+				block.Items = append(block.Items, DlrrItem{
+					Ssrc:             binary.BigEndian.Uint32(content[i:]),
+					LastRr:           binary.BigEndian.Uint32(content[i+4:]),
+					DelaySinceLastRr: binary.BigEndian.Uint32(content[i+8:]),
+				})
+			}
+			p.Blocks = append(p.Blocks, block)
+
+		default:
+			p.Blocks = append(p.Blocks, &UnknownXrBlock{
+				Type:         blockType,
+				TypeSpecific: typeSpecific,
+				Data:         append([]byte(nil), content...),
+			})
+		}
+
+		offset += xrBlockHeaderLength + length
+	}
+
+	return nil
+}
+
+func (p *ExtendedReport) Marshal() ([]byte, error) {
+	for _, block := range p.Blocks {
+		if unknown, ok := block.(*UnknownXrBlock); ok && len(unknown.Data)%4 != 0 {
+			return nil, ErrBadLength
+		}
+	}
+
+	buf, err := newPacketBuffer(p.MarshalSize(), 0, PacketTypeXR)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.BigEndian.PutUint32(buf[headerLength:], p.Ssrc)
+
+	offset := headerLength + ssrcLength
+	for _, block := range p.Blocks {
+		block.marshalTo(buf[offset:])
+		offset += block.marshalSize()
+	}
+
+	return buf, nil
+}
+
+func (p *ExtendedReport) MarshalSize() int {
+	size := headerLength + ssrcLength
+	for _, block := range p.Blocks {
+		size += block.marshalSize()
+	}
+	return size
+}
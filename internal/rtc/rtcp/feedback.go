@@ -0,0 +1,124 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+const feedbackHeaderLength = 8
+
+// unmarshalFeedback parses the common part of a feedback packet of RFC 4585
+// and returns its feedback control information.
+func unmarshalFeedback(data []byte, packetType PacketType, format uint8) (senderSsrc, mediaSsrc uint32, fci []byte, err error) {
+	header, body, err := unmarshalHeader(data, packetType)
+	if err != nil {
+		return
+	}
+	if header.Count != format {
+		err = ErrWrongType
+		return
+	}
+	if len(body) < feedbackHeaderLength {
+		err = ErrPacketTooShort
+		return
+	}
+	senderSsrc = binary.BigEndian.Uint32(body)
+	mediaSsrc = binary.BigEndian.Uint32(body[4:])
+	fci = body[feedbackHeaderLength:]
+	return
+}
+
+// newFeedbackBuffer allocates a feedback packet of the given size and writes
+// its common part. It returns the buffer of the feedback control information.
+func newFeedbackBuffer(size int, packetType PacketType, format uint8, senderSsrc, mediaSsrc uint32) (buf, fci []byte, err error) {
+	if buf, err = newPacketBuffer(size, format, packetType); err != nil {
+		return
+	}
+	binary.BigEndian.PutUint32(buf[headerLength:], senderSsrc)
+	binary.BigEndian.PutUint32(buf[headerLength+4:], mediaSsrc)
+	fci = buf[headerLength+feedbackHeaderLength:]
+	return
+}
+
+// Pli is a RTCP Picture Loss Indication feedback packet.
+type Pli struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+}
+
+func (p *Pli) Unmarshal(data []byte) error {
+	senderSsrc, mediaSsrc, fci, err := unmarshalFeedback(data, PacketTypePSFB, FormatPli)
+	if err != nil {
+		return err
+	}
+	if len(fci) != 0 {
+		return ErrBadLength
+	}
+	p.SenderSsrc = senderSsrc
+	p.MediaSsrc = mediaSsrc
+	return nil
+}
+
+func (p *Pli) Marshal() ([]byte, error) {
+	buf, _, err := newFeedbackBuffer(p.MarshalSize(), PacketTypePSFB, FormatPli, p.SenderSsrc, p.MediaSsrc)
+	return buf, err
+}
+
+func (p *Pli) MarshalSize() int {
+	return headerLength + feedbackHeaderLength
+}
+
+// FirEntry asks the source with the given ssrc for a key frame.
+type FirEntry struct {
+	Ssrc uint32
+	// SequenceNumber is increased for each new request to the source.
+	SequenceNumber uint8
+}
+
+const firEntryLength = 8
+
+// Fir is a RTCP Full Intra Request feedback packet of RFC 5104.
+type Fir struct {
+	SenderSsrc uint32
+	// MediaSsrc is unused and should be 0.
+	MediaSsrc uint32
+	Entries   []FirEntry
+}
+
+func (p *Fir) Unmarshal(data []byte) error {
+	senderSsrc, mediaSsrc, fci, err := unmarshalFeedback(data, PacketTypePSFB, FormatFir)
+	if err != nil {
+		return err
+	}
+	if len(fci) == 0 || len(fci)%firEntryLength != 0 {
+		return ErrBadLength
+	}
+	p.SenderSsrc = senderSsrc
+	p.MediaSsrc = mediaSsrc
+	p.Entries = nil
+	for offset := 0; offset < len(fci); offset += firEntryLength {
+		p.Entries = append(p.Entries, FirEntry{
+			Ssrc:           binary.BigEndian.Uint32(fci[offset:]),
+			SequenceNumber: fci[offset+4],
+		})
+	}
+	return nil
+}
+
+func (p *Fir) Marshal() ([]byte, error) {
+	if len(p.Entries) == 0 {
+		return nil, ErrInvalidFeedback
+	}
+	buf, fci, err := newFeedbackBuffer(p.MarshalSize(), PacketTypePSFB, FormatFir, p.SenderSsrc, p.MediaSsrc)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range p.Entries {
+		binary.BigEndian.PutUint32(fci[i*firEntryLength:], entry.Ssrc)
+		fci[i*firEntryLength+4] = entry.SequenceNumber
+	}
+	return buf, nil
+}
+
+func (p *Fir) MarshalSize() int {
+	return headerLength + feedbackHeaderLength + len(p.Entries)*firEntryLength
+}
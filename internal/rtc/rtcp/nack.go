@@ -0,0 +1,122 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+const nackItemLength = 4
+
+// NackItem reports the loss of the packet with sequence number Pid and of the
+// following 16 packets whose bits are set in Blp.
+type NackItem struct {
+	Pid uint16
+	Blp uint16
+}
+
+// Nack is a RTCP Generic NACK feedback packet.
+type Nack struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Items      []NackItem
+}
+
+func (p *Nack) Unmarshal(data []byte) error {
+	senderSsrc, mediaSsrc, fci, err := unmarshalFeedback(data, PacketTypeRTPFB, FormatNack)
+	if err != nil {
+		return err
+	}
+	if len(fci) == 0 || len(fci)%nackItemLength != 0 {
+		return ErrBadLength
+	}
+	p.SenderSsrc = senderSsrc
+	p.MediaSsrc = mediaSsrc
+	p.Items = nil
+	for offset := 0; offset < len(fci); offset += nackItemLength {
+		p.Items = append(p.Items, NackItem{
+			Pid: binary.BigEndian.Uint16(fci[offset:]),
+			Blp: binary.BigEndian.Uint16(fci[offset+2:]),
+		})
+	}
+	return nil
+}
+
+func (p *Nack) Marshal() ([]byte, error) {
+	if len(p.Items) == 0 {
+		return nil, ErrInvalidFeedback
+	}
+	buf, fci, err := newFeedbackBuffer(p.MarshalSize(), PacketTypeRTPFB, FormatNack, p.SenderSsrc, p.MediaSsrc)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range p.Items {
+		binary.BigEndian.PutUint16(fci[i*nackItemLength:], item.Pid)
+		binary.BigEndian.PutUint16(fci[i*nackItemLength+2:], item.Blp)
+	}
+	return buf, nil
+}
+
+func (p *Nack) MarshalSize() int {
+	return headerLength + feedbackHeaderLength + len(p.Items)*nackItemLength
+}
+
+// NewNackItems packs the given lost sequence numbers into PID+BLP items. The
+// sequence numbers must be in ascending order, as delivered by the NACK
+// generator, so each item covers as many of them as possible.
+func NewNackItems(seqNumbers []uint16) []NackItem {
+	var items []NackItem
+
+	for _, seq := range seqNumbers {
+		if len(items) > 0 {
+			item := &items[len(items)-1]
+			diff := seq - item.Pid
+
+			// Duplicated sequence number.
+			if diff == 0 {
+				continue
+			}
+
+			if diff <= 16 {
+				item.Blp |= 1 << (diff - 1)
+				continue
+			}
+		}
+
+		items = append(items, NackItem{Pid: seq})
+	}
+
+	return items
+}
+
+// NewNacks packs the given lost sequence numbers into as few NACK packets as
+// possible, none of them exceeding maxPacketSize bytes.
+func NewNacks(senderSsrc, mediaSsrc uint32, seqNumbers []uint16, maxPacketSize int) []*Nack {
+	items := NewNackItems(seqNumbers)
+	maxItems := max((maxPacketSize-headerLength-feedbackHeaderLength)/nackItemLength, 1)
+
+	var nacks []*Nack
+	for len(items) > 0 {
+		count := min(len(items), maxItems)
+		nacks = append(nacks, &Nack{
+			SenderSsrc: senderSsrc,
+			MediaSsrc:  mediaSsrc,
+			Items:      items[:count:count],
+		})
+		items = items[count:]
+	}
+
+	return nacks
+}
+
+// GetSequenceNumbers returns the sequence numbers reported as lost.
+func (p *Nack) GetSequenceNumbers() []uint16 {
+	var seqs []uint16
+	for _, item := range p.Items {
+		seqs = append(seqs, item.Pid)
+		for i := uint16(0); i < 16; i++ {
+			if item.Blp&(1<<i) != 0 {
+				seqs = append(seqs, item.Pid+i+1)
+			}
+		}
+	}
+	return seqs
+}
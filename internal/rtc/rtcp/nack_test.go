@@ -0,0 +1,73 @@
+package rtcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNack(t *testing.T) {
+	t.Run("packs sequence numbers into PID and BLP items", func(t *testing.T) {
+		testCases := []struct {
+			name       string
+			seqNumbers []uint16
+			items      []NackItem
+		}{
+			{"single", []uint16{100}, []NackItem{{Pid: 100}}},
+			{"consecutive", []uint16{100, 101, 102}, []NackItem{{Pid: 100, Blp: 0x0003}}},
+			{"last BLP bit", []uint16{100, 116}, []NackItem{{Pid: 100, Blp: 0x8000}}},
+			{"beyond BLP", []uint16{100, 117}, []NackItem{{Pid: 100}, {Pid: 117}}},
+			{"duplicated", []uint16{100, 100, 102}, []NackItem{{Pid: 100, Blp: 0x0002}}},
+			{"wrap around", []uint16{65534, 65535, 0, 1}, []NackItem{{Pid: 65534, Blp: 0x0007}}},
+			{"sparse", []uint16{1, 5, 40, 41, 60}, []NackItem{{Pid: 1, Blp: 0x0008}, {Pid: 40, Blp: 0x0001}, {Pid: 60}}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				items := NewNackItems(tc.seqNumbers)
+				require.Equal(t, tc.items, items)
+
+				nack := &Nack{Items: items}
+				require.Equal(t, uniqueSeqNumbers(tc.seqNumbers), nack.GetSequenceNumbers())
+			})
+		}
+
+		require.Empty(t, NewNackItems(nil))
+	})
+
+	t.Run("splits items across packets not exceeding the given size", func(t *testing.T) {
+		var seqNumbers []uint16
+		for i := 0; i < 10; i++ {
+			// Each sequence number needs its own item.
+			seqNumbers = append(seqNumbers, uint16(i*20))
+		}
+
+		// Room for 4 items.
+		nacks := NewNacks(1, 2, seqNumbers, 28+3)
+		require.Len(t, nacks, 3)
+
+		var seqs []uint16
+		for _, nack := range nacks {
+			require.EqualValues(t, 1, nack.SenderSsrc)
+			require.EqualValues(t, 2, nack.MediaSsrc)
+			require.LessOrEqual(t, nack.MarshalSize(), 28+3)
+			seqs = append(seqs, nack.GetSequenceNumbers()...)
+		}
+		require.Len(t, nacks[0].Items, 4)
+		require.Len(t, nacks[2].Items, 2)
+		require.Equal(t, seqNumbers, seqs)
+
+		require.Len(t, NewNacks(1, 2, seqNumbers, 1200), 1)
+		require.Empty(t, NewNacks(1, 2, nil, 1200))
+	})
+}
+
+func uniqueSeqNumbers(seqNumbers []uint16) []uint16 {
+	var unique []uint16
+	for _, seq := range seqNumbers {
+		if len(unique) == 0 || unique[len(unique)-1] != seq {
+			unique = append(unique, seq)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,93 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+var rembUniqueIdentifier = [4]byte{'R', 'E', 'M', 'B'}
+
+// isRemb tells whether the given application layer feedback packet is a REMB
+// one.
+func isRemb(data []byte) bool {
+	offset := headerLength + feedbackHeaderLength
+	return len(data) >= offset+4 && [4]byte(data[offset:offset+4]) == rembUniqueIdentifier
+}
+
+// Remb is a RTCP Receiver Estimated Maximum Bitrate application layer feedback
+// packet.
+type Remb struct {
+	SenderSsrc uint32
+	// Bitrate is the estimated bitrate in bps. It is rounded down to fit in
+	// an 18 bits mantissa and a 6 bits exponent when serialized.
+	Bitrate uint64
+	Ssrcs   []uint32
+}
+
+func (p *Remb) Unmarshal(data []byte) error {
+	senderSsrc, _, fci, err := unmarshalFeedback(data, PacketTypePSFB, FormatAfb)
+	if err != nil {
+		return err
+	}
+	if len(fci) < 8 {
+		return ErrPacketTooShort
+	}
+	if [4]byte(fci[:4]) != rembUniqueIdentifier {
+		return ErrWrongType
+	}
+
+	numSsrcs := int(fci[4])
+	if len(fci) != 8+numSsrcs*ssrcLength {
+		return ErrBadLength
+	}
+
+	exponent := fci[5] >> 2
+	mantissa := uint64(fci[5]&0x03)<<16 | uint64(binary.BigEndian.Uint16(fci[6:]))
+
+	p.SenderSsrc = senderSsrc
+	p.Bitrate = mantissa << exponent
+	// Check overflow.
+	if p.Bitrate>>exponent != mantissa {
+		return ErrInvalidFeedback
+	}
+
+	p.Ssrcs = nil
+	for i := 0; i < numSsrcs; i++ {
+		p.Ssrcs = append(p.Ssrcs, binary.BigEndian.Uint32(fci[8+i*ssrcLength:]))
+	}
+
+	return nil
+}
+
+func (p *Remb) Marshal() ([]byte, error) {
+	if len(p.Ssrcs) > 255 {
+		return nil, ErrTooManyItems
+	}
+
+	// The media ssrc is always 0.
+	buf, fci, err := newFeedbackBuffer(p.MarshalSize(), PacketTypePSFB, FormatAfb, p.SenderSsrc, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	mantissa := p.Bitrate
+	exponent := uint8(0)
+	for mantissa >= 1<<18 {
+		mantissa >>= 1
+		exponent++
+	}
+
+	copy(fci, rembUniqueIdentifier[:])
+	fci[4] = uint8(len(p.Ssrcs))
+	fci[5] = exponent<<2 | uint8(mantissa>>16)
+	binary.BigEndian.PutUint16(fci[6:], uint16(mantissa))
+
+	for i, ssrc := range p.Ssrcs {
+		binary.BigEndian.PutUint32(fci[8+i*ssrcLength:], ssrc)
+	}
+
+	return buf, nil
+}
+
+func (p *Remb) MarshalSize() int {
+	return headerLength + feedbackHeaderLength + 8 + len(p.Ssrcs)*ssrcLength
+}
@@ -0,0 +1,167 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+const (
+	receptionReportLength = 24
+	senderInfoLength      = 24
+)
+
+// ReceptionReport is a report block of SR and RR packets.
+type ReceptionReport struct {
+	Ssrc         uint32
+	FractionLost uint8
+	// TotalLost is the cumulative number of packets lost, a 24 bits signed
+	// value on the wire.
+	TotalLost int32
+	LastSeq   uint32
+	Jitter    uint32
+	// Lsr is the middle 32 bits of the NTP timestamp of the last SR received.
+	Lsr uint32
+	// Dlsr is the delay since the last SR received, in 1/65536 seconds.
+	Dlsr uint32
+}
+
+func (r *ReceptionReport) unmarshal(data []byte) {
+	r.Ssrc = binary.BigEndian.Uint32(data)
+	r.FractionLost = data[4]
+	// Sign extend the 24 bits value.
+	r.TotalLost = int32(binary.BigEndian.Uint32(data[4:])<<8) >> 8
+	r.LastSeq = binary.BigEndian.Uint32(data[8:])
+	r.Jitter = binary.BigEndian.Uint32(data[12:])
+	r.Lsr = binary.BigEndian.Uint32(data[16:])
+	r.Dlsr = binary.BigEndian.Uint32(data[20:])
+}
+
+func (r ReceptionReport) marshalTo(buf []byte) {
+	binary.BigEndian.PutUint32(buf, r.Ssrc)
+	totalLost := min(max(r.TotalLost, -0x800000), 0x7FFFFF)
+	binary.BigEndian.PutUint32(buf[4:], uint32(totalLost)&0xFFFFFF)
+	buf[4] = r.FractionLost
+	binary.BigEndian.PutUint32(buf[8:], r.LastSeq)
+	binary.BigEndian.PutUint32(buf[12:], r.Jitter)
+	binary.BigEndian.PutUint32(buf[16:], r.Lsr)
+	binary.BigEndian.PutUint32(buf[20:], r.Dlsr)
+}
+
+func unmarshalReceptionReports(data []byte, count uint8) ([]ReceptionReport, error) {
+	if len(data) < int(count)*receptionReportLength {
+		return nil, ErrPacketTooShort
+	}
+	var reports []ReceptionReport
+	for i := 0; i < int(count); i++ {
+		var report ReceptionReport
+		report.unmarshal(data[i*receptionReportLength:])
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// SenderReport is a RTCP SR packet.
+type SenderReport struct {
+	Ssrc        uint32
+	NtpSec      uint32
+	NtpFrac     uint32
+	RtpTs       uint32
+	PacketCount uint32
+	OctetCount  uint32
+	Reports     []ReceptionReport
+}
+
+func (p *SenderReport) Unmarshal(data []byte) error {
+	header, body, err := unmarshalHeader(data, PacketTypeSR)
+	if err != nil {
+		return err
+	}
+	if len(body) < ssrcLength+senderInfoLength {
+		return ErrPacketTooShort
+	}
+
+	p.Ssrc = binary.BigEndian.Uint32(body)
+	p.NtpSec = binary.BigEndian.Uint32(body[4:])
+	p.NtpFrac = binary.BigEndian.Uint32(body[8:])
+	p.RtpTs = binary.BigEndian.Uint32(body[12:])
+	p.PacketCount = binary.BigEndian.Uint32(body[16:])
+	p.OctetCount = binary.BigEndian.Uint32(body[20:])
+
+	// Profile specific extensions, if any, are ignored.
+	p.Reports, err = unmarshalReceptionReports(body[ssrcLength+senderInfoLength:], header.Count)
+	return err
+}
+
+func (p *SenderReport) Marshal() ([]byte, error) {
+	if len(p.Reports) > maxCount {
+		return nil, ErrTooManyItems
+	}
+
+	buf, err := newPacketBuffer(p.MarshalSize(), uint8(len(p.Reports)), PacketTypeSR)
+	if err != nil {
+		return nil, err
+	}
+
+	body := buf[headerLength:]
+	binary.BigEndian.PutUint32(body, p.Ssrc)
+	binary.BigEndian.PutUint32(body[4:], p.NtpSec)
+	binary.BigEndian.PutUint32(body[8:], p.NtpFrac)
+	binary.BigEndian.PutUint32(body[12:], p.RtpTs)
+	binary.BigEndian.PutUint32(body[16:], p.PacketCount)
+	binary.BigEndian.PutUint32(body[20:], p.OctetCount)
+
+	for i, report := range p.Reports {
+		report.marshalTo(body[ssrcLength+senderInfoLength+i*receptionReportLength:])
+	}
+
+	return buf, nil
+}
+
+func (p *SenderReport) MarshalSize() int {
+	return headerLength + ssrcLength + senderInfoLength + len(p.Reports)*receptionReportLength
+}
+
+// ReceiverReport is a RTCP RR packet.
+type ReceiverReport struct {
+	Ssrc    uint32
+	Reports []ReceptionReport
+}
+
+func (p *ReceiverReport) Unmarshal(data []byte) error {
+	header, body, err := unmarshalHeader(data, PacketTypeRR)
+	if err != nil {
+		return err
+	}
+	if len(body) < ssrcLength {
+		return ErrPacketTooShort
+	}
+
+	p.Ssrc = binary.BigEndian.Uint32(body)
+
+	// Profile specific extensions, if any, are ignored.
+	p.Reports, err = unmarshalReceptionReports(body[ssrcLength:], header.Count)
+	return err
+}
+
+func (p *ReceiverReport) Marshal() ([]byte, error) {
+	if len(p.Reports) > maxCount {
+		return nil, ErrTooManyItems
+	}
+
+	buf, err := newPacketBuffer(p.MarshalSize(), uint8(len(p.Reports)), PacketTypeRR)
+	if err != nil {
+		return nil, err
+	}
+
+	body := buf[headerLength:]
+	binary.BigEndian.PutUint32(body, p.Ssrc)
+
+	for i, report := range p.Reports {
+		report.marshalTo(body[ssrcLength+i*receptionReportLength:])
+	}
+
+	return buf, nil
+}
+
+func (p *ReceiverReport) MarshalSize() int {
+	return headerLength + ssrcLength + len(p.Reports)*receptionReportLength
+}
@@ -0,0 +1,268 @@
+// Package rtcp parses and serializes RTCP compound packets.
+package rtcp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrPacketTooShort  = errors.New("rtcp: packet too short")
+	ErrBadVersion      = errors.New("rtcp: invalid version")
+	ErrBadLength       = errors.New("rtcp: invalid length")
+	ErrBadPadding      = errors.New("rtcp: invalid padding")
+	ErrWrongType       = errors.New("rtcp: wrong packet type")
+	ErrTooManyItems    = errors.New("rtcp: too many items")
+	ErrTextTooLong     = errors.New("rtcp: text too long")
+	ErrInvalidFeedback = errors.New("rtcp: invalid feedback message")
+)
+
+const (
+	rtcpVersion     = 2
+	headerLength    = 4
+	maxCount        = 31
+	ssrcLength      = 4
+	maxPacketLength = (1 << 16) * 4
+)
+
+type PacketType uint8
+
+const (
+	PacketTypeSR    PacketType = 200
+	PacketTypeRR    PacketType = 201
+	PacketTypeSDES  PacketType = 202
+	PacketTypeBYE   PacketType = 203
+	PacketTypeAPP   PacketType = 204
+	PacketTypeRTPFB PacketType = 205
+	PacketTypePSFB  PacketType = 206
+	PacketTypeXR    PacketType = 207
+)
+
+func (t PacketType) String() string {
+	switch t {
+	case PacketTypeSR:
+		return "SR"
+	case PacketTypeRR:
+		return "RR"
+	case PacketTypeSDES:
+		return "SDES"
+	case PacketTypeBYE:
+		return "BYE"
+	case PacketTypeAPP:
+		return "APP"
+	case PacketTypeRTPFB:
+		return "RTPFB"
+	case PacketTypePSFB:
+		return "PSFB"
+	case PacketTypeXR:
+		return "XR"
+	default:
+		return "unknown"
+	}
+}
+
+// Feedback message types (FMT) of RTPFB and PSFB packets.
+const (
+	FormatNack uint8 = 1
+	FormatTcc  uint8 = 15
+
+	FormatPli uint8 = 1
+	FormatFir uint8 = 4
+	FormatAfb uint8 = 15
+)
+
+// Header is the common header of every RTCP packet.
+type Header struct {
+	Padding bool
+	// Count is the reception report or source count, the subtype of APP
+	// packets or the FMT of feedback packets.
+	Count uint8
+	Type  PacketType
+	// Length is the length of the packet in 32 bits words minus one.
+	Length uint16
+}
+
+func (h *Header) Unmarshal(data []byte) error {
+	if len(data) < headerLength {
+		return ErrPacketTooShort
+	}
+	if data[0]>>6 != rtcpVersion {
+		return ErrBadVersion
+	}
+	h.Padding = (data[0]>>5)&0x01 == 1
+	h.Count = data[0] & 0x1F
+	h.Type = PacketType(data[1])
+	h.Length = binary.BigEndian.Uint16(data[2:])
+	return nil
+}
+
+func (h Header) marshalTo(buf []byte) {
+	buf[0] = rtcpVersion<<6 | h.Count&0x1F
+	if h.Padding {
+		buf[0] |= 0x20
+	}
+	buf[1] = uint8(h.Type)
+	binary.BigEndian.PutUint16(buf[2:], h.Length)
+}
+
+// Packet is a single RTCP packet of a compound packet.
+type Packet interface {
+	// Marshal serializes the packet, header included.
+	Marshal() ([]byte, error)
+	// Unmarshal parses a whole packet, header included. The length in the
+	// header must match the length of the given data.
+	Unmarshal(data []byte) error
+	// MarshalSize returns the length of the serialized packet.
+	MarshalSize() int
+}
+
+// Unmarshal parses a compound RTCP packet.
+func Unmarshal(data []byte) ([]Packet, error) {
+	var packets []Packet
+
+	for len(data) > 0 {
+		var header Header
+		if err := header.Unmarshal(data); err != nil {
+			return nil, err
+		}
+
+		size := (int(header.Length) + 1) * 4
+		if len(data) < size {
+			return nil, ErrPacketTooShort
+		}
+
+		packet := newPacket(header, data[:size])
+		if err := packet.Unmarshal(data[:size]); err != nil {
+			return nil, err
+		}
+
+		packets = append(packets, packet)
+		data = data[size:]
+	}
+
+	if len(packets) == 0 {
+		return nil, ErrPacketTooShort
+	}
+
+	return packets, nil
+}
+
+// Marshal serializes the given packets into a compound RTCP packet.
+func Marshal(packets []Packet) ([]byte, error) {
+	var data []byte
+	for _, packet := range packets {
+		buf, err := packet.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, buf...)
+	}
+	return data, nil
+}
+
+func newPacket(header Header, data []byte) Packet {
+	switch header.Type {
+	case PacketTypeSR:
+		return &SenderReport{}
+	case PacketTypeRR:
+		return &ReceiverReport{}
+	case PacketTypeSDES:
+		return &SourceDescription{}
+	case PacketTypeBYE:
+		return &Bye{}
+	case PacketTypeAPP:
+		return &App{}
+	case PacketTypeRTPFB:
+		switch header.Count {
+		case FormatNack:
+			return &Nack{}
+		case FormatTcc:
+			return &TransportFeedback{}
+		}
+	case PacketTypePSFB:
+		switch header.Count {
+		case FormatPli:
+			return &Pli{}
+		case FormatFir:
+			return &Fir{}
+		case FormatAfb:
+			if isRemb(data) {
+				return &Remb{}
+			}
+		}
+	case PacketTypeXR:
+		return &ExtendedReport{}
+	}
+	return &RawPacket{}
+}
+
+// unmarshalHeader parses and validates the header of the given packet, and
+// returns its body without padding.
+func unmarshalHeader(data []byte, packetType PacketType) (header Header, body []byte, err error) {
+	if err = header.Unmarshal(data); err != nil {
+		return
+	}
+	if header.Type != packetType {
+		err = ErrWrongType
+		return
+	}
+	size := (int(header.Length) + 1) * 4
+	if len(data) != size {
+		err = ErrBadLength
+		return
+	}
+	body = data[headerLength:]
+	if header.Padding {
+		padding := int(data[size-1])
+		if padding == 0 || padding > len(body) {
+			err = ErrBadPadding
+			return
+		}
+		body = body[:len(body)-padding]
+	}
+	return
+}
+
+// newPacketBuffer allocates a packet of the given size and writes its header.
+func newPacketBuffer(size int, count uint8, packetType PacketType) ([]byte, error) {
+	if size > maxPacketLength || size%4 != 0 {
+		return nil, ErrBadLength
+	}
+	buf := make([]byte, size)
+	Header{
+		Count:  count,
+		Type:   packetType,
+		Length: uint16(size/4 - 1),
+	}.marshalTo(buf)
+	return buf, nil
+}
+
+// padTo4 rounds up the given length to a multiple of 4.
+func padTo4(length int) int {
+	return (length + 3) &^ 3
+}
+
+// RawPacket is a packet of an unsupported type, kept as is.
+type RawPacket struct {
+	Header Header
+	Data   []byte
+}
+
+func (p *RawPacket) Unmarshal(data []byte) error {
+	if err := p.Header.Unmarshal(data); err != nil {
+		return err
+	}
+	if len(data) != (int(p.Header.Length)+1)*4 {
+		return ErrBadLength
+	}
+	p.Data = append([]byte(nil), data...)
+	return nil
+}
+
+func (p *RawPacket) Marshal() ([]byte, error) {
+	return append([]byte(nil), p.Data...), nil
+}
+
+func (p *RawPacket) MarshalSize() int {
+	return len(p.Data)
+}
@@ -0,0 +1,290 @@
+package rtcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name   string
+		packet Packet
+	}{
+		{"SR", &SenderReport{
+			Ssrc:        0x01020304,
+			NtpSec:      0xda8bd1fc,
+			NtpFrac:     0xdddda05a,
+			RtpTs:       0xaaf4edd5,
+			PacketCount: 1,
+			OctetCount:  2,
+			Reports: []ReceptionReport{{
+				Ssrc:         0xbc5e9a40,
+				FractionLost: 10,
+				TotalLost:    -2,
+				LastSeq:      0x46e1,
+				Jitter:       273,
+				Lsr:          0x9f36432,
+				Dlsr:         150137,
+			}},
+		}},
+		{"RR", &ReceiverReport{
+			Ssrc: 0x902f9e2e,
+			Reports: []ReceptionReport{
+				{Ssrc: 0xbc5e9a40, FractionLost: 0, TotalLost: 0x7FFFFF, LastSeq: 0x46e1},
+				{Ssrc: 0xbc5e9a41, FractionLost: 255, TotalLost: 3},
+			},
+		}},
+		{"RR without reports", &ReceiverReport{Ssrc: 0x902f9e2e}},
+		{"SDES", &SourceDescription{Chunks: []SdesChunk{
+			{Ssrc: 0x01020304, Items: []SdesItem{{Type: SdesItemCname, Text: "abcd"}}},
+			{Ssrc: 0x05060708, Items: []SdesItem{
+				{Type: SdesItemCname, Text: "ab"},
+				{Type: SdesItemTool, Text: "mediasoup"},
+			}},
+		}}},
+		{"BYE", &Bye{Ssrcs: []uint32{0x01020304, 0x05060708}, Reason: "bye"}},
+		{"BYE without reason", &Bye{Ssrcs: []uint32{0x01020304}}},
+		{"APP", &App{Subtype: 3, Ssrc: 0x01020304, Name: [4]byte{'t', 'e', 's', 't'}, Data: []byte{1, 2, 3, 4}}},
+		{"NACK", &Nack{SenderSsrc: 1, MediaSsrc: 2, Items: []NackItem{{Pid: 100, Blp: 0x8001}, {Pid: 200}}}},
+		{"PLI", &Pli{SenderSsrc: 1, MediaSsrc: 2}},
+		{"FIR", &Fir{SenderSsrc: 1, Entries: []FirEntry{{Ssrc: 2, SequenceNumber: 7}, {Ssrc: 3, SequenceNumber: 255}}}},
+		{"REMB", &Remb{SenderSsrc: 1, Bitrate: 1_234_432, Ssrcs: []uint32{2, 3}}},
+		{"TWCC", &TransportFeedback{
+			SenderSsrc:          1,
+			MediaSsrc:           2,
+			BaseSequenceNumber:  65530,
+			ReferenceTime:       -5,
+			FeedbackPacketCount: 9,
+			Packets: []TransportFeedbackPacket{
+				{Received: true, Delta: 4},
+				{},
+				{Received: true, Delta: 300},
+				{Received: true, Delta: -4},
+				{Received: true, Delta: 255},
+			},
+		}},
+		{"XR", &ExtendedReport{
+			Ssrc: 0x01020304,
+			Blocks: []XrBlock{
+				&ReceiverReferenceTimeBlock{NtpSec: 0xda8bd1fc, NtpFrac: 0xdddda05a},
+				&DlrrBlock{Items: []DlrrItem{{Ssrc: 2, LastRr: 3, DelaySinceLastRr: 4}, {Ssrc: 5, LastRr: 6, DelaySinceLastRr: 7}}},
+				&UnknownXrBlock{Type: 42, TypeSpecific: 1, Data: []byte{1, 2, 3, 4}},
+			},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := tc.packet.Marshal()
+			require.NoError(t, err)
+			require.Len(t, data, tc.packet.MarshalSize())
+			require.Zero(t, len(data)%4)
+
+			packets, err := Unmarshal(data)
+			require.NoError(t, err)
+			require.Len(t, packets, 1)
+			require.Equal(t, tc.packet, packets[0])
+		})
+	}
+
+	t.Run("compound", func(t *testing.T) {
+		var packets []Packet
+		for _, tc := range testCases {
+			packets = append(packets, tc.packet)
+		}
+
+		data, err := Marshal(packets)
+		require.NoError(t, err)
+
+		parsed, err := Unmarshal(data)
+		require.NoError(t, err)
+		require.Equal(t, packets, parsed)
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("parses a compound packet captured from a browser", func(t *testing.T) {
+		data := []byte{
+			// RR.
+			0x81, 0xc9, 0x00, 0x07,
+			0x90, 0x2f, 0x9e, 0x2e,
+			0xbc, 0x5e, 0x9a, 0x40,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x46, 0xe1,
+			0x00, 0x00, 0x01, 0x11,
+			0x09, 0xf3, 0x64, 0x32,
+			0x00, 0x02, 0x4a, 0x79,
+			// SDES.
+			0x81, 0xca, 0x00, 0x06,
+			0x90, 0x2f, 0x9e, 0x2e,
+			0x01, 0x0e, 0x2f, 0x76,
+			0x6d, 0x4c, 0x67, 0x4e,
+			0x53, 0x36, 0x6e, 0x35,
+			0x43, 0x72, 0x31, 0x6c,
+			0x00, 0x00, 0x00, 0x00,
+			// PLI.
+			0x81, 0xce, 0x00, 0x02,
+			0x90, 0x2f, 0x9e, 0x2e,
+			0x90, 0x2f, 0x9e, 0x2e,
+		}
+
+		packets, err := Unmarshal(data)
+		require.NoError(t, err)
+		require.Len(t, packets, 3)
+
+		rr, ok := packets[0].(*ReceiverReport)
+		require.True(t, ok)
+		require.EqualValues(t, 0x902f9e2e, rr.Ssrc)
+		require.Equal(t, []ReceptionReport{{
+			Ssrc:    0xbc5e9a40,
+			LastSeq: 0x46e1,
+			Jitter:  273,
+			Lsr:     0x9f36432,
+			Dlsr:    150137,
+		}}, rr.Reports)
+
+		sdes, ok := packets[1].(*SourceDescription)
+		require.True(t, ok)
+		require.Equal(t, []SdesChunk{{
+			Ssrc:  0x902f9e2e,
+			Items: []SdesItem{{Type: SdesItemCname, Text: "/vmLgNS6n5Cr1l"}},
+		}}, sdes.Chunks)
+
+		require.Equal(t, &Pli{SenderSsrc: 0x902f9e2e, MediaSsrc: 0x902f9e2e}, packets[2])
+	})
+
+	t.Run("keeps unsupported packets as raw packets", func(t *testing.T) {
+		// AFB which is not a REMB.
+		data := []byte{
+			0x8f, 0xce, 0x00, 0x03,
+			0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x00,
+			'A', 'B', 'C', 'D',
+		}
+
+		packets, err := Unmarshal(data)
+		require.NoError(t, err)
+		require.Equal(t, &RawPacket{
+			Header: Header{Count: FormatAfb, Type: PacketTypePSFB, Length: 3},
+			Data:   data,
+		}, packets[0])
+
+		marshaled, err := Marshal(packets)
+		require.NoError(t, err)
+		require.Equal(t, data, marshaled)
+	})
+
+	t.Run("strips padding", func(t *testing.T) {
+		data := []byte{
+			0xa0, 0xc9, 0x00, 0x02,
+			0x90, 0x2f, 0x9e, 0x2e,
+			0x00, 0x00, 0x00, 0x04,
+		}
+
+		packets, err := Unmarshal(data)
+		require.NoError(t, err)
+		require.Equal(t, &ReceiverReport{Ssrc: 0x902f9e2e}, packets[0])
+	})
+
+	t.Run("rejects invalid packets", func(t *testing.T) {
+		testCases := []struct {
+			name string
+			data []byte
+			err  error
+		}{
+			{"empty", nil, ErrPacketTooShort},
+			{"short header", []byte{0x80, 0xc9, 0x00}, ErrPacketTooShort},
+			{"bad version", []byte{0x40, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrBadVersion},
+			{"length beyond data", []byte{0x80, 0xc9, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01}, ErrPacketTooShort},
+			{"missing report block", []byte{0x81, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrPacketTooShort},
+			{"zero padding", []byte{0xa0, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, ErrBadPadding},
+			{"padding beyond body", []byte{0xa0, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x05}, ErrBadPadding},
+			{"short sender report", []byte{0x80, 0xc8, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrPacketTooShort},
+			{"PLI with FCI", []byte{
+				0x81, 0xce, 0x00, 0x03,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+			}, ErrBadLength},
+			{"NACK without items", []byte{
+				0x81, 0xcd, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+			}, ErrBadLength},
+			{"FIR with truncated entry", []byte{
+				0x84, 0xce, 0x00, 0x03,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+			}, ErrBadLength},
+			{"REMB with wrong ssrc count", []byte{
+				0x8f, 0xce, 0x00, 0x05,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+				'R', 'E', 'M', 'B', 0x02, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+			}, ErrBadLength},
+			{"SDES without end of items", []byte{
+				0x81, 0xca, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01, 0x01, 0x02, 0x61, 0x62,
+			}, ErrPacketTooShort},
+			{"SDES item beyond chunk", []byte{
+				0x81, 0xca, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01, 0x01, 0x05, 0x61, 0x62,
+			}, ErrPacketTooShort},
+			{"BYE with truncated reason", []byte{
+				0x81, 0xcb, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01, 0x05, 0x61, 0x62, 0x63,
+			}, ErrPacketTooShort},
+			{"XR block beyond packet", []byte{
+				0x80, 0xcf, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01, 0x04, 0x00, 0x00, 0x02,
+			}, ErrPacketTooShort},
+			{"XR RRTR with wrong length", []byte{
+				0x80, 0xcf, 0x00, 0x03,
+				0x00, 0x00, 0x00, 0x01, 0x04, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+			}, ErrBadLength},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := Unmarshal(tc.data)
+				require.ErrorIs(t, err, tc.err)
+			})
+		}
+	})
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("rejects invalid packets", func(t *testing.T) {
+		_, err := (&ReceiverReport{Reports: make([]ReceptionReport, 32)}).Marshal()
+		require.ErrorIs(t, err, ErrTooManyItems)
+
+		_, err = (&Bye{Reason: string(make([]byte, 256))}).Marshal()
+		require.ErrorIs(t, err, ErrTextTooLong)
+
+		_, err = (&App{Data: []byte{1}}).Marshal()
+		require.ErrorIs(t, err, ErrBadLength)
+
+		_, err = (&Nack{}).Marshal()
+		require.ErrorIs(t, err, ErrInvalidFeedback)
+
+		_, err = (&TransportFeedback{}).Marshal()
+		require.ErrorIs(t, err, ErrInvalidFeedback)
+	})
+
+	t.Run("clamps total lost and rounds down REMB bitrate", func(t *testing.T) {
+		data, err := (&ReceiverReport{Reports: []ReceptionReport{{TotalLost: -0x1000000}}}).Marshal()
+		require.NoError(t, err)
+
+		rr := &ReceiverReport{}
+		require.NoError(t, rr.Unmarshal(data))
+		require.EqualValues(t, -0x800000, rr.Reports[0].TotalLost)
+
+		data, err = (&Remb{Bitrate: 1<<20 + 1}).Marshal()
+		require.NoError(t, err)
+
+		remb := &Remb{}
+		require.NoError(t, remb.Unmarshal(data))
+		require.EqualValues(t, 1<<20, remb.Bitrate)
+	})
+
+	t.Run("NACK reports lost sequence numbers", func(t *testing.T) {
+		nack := &Nack{Items: []NackItem{{Pid: 65534, Blp: 0x0005}}}
+		require.Equal(t, []uint16{65534, 65535, 1}, nack.GetSequenceNumbers())
+	})
+}
@@ -0,0 +1,142 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+// SDES item types.
+const (
+	SdesItemEnd   uint8 = 0
+	SdesItemCname uint8 = 1
+	SdesItemName  uint8 = 2
+	SdesItemEmail uint8 = 3
+	SdesItemPhone uint8 = 4
+	SdesItemLoc   uint8 = 5
+	SdesItemTool  uint8 = 6
+	SdesItemNote  uint8 = 7
+	SdesItemPriv  uint8 = 8
+)
+
+type SdesItem struct {
+	Type uint8
+	Text string
+}
+
+// SdesChunk holds the items describing a source.
+type SdesChunk struct {
+	Ssrc  uint32
+	Items []SdesItem
+}
+
+func (c SdesChunk) marshalSize() int {
+	size := ssrcLength
+	for _, item := range c.Items {
+		size += 2 + len(item.Text)
+	}
+	// The list of items is terminated by at least one null octet.
+	return padTo4(size + 1)
+}
+
+// SourceDescription is a RTCP SDES packet.
+type SourceDescription struct {
+	Chunks []SdesChunk
+}
+
+func (p *SourceDescription) Unmarshal(data []byte) error {
+	header, body, err := unmarshalHeader(data, PacketTypeSDES)
+	if err != nil {
+		return err
+	}
+
+	p.Chunks = nil
+	offset := 0
+
+	for i := 0; i < int(header.Count); i++ {
+		if len(body)-offset < ssrcLength {
+			return ErrPacketTooShort
+		}
+
+		chunk := SdesChunk{Ssrc: binary.BigEndian.Uint32(body[offset:])}
+		offset += ssrcLength
+
+		for {
+			if offset >= len(body) {
+				return ErrPacketTooShort
+			}
+
+			itemType := body[offset]
+			if itemType == SdesItemEnd {
+				// Skip the null octets up to the next 32 bits boundary.
+				offset = padTo4(offset + 1)
+				break
+			}
+
+			if len(body)-offset < 2 {
+				return ErrPacketTooShort
+			}
+
+			length := int(body[offset+1])
+			if len(body)-offset-2 < length {
+				return ErrPacketTooShort
+			}
+
+			chunk.Items = append(chunk.Items, SdesItem{
+				Type: itemType,
+				Text: string(body[offset+2 : offset+2+length]),
+			})
+			offset += 2 + length
+		}
+
+		p.Chunks = append(p.Chunks, chunk)
+	}
+
+	if offset > len(body) {
+		return ErrPacketTooShort
+	}
+
+	return nil
+}
+
+func (p *SourceDescription) Marshal() ([]byte, error) {
+	if len(p.Chunks) > maxCount {
+		return nil, ErrTooManyItems
+	}
+	for _, chunk := range p.Chunks {
+		for _, item := range chunk.Items {
+			if len(item.Text) > 255 {
+				return nil, ErrTextTooLong
+			}
+		}
+	}
+
+	buf, err := newPacketBuffer(p.MarshalSize(), uint8(len(p.Chunks)), PacketTypeSDES)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := headerLength
+	for _, chunk := range p.Chunks {
+		binary.BigEndian.PutUint32(buf[offset:], chunk.Ssrc)
+		itemOffset := offset + ssrcLength
+
+		for _, item := range chunk.Items {
+			buf[itemOffset] = item.Type
+			buf[itemOffset+1] = uint8(len(item.Text))
+			copy(buf[itemOffset+2:], item.Text)
+			itemOffset += 2 + len(item.Text)
+		}
+
+		// Null octets are already there.
+		offset += chunk.marshalSize()
+	}
+
+	return buf, nil
+}
+
+func (p *SourceDescription) MarshalSize() int {
+	size := headerLength
+	for _, chunk := range p.Chunks {
+		size += chunk.marshalSize()
+	}
+	return size
+}
@@ -0,0 +1,265 @@
+package rtcp
+
+import (
+	"encoding/binary"
+)
+
+// Packet status symbols.
+const (
+	TccStatusNotReceived     uint8 = 0
+	TccStatusSmallDelta      uint8 = 1
+	TccStatusLargeOrNegDelta uint8 = 2
+)
+
+const (
+	tccFixedLength       = 8
+	tccChunkLength       = 2
+	tccMaxRunLength      = 1<<13 - 1
+	tccOneBitCapacity    = 14
+	tccTwoBitCapacity    = 7
+	tccMaxPacketStatuses = 1<<16 - 1
+
+	// TccDeltaTickUs is the resolution of the receive deltas.
+	TccDeltaTickUs = 250
+	// TccReferenceTimeTickMs is the resolution of the reference time.
+	TccReferenceTimeTickMs = 64
+)
+
+// TransportFeedbackPacket is the status of a packet in a transport-wide
+// congestion control feedback.
+type TransportFeedbackPacket struct {
+	Received bool
+	// Delta is the receive time of the packet relative to the previous
+	// received one, or to the reference time for the first one, in 250 us
+	// ticks.
+	Delta int16
+}
+
+func (p TransportFeedbackPacket) symbol() uint8 {
+	switch {
+	case !p.Received:
+		return TccStatusNotReceived
+	case p.Delta >= 0 && p.Delta <= 0xFF:
+		return TccStatusSmallDelta
+	default:
+		return TccStatusLargeOrNegDelta
+	}
+}
+
+// TransportFeedback is a RTCP transport-wide congestion control feedback
+// packet, as defined in draft-holmer-rmcat-transport-wide-cc-extensions-01.
+type TransportFeedback struct {
+	SenderSsrc         uint32
+	MediaSsrc          uint32
+	BaseSequenceNumber uint16
+	// ReferenceTime is a 24 bits signed value in multiples of 64 ms.
+	ReferenceTime       int32
+	FeedbackPacketCount uint8
+	// Packets holds the status of each packet, starting from the base sequence
+	// number.
+	Packets []TransportFeedbackPacket
+}
+
+func (p *TransportFeedback) Unmarshal(data []byte) error {
+	senderSsrc, mediaSsrc, fci, err := unmarshalFeedback(data, PacketTypeRTPFB, FormatTcc)
+	if err != nil {
+		return err
+	}
+	if len(fci) < tccFixedLength {
+		return ErrPacketTooShort
+	}
+
+	packetStatusCount := int(binary.BigEndian.Uint16(fci[2:]))
+	if packetStatusCount == 0 {
+		return ErrInvalidFeedback
+	}
+
+	p.SenderSsrc = senderSsrc
+	p.MediaSsrc = mediaSsrc
+	p.BaseSequenceNumber = binary.BigEndian.Uint16(fci)
+	// Sign extend the 24 bits value.
+	p.ReferenceTime = int32(binary.BigEndian.Uint32(fci[4:])) >> 8
+	p.FeedbackPacketCount = fci[7]
+
+	offset := tccFixedLength
+	symbols := make([]uint8, 0, packetStatusCount)
+
+	// Packet status chunks.
+	for len(symbols) < packetStatusCount {
+		if len(fci)-offset < tccChunkLength {
+			return ErrPacketTooShort
+		}
+
+		chunk := binary.BigEndian.Uint16(fci[offset:])
+		offset += tccChunkLength
+		remaining := packetStatusCount - len(symbols)
+
+		switch {
+		// Run length chunk.
+		case chunk>>15 == 0:
+			symbol := uint8(chunk>>13) & 0x03
+			runLength := int(chunk & 0x1FFF)
+			for i := 0; i < min(runLength, remaining); i++ {
+				symbols = append(symbols, symbol)
+			}
+		// Status vector chunk with 1 bit symbols.
+		case (chunk>>14)&0x01 == 0:
+			for i := 0; i < min(tccOneBitCapacity, remaining); i++ {
+				symbols = append(symbols, uint8(chunk>>(13-i))&0x01)
+			}
+		// Status vector chunk with 2 bits symbols.
+		default:
+			for i := 0; i < min(tccTwoBitCapacity, remaining); i++ {
+				symbols = append(symbols, uint8(chunk>>(12-2*i))&0x03)
+			}
+		}
+	}
+
+	// Receive deltas.
+	p.Packets = make([]TransportFeedbackPacket, 0, packetStatusCount)
+	for _, symbol := range symbols {
+		var packet TransportFeedbackPacket
+
+		switch symbol {
+		case TccStatusNotReceived:
+		case TccStatusSmallDelta:
+			if len(fci)-offset < 1 {
+				return ErrPacketTooShort
+			}
+			packet.Received = true
+			packet.Delta = int16(fci[offset])
+			offset++
+		case TccStatusLargeOrNegDelta:
+			if len(fci)-offset < 2 {
+				return ErrPacketTooShort
+			}
+			packet.Received = true
+			packet.Delta = int16(binary.BigEndian.Uint16(fci[offset:]))
+			offset += 2
+		default:
+			return ErrInvalidFeedback
+		}
+
+		p.Packets = append(p.Packets, packet)
+	}
+
+	// Only zero padding up to the next 32 bits boundary may follow.
+	if len(fci)-offset > 3 {
+		return ErrBadLength
+	}
+
+	return nil
+}
+
+func (p *TransportFeedback) Marshal() ([]byte, error) {
+	if len(p.Packets) == 0 {
+		return nil, ErrInvalidFeedback
+	}
+	if len(p.Packets) > tccMaxPacketStatuses {
+		return nil, ErrTooManyItems
+	}
+
+	chunks := p.chunks()
+	contentLength := p.contentLength(chunks)
+	size := padTo4(contentLength)
+
+	buf, fci, err := newFeedbackBuffer(size, PacketTypeRTPFB, FormatTcc, p.SenderSsrc, p.MediaSsrc)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.BigEndian.PutUint16(fci, p.BaseSequenceNumber)
+	binary.BigEndian.PutUint16(fci[2:], uint16(len(p.Packets)))
+	binary.BigEndian.PutUint32(fci[4:], uint32(p.ReferenceTime)<<8|uint32(p.FeedbackPacketCount))
+
+	offset := tccFixedLength
+	for _, chunk := range chunks {
+		binary.BigEndian.PutUint16(fci[offset:], chunk)
+		offset += tccChunkLength
+	}
+
+	for _, packet := range p.Packets {
+		switch packet.symbol() {
+		case TccStatusSmallDelta:
+			fci[offset] = uint8(packet.Delta)
+			offset++
+		case TccStatusLargeOrNegDelta:
+			binary.BigEndian.PutUint16(fci[offset:], uint16(packet.Delta))
+			offset += 2
+		}
+	}
+
+	// Padding.
+	if padding := size - contentLength; padding > 0 {
+		buf[0] |= 0x20
+		buf[size-1] = uint8(padding)
+	}
+
+	return buf, nil
+}
+
+func (p *TransportFeedback) MarshalSize() int {
+	return padTo4(p.contentLength(p.chunks()))
+}
+
+func (p *TransportFeedback) contentLength(chunks []uint16) int {
+	length := headerLength + feedbackHeaderLength + tccFixedLength + len(chunks)*tccChunkLength
+	for _, packet := range p.Packets {
+		switch packet.symbol() {
+		case TccStatusSmallDelta:
+			length++
+		case TccStatusLargeOrNegDelta:
+			length += 2
+		}
+	}
+	return length
+}
+
+// chunks encodes the packet statuses, using run length chunks for runs of the
+// same symbol and status vector chunks otherwise.
+func (p *TransportFeedback) chunks() []uint16 {
+	var chunks []uint16
+
+	for i := 0; i < len(p.Packets); {
+		symbol := p.Packets[i].symbol()
+		runLength := 1
+		for i+runLength < len(p.Packets) &&
+			runLength < tccMaxRunLength &&
+			p.Packets[i+runLength].symbol() == symbol {
+			runLength++
+		}
+
+		if runLength >= tccTwoBitCapacity || i+runLength == len(p.Packets) {
+			chunks = append(chunks, uint16(symbol)<<13|uint16(runLength))
+			i += runLength
+			continue
+		}
+
+		// Use 1 bit symbols if the next ones are all small deltas or not
+		// received packets.
+		oneBit := true
+		for j := i; j < min(i+tccOneBitCapacity, len(p.Packets)); j++ {
+			if p.Packets[j].symbol() == TccStatusLargeOrNegDelta {
+				oneBit = false
+				break
+			}
+		}
+
+		chunk := uint16(1 << 15)
+		if oneBit {
+			for j := 0; j < tccOneBitCapacity && i < len(p.Packets); j++ {
+				chunk |= uint16(p.Packets[i].symbol()) << (13 - j)
+				i++
+			}
+		} else {
+			chunk |= 1 << 14
+			for j := 0; j < tccTwoBitCapacity && i < len(p.Packets); j++ {
+				chunk |= uint16(p.Packets[i].symbol()) << (12 - 2*j)
+				i++
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
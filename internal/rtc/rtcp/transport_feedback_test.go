@@ -0,0 +1,108 @@
+package rtcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportFeedback(t *testing.T) {
+	t.Run("parses every kind of packet status chunk", func(t *testing.T) {
+		data := []byte{
+			0xaf, 0xcd, 0x00, 0x08,
+			0x00, 0x00, 0x00, 0x01, // Sender ssrc.
+			0x00, 0x00, 0x00, 0x02, // Media ssrc.
+			0x00, 0x0a, 0x00, 0x13, // Base sequence number 10, 19 statuses.
+			0xff, 0xff, 0xfe, 0x07, // Reference time -2, feedback packet count 7.
+			0xa8, 0x00, // 1 bit vector: received, not received, received, 11 x not received.
+			0x20, 0x03, // Run length: 3 x small delta.
+			0xc9, 0x00, // 2 bits vector: not received, large delta, small delta, 4 x not received.
+			0x01, 0x02, 0x03, 0x04, 0x05, // Small deltas.
+			0xff, 0xfe, // Large delta.
+			0x00, 0x00, 0x03, // Padding.
+		}
+
+		packets, err := Unmarshal(data)
+		require.NoError(t, err)
+		require.Len(t, packets, 1)
+
+		feedback, ok := packets[0].(*TransportFeedback)
+		require.True(t, ok)
+		require.EqualValues(t, 1, feedback.SenderSsrc)
+		require.EqualValues(t, 2, feedback.MediaSsrc)
+		require.EqualValues(t, 10, feedback.BaseSequenceNumber)
+		require.EqualValues(t, -2, feedback.ReferenceTime)
+		require.EqualValues(t, 7, feedback.FeedbackPacketCount)
+
+		expected := []TransportFeedbackPacket{
+			{Received: true, Delta: 1}, {}, {Received: true, Delta: 2},
+			{}, {}, {}, {}, {}, {}, {}, {}, {}, {}, {},
+			{Received: true, Delta: 3}, {Received: true, Delta: 4}, {Received: true, Delta: 5},
+			{}, {Received: true, Delta: -2},
+		}
+		// The last status of the 2 bits vector chunk is beyond the status count.
+		require.Len(t, feedback.Packets, 19)
+		require.Equal(t, expected, feedback.Packets)
+	})
+
+	t.Run("encodes long runs with run length chunks", func(t *testing.T) {
+		feedback := &TransportFeedback{}
+		for i := 0; i < 100; i++ {
+			feedback.Packets = append(feedback.Packets, TransportFeedbackPacket{Received: true, Delta: 1})
+		}
+		feedback.Packets = append(feedback.Packets, TransportFeedbackPacket{}, TransportFeedbackPacket{Received: true, Delta: 1000})
+
+		require.Equal(t, []uint16{0x2000 | 100, 0xc000 | 0x02<<10}, feedback.chunks())
+
+		data, err := feedback.Marshal()
+		require.NoError(t, err)
+
+		parsed := &TransportFeedback{}
+		require.NoError(t, parsed.Unmarshal(data))
+		require.Equal(t, feedback, parsed)
+	})
+
+	t.Run("rejects truncated feedback", func(t *testing.T) {
+		testCases := []struct {
+			name string
+			data []byte
+			err  error
+		}{
+			{"zero status count", []byte{
+				0x8f, 0xcd, 0x00, 0x04,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+			}, ErrInvalidFeedback},
+			{"missing chunks", []byte{
+				0x8f, 0xcd, 0x00, 0x04,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x0a, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07,
+			}, ErrPacketTooShort},
+			{"missing deltas", []byte{
+				0x8f, 0xcd, 0x00, 0x05,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x0a, 0x00, 0x03, 0x00, 0x00, 0x00, 0x07,
+				0x40, 0x03, 0x00, 0x01,
+			}, ErrPacketTooShort},
+			{"reserved symbol", []byte{
+				0x8f, 0xcd, 0x00, 0x05,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x0a, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07,
+				0x60, 0x01, 0x00, 0x00,
+			}, ErrInvalidFeedback},
+			{"trailing data", []byte{
+				0x8f, 0xcd, 0x00, 0x06,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x0a, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07,
+				0x20, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+			}, ErrBadLength},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := Unmarshal(tc.data)
+				require.ErrorIs(t, err, tc.err)
+			})
+		}
+	})
+}
@@ -0,0 +1,369 @@
+package rtc
+
+import (
+	"encoding/binary"
+
+	"github.com/jiyeyuran/mediasoup/internal/util"
+	"github.com/pion/rtp"
+)
+
+// Supported RTP header extension URIs.
+const (
+	MidHeaderExtensionUri                  = "urn:ietf:params:rtp-hdrext:sdes:mid"
+	RidHeaderExtensionUri                  = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+	RepairedRidHeaderExtensionUri          = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+	AbsSendTimeHeaderExtensionUri          = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+	TransportWideCc01HeaderExtensionUri    = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+	FrameMarkingHeaderExtensionUri         = "urn:ietf:params:rtp-hdrext:framemarking"
+	FrameMarking07HeaderExtensionUri       = "http://tools.ietf.org/html/draft-ietf-avtext-framemarking-07"
+	SsrcAudioLevelHeaderExtensionUri       = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+	VideoOrientationHeaderExtensionUri     = "urn:3gpp:video-orientation"
+	ToffsetHeaderExtensionUri              = "urn:ietf:params:rtp-hdrext:toffset"
+	AbsCaptureTimeHeaderExtensionUri       = "http://www.webrtc.org/experiments/rtp-hdrext/abs-capture-time"
+	PlayoutDelayHeaderExtensionUri         = "http://www.webrtc.org/experiments/rtp-hdrext/playout-delay"
+	DependencyDescriptorHeaderExtensionUri = "https://aomediacodec.github.io/av1-rtp-spec/#dependency-descriptor-rtp-header-extension"
+)
+
+// MaxMidLength is the maximum length of the mid, rid and repaired-rid values.
+const MaxMidLength = 16
+
+// RFC 8285 header extension profiles.
+const (
+	oneByteHeaderExtensionProfile = 0xBEDE
+	twoByteHeaderExtensionProfile = 0x1000
+)
+
+// RtpHeaderExtensionIds holds the negotiated ids of the supported header
+// extensions, zero meaning not negotiated.
+type RtpHeaderExtensionIds struct {
+	Mid                  uint8
+	Rid                  uint8
+	RRid                 uint8
+	AbsSendTime          uint8
+	TransportWideCc01    uint8
+	FrameMarking         uint8
+	FrameMarking07       uint8
+	SsrcAudioLevel       uint8
+	VideoOrientation     uint8
+	Toffset              uint8
+	AbsCaptureTime       uint8
+	PlayoutDelay         uint8
+	DependencyDescriptor uint8
+}
+
+// NewRtpHeaderExtensionIds returns the ids of the supported header extensions
+// in the given negotiated list. Encrypted extensions are ignored since they
+// cannot be read.
+func NewRtpHeaderExtensionIds(headerExtensions []RtpHeaderExtensionParameters) RtpHeaderExtensionIds {
+	var ids RtpHeaderExtensionIds
+
+	for _, ext := range headerExtensions {
+		if ext.Encrypt {
+			continue
+		}
+
+		switch ext.Uri {
+		case MidHeaderExtensionUri:
+			ids.Mid = ext.Id
+		case RidHeaderExtensionUri:
+			ids.Rid = ext.Id
+		case RepairedRidHeaderExtensionUri:
+			ids.RRid = ext.Id
+		case AbsSendTimeHeaderExtensionUri:
+			ids.AbsSendTime = ext.Id
+		case TransportWideCc01HeaderExtensionUri:
+			ids.TransportWideCc01 = ext.Id
+		case FrameMarkingHeaderExtensionUri:
+			ids.FrameMarking = ext.Id
+		case FrameMarking07HeaderExtensionUri:
+			ids.FrameMarking07 = ext.Id
+		case SsrcAudioLevelHeaderExtensionUri:
+			ids.SsrcAudioLevel = ext.Id
+		case VideoOrientationHeaderExtensionUri:
+			ids.VideoOrientation = ext.Id
+		case ToffsetHeaderExtensionUri:
+			ids.Toffset = ext.Id
+		case AbsCaptureTimeHeaderExtensionUri:
+			ids.AbsCaptureTime = ext.Id
+		case PlayoutDelayHeaderExtensionUri:
+			ids.PlayoutDelay = ext.Id
+		case DependencyDescriptorHeaderExtensionUri:
+			ids.DependencyDescriptor = ext.Id
+		}
+	}
+
+	return ids
+}
+
+// AbsCaptureTime is the content of the abs-capture-time header extension.
+type AbsCaptureTime struct {
+	// AbsoluteCaptureTimestamp is a UQ32.32 NTP timestamp.
+	AbsoluteCaptureTimestamp uint64
+	// EstimatedCaptureClockOffset is a Q32.32 value, only meaningful if
+	// HasEstimatedCaptureClockOffset is true.
+	EstimatedCaptureClockOffset    int64
+	HasEstimatedCaptureClockOffset bool
+}
+
+// readExtension returns the value of the extension with the given id, or nil
+// if not negotiated or not present.
+func (p *RtpPacket) readExtension(id uint8) []byte {
+	if id == 0 {
+		return nil
+	}
+	return p.GetExtension(id)
+}
+
+// readSdesExtension returns the value of a mid, rid or repaired-rid extension.
+func (p *RtpPacket) readSdesExtension(id uint8) (string, bool) {
+	value := p.readExtension(id)
+	if len(value) == 0 || len(value) > MaxMidLength {
+		return "", false
+	}
+	return string(value), true
+}
+
+func (p *RtpPacket) ReadMid() (mid string, ok bool) {
+	return p.readSdesExtension(p.headerExtensionIds.Mid)
+}
+
+// UpdateMid rewrites the mid value, which may change the packet size. It
+// returns false if the mid extension is not negotiated or the value is too
+// long.
+func (p *RtpPacket) UpdateMid(mid string) bool {
+	if p.headerExtensionIds.Mid == 0 || len(mid) == 0 || len(mid) > MaxMidLength {
+		return false
+	}
+	if err := p.SetExtension(p.headerExtensionIds.Mid, []byte(mid)); err != nil {
+		return false
+	}
+	p.Size = uint64(p.MarshalSize())
+	return true
+}
+
+func (p *RtpPacket) ReadRid() (rid string, ok bool) {
+	return p.readSdesExtension(p.headerExtensionIds.Rid)
+}
+
+func (p *RtpPacket) ReadRepairedRid() (rrid string, ok bool) {
+	return p.readSdesExtension(p.headerExtensionIds.RRid)
+}
+
+// ReadAbsSendTime returns the 24 bits 6.18 fixed point send time in seconds.
+func (p *RtpPacket) ReadAbsSendTime() (absSendTime uint32, ok bool) {
+	return p.readAbsSendTime(p.headerExtensionIds.AbsSendTime)
+}
+
+// readAbsSendTime reads the abs-send-time extension with the given id.
+func (p *RtpPacket) readAbsSendTime(id uint8) (absSendTime uint32, ok bool) {
+	value := p.readExtension(id)
+	if len(value) != 3 {
+		return 0, false
+	}
+	return uint32(value[0])<<16 | uint32(value[1])<<8 | uint32(value[2]), true
+}
+
+// UpdateAbsSendTime sets the send time to the given time in ms, if the
+// extension is present.
+func (p *RtpPacket) UpdateAbsSendTime(ms uint64) bool {
+	value := p.readExtension(p.headerExtensionIds.AbsSendTime)
+	if len(value) != 3 {
+		return false
+	}
+	absSendTime := util.TimeMsToAbsSendTime(ms)
+	value[0] = uint8(absSendTime >> 16)
+	value[1] = uint8(absSendTime >> 8)
+	value[2] = uint8(absSendTime)
+	return true
+}
+
+func (p *RtpPacket) ReadTransportWideCc01() (wideSeqNumber uint16, ok bool) {
+	return p.readTransportWideCc01(p.headerExtensionIds.TransportWideCc01)
+}
+
+// readTransportWideCc01 reads the transport-wide-cc-01 extension with the
+// given id.
+func (p *RtpPacket) readTransportWideCc01(id uint8) (wideSeqNumber uint16, ok bool) {
+	value := p.readExtension(id)
+	if len(value) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(value), true
+}
+
+// UpdateTransportWideCc01 sets the transport-wide sequence number, if the
+// extension is present.
+func (p *RtpPacket) UpdateTransportWideCc01(wideSeqNumber uint16) bool {
+	value := p.readExtension(p.headerExtensionIds.TransportWideCc01)
+	if len(value) != 2 {
+		return false
+	}
+	binary.BigEndian.PutUint16(value, wideSeqNumber)
+	return true
+}
+
+// ReadSsrcAudioLevel returns the audio level in -dBov, 127 being silence, and
+// whether the packet contains voice activity.
+func (p *RtpPacket) ReadSsrcAudioLevel() (volume uint8, voice bool, ok bool) {
+	value := p.readExtension(p.headerExtensionIds.SsrcAudioLevel)
+	if len(value) != 1 {
+		return 0, false, false
+	}
+	return value[0] & 0x7F, value[0]&0x80 != 0, true
+}
+
+// ReadVideoOrientation returns the coordination of video orientation (CVO)
+// values: whether the camera is the back one, whether the video is flipped
+// horizontally and its clockwise rotation in degrees.
+func (p *RtpPacket) ReadVideoOrientation() (camera, flip bool, rotation uint16, ok bool) {
+	value := p.readExtension(p.headerExtensionIds.VideoOrientation)
+	if len(value) != 1 {
+		return false, false, 0, false
+	}
+	return value[0]&0x08 != 0, value[0]&0x04 != 0, uint16(value[0]&0x03) * 90, true
+}
+
+// ReadToffset returns the transmission time offset in RTP timestamp units.
+func (p *RtpPacket) ReadToffset() (offset int32, ok bool) {
+	value := p.readExtension(p.headerExtensionIds.Toffset)
+	if len(value) != 3 {
+		return 0, false
+	}
+	// Sign extend the 24 bits value.
+	return int32(uint32(value[0])<<24|uint32(value[1])<<16|uint32(value[2])<<8) >> 8, true
+}
+
+// ReadPlayoutDelay returns the minimum and maximum playout delays in 10 ms
+// units.
+func (p *RtpPacket) ReadPlayoutDelay() (minDelay, maxDelay uint16, ok bool) {
+	value := p.readExtension(p.headerExtensionIds.PlayoutDelay)
+	if len(value) != 3 {
+		return 0, 0, false
+	}
+	return uint16(value[0])<<4 | uint16(value[1])>>4, uint16(value[1]&0x0F)<<8 | uint16(value[2]), true
+}
+
+func (p *RtpPacket) ReadAbsCaptureTime() (absCaptureTime AbsCaptureTime, ok bool) {
+	value := p.readExtension(p.headerExtensionIds.AbsCaptureTime)
+	if len(value) != 8 && len(value) != 16 {
+		return absCaptureTime, false
+	}
+	absCaptureTime.AbsoluteCaptureTimestamp = binary.BigEndian.Uint64(value)
+	if len(value) == 16 {
+		absCaptureTime.EstimatedCaptureClockOffset = int64(binary.BigEndian.Uint64(value[8:]))
+		absCaptureTime.HasEstimatedCaptureClockOffset = true
+	}
+	return absCaptureTime, true
+}
+
+// RtpHeaderExtensionsBackup holds the header extensions of a packet replaced by
+// MapHeaderExtensions so they can be restored once the packet is sent.
+type RtpHeaderExtensionsBackup struct {
+	extension          bool
+	extensionProfile   uint16
+	extensions         []rtp.Extension
+	headerExtensionIds RtpHeaderExtensionIds
+	size               uint64
+}
+
+// MapHeaderExtensions rewrites the header extensions of the packet into the
+// ones negotiated with a remote endpoint, given by ids:
+//   - Extensions not negotiated by the remote endpoint, as well as rid and
+//     repaired-rid, are removed.
+//   - Kept extensions get the ids of the remote endpoint, the one-byte format
+//     being used unless an id or a value does not fit in it.
+//   - The mid is replaced by the given one.
+//   - Zeroed abs-send-time and transport-wide-cc-01 extensions are added if
+//     missing so the sender can stamp them with UpdateAbsSendTime and
+//     UpdateTransportWideCc01.
+//
+// Values are copied so updating them does not alter the original packet, which
+// RestoreHeaderExtensions brings back with the returned backup.
+func (p *RtpPacket) MapHeaderExtensions(ids RtpHeaderExtensionIds, mid string) RtpHeaderExtensionsBackup {
+	backup := RtpHeaderExtensionsBackup{
+		extension:          p.Extension,
+		extensionProfile:   p.ExtensionProfile,
+		extensions:         p.Extensions,
+		headerExtensionIds: p.headerExtensionIds,
+		size:               p.Size,
+	}
+
+	from := p.headerExtensionIds
+
+	type mappedExtension struct {
+		id    uint8
+		value []byte
+	}
+	var mapped []mappedExtension
+
+	add := func(id uint8, value []byte) {
+		mapped = append(mapped, mappedExtension{id: id, value: append([]byte(nil), value...)})
+	}
+	proxy := func(fromId, toId uint8) {
+		if toId == 0 {
+			return
+		}
+		if value := p.readExtension(fromId); len(value) > 0 {
+			add(toId, value)
+		}
+	}
+	stamp := func(fromId, toId uint8, length int) {
+		if toId == 0 {
+			return
+		}
+		if value := p.readExtension(fromId); len(value) == length {
+			add(toId, value)
+		} else {
+			add(toId, make([]byte, length))
+		}
+	}
+
+	if ids.Mid != 0 && len(mid) > 0 && len(mid) <= MaxMidLength {
+		add(ids.Mid, []byte(mid))
+	}
+	stamp(from.AbsSendTime, ids.AbsSendTime, 3)
+	stamp(from.TransportWideCc01, ids.TransportWideCc01, 2)
+	proxy(from.SsrcAudioLevel, ids.SsrcAudioLevel)
+	proxy(from.FrameMarking, ids.FrameMarking)
+	proxy(from.FrameMarking07, ids.FrameMarking07)
+	proxy(from.VideoOrientation, ids.VideoOrientation)
+	proxy(from.Toffset, ids.Toffset)
+	proxy(from.AbsCaptureTime, ids.AbsCaptureTime)
+	proxy(from.PlayoutDelay, ids.PlayoutDelay)
+	proxy(from.DependencyDescriptor, ids.DependencyDescriptor)
+
+	profile := uint16(oneByteHeaderExtensionProfile)
+	for _, ext := range mapped {
+		if ext.id > 14 || len(ext.value) > 16 {
+			profile = twoByteHeaderExtensionProfile
+			break
+		}
+	}
+
+	p.Extension = len(mapped) > 0
+	p.ExtensionProfile = 0
+	p.Extensions = nil
+	if p.Extension {
+		p.ExtensionProfile = profile
+		p.Extensions = make([]rtp.Extension, 0, len(mapped))
+		for _, ext := range mapped {
+			// Cannot fail, ids and lengths fit the chosen profile.
+			_ = p.SetExtension(ext.id, ext.value)
+		}
+	}
+
+	p.headerExtensionIds = ids
+	p.Size = uint64(p.MarshalSize())
+
+	return backup
+}
+
+// RestoreHeaderExtensions restores the header extensions replaced by
+// MapHeaderExtensions.
+func (p *RtpPacket) RestoreHeaderExtensions(backup RtpHeaderExtensionsBackup) {
+	p.Extension = backup.extension
+	p.ExtensionProfile = backup.extensionProfile
+	p.Extensions = backup.extensions
+	p.headerExtensionIds = backup.headerExtensionIds
+	p.Size = backup.size
+}
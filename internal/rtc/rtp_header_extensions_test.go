@@ -0,0 +1,181 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRtpHeaderExtensionIds(t *testing.T) {
+	ids := NewRtpHeaderExtensionIds([]RtpHeaderExtensionParameters{
+		{Uri: MidHeaderExtensionUri, Id: 1},
+		{Uri: AbsSendTimeHeaderExtensionUri, Id: 2},
+		{Uri: TransportWideCc01HeaderExtensionUri, Id: 3},
+		{Uri: SsrcAudioLevelHeaderExtensionUri, Id: 4, Encrypt: true},
+		{Uri: "urn:unknown", Id: 5},
+	})
+
+	require.Equal(t, RtpHeaderExtensionIds{Mid: 1, AbsSendTime: 2, TransportWideCc01: 3}, ids)
+}
+
+func TestRtpPacketHeaderExtensions(t *testing.T) {
+	ids := RtpHeaderExtensionIds{
+		Mid:               1,
+		Rid:               2,
+		RRid:              3,
+		AbsSendTime:       4,
+		TransportWideCc01: 5,
+		SsrcAudioLevel:    6,
+		VideoOrientation:  7,
+		Toffset:           8,
+		PlayoutDelay:      9,
+		AbsCaptureTime:    10,
+	}
+
+	t.Run("not negotiated extensions are not read", func(t *testing.T) {
+		packet := createTestRtpPacket(t, 1111, 1, 100, map[uint8][]byte{1: []byte("0")})
+
+		_, ok := packet.ReadMid()
+		require.False(t, ok)
+		require.False(t, packet.UpdateMid("1"))
+	})
+
+	t.Run("read sdes extensions", func(t *testing.T) {
+		packet := createTestRtpPacket(t, 1111, 1, 100, map[uint8][]byte{
+			1: []byte("audio"),
+			2: []byte("h"),
+			3: []byte("l"),
+		})
+		packet.SetHeaderExtensionIds(ids)
+
+		mid, ok := packet.ReadMid()
+		require.True(t, ok)
+		require.Equal(t, "audio", mid)
+
+		rid, ok := packet.ReadRid()
+		require.True(t, ok)
+		require.Equal(t, "h", rid)
+
+		rrid, ok := packet.ReadRepairedRid()
+		require.True(t, ok)
+		require.Equal(t, "l", rrid)
+	})
+
+	t.Run("update mid changes the packet size", func(t *testing.T) {
+		packet := createTestRtpPacket(t, 1111, 1, 100, map[uint8][]byte{1: []byte("0")})
+		packet.SetHeaderExtensionIds(ids)
+		size := packet.Size
+
+		require.True(t, packet.UpdateMid("video12"))
+
+		mid, ok := packet.ReadMid()
+		require.True(t, ok)
+		require.Equal(t, "video12", mid)
+		require.Greater(t, packet.Size, size)
+
+		data, err := packet.Marshal()
+		require.NoError(t, err)
+		require.EqualValues(t, len(data), packet.Size)
+
+		require.False(t, packet.UpdateMid("this-mid-is-far-too-long"))
+	})
+
+	t.Run("read and update abs-send-time and transport-wide-cc", func(t *testing.T) {
+		packet := createTestRtpPacket(t, 1111, 1, 100, map[uint8][]byte{
+			4: {0x00, 0x00, 0x00},
+			5: {0x00, 0x00},
+		})
+		packet.SetHeaderExtensionIds(ids)
+
+		require.True(t, packet.UpdateAbsSendTime(1500))
+		absSendTime, ok := packet.ReadAbsSendTime()
+		require.True(t, ok)
+		require.Equal(t, util.TimeMsToAbsSendTime(1500), absSendTime)
+
+		require.True(t, packet.UpdateTransportWideCc01(0xABCD))
+		wideSeqNumber, ok := packet.ReadTransportWideCc01()
+		require.True(t, ok)
+		require.EqualValues(t, 0xABCD, wideSeqNumber)
+
+		// Updates must be kept once serialized.
+		data, err := packet.Marshal()
+		require.NoError(t, err)
+		parsed, err := NewRtpPacket(data)
+		require.NoError(t, err)
+		parsed.SetHeaderExtensionIds(ids)
+		wideSeqNumber, ok = parsed.ReadTransportWideCc01()
+		require.True(t, ok)
+		require.EqualValues(t, 0xABCD, wideSeqNumber)
+	})
+
+	t.Run("missing extensions are not updated", func(t *testing.T) {
+		packet := createTestRtpPacket(t, 1111, 1, 100, nil)
+		packet.SetHeaderExtensionIds(ids)
+
+		require.False(t, packet.UpdateAbsSendTime(1500))
+		require.False(t, packet.UpdateTransportWideCc01(1))
+	})
+
+	t.Run("read media extensions", func(t *testing.T) {
+		packet := createTestRtpPacket(t, 1111, 1, 100, map[uint8][]byte{
+			6:  {0x80 | 30},
+			7:  {0x08 | 0x04 | 0x03},
+			8:  {0xFF, 0xFF, 0xFE},
+			9:  {0x01, 0x40, 0x20},
+			10: {0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		})
+		packet.SetHeaderExtensionIds(ids)
+
+		volume, voice, ok := packet.ReadSsrcAudioLevel()
+		require.True(t, ok)
+		require.EqualValues(t, 30, volume)
+		require.True(t, voice)
+
+		camera, flip, rotation, ok := packet.ReadVideoOrientation()
+		require.True(t, ok)
+		require.True(t, camera)
+		require.True(t, flip)
+		require.EqualValues(t, 270, rotation)
+
+		offset, ok := packet.ReadToffset()
+		require.True(t, ok)
+		require.EqualValues(t, -2, offset)
+
+		minDelay, maxDelay, ok := packet.ReadPlayoutDelay()
+		require.True(t, ok)
+		require.EqualValues(t, 0x014, minDelay)
+		require.EqualValues(t, 0x020, maxDelay)
+
+		absCaptureTime, ok := packet.ReadAbsCaptureTime()
+		require.True(t, ok)
+		require.EqualValues(t, 0x0102030405060708, absCaptureTime.AbsoluteCaptureTimestamp)
+		require.True(t, absCaptureTime.HasEstimatedCaptureClockOffset)
+		require.EqualValues(t, -1, absCaptureTime.EstimatedCaptureClockOffset)
+	})
+
+	t.Run("map header extensions converts to the one-byte format", func(t *testing.T) {
+		packet := createTestRtpPacket(t, 1111, 1, 100, nil)
+		packet.Extension = true
+		packet.ExtensionProfile = twoByteHeaderExtensionProfile
+		require.NoError(t, packet.SetExtension(20, []byte{0x01, 0x02}))
+		require.NoError(t, packet.SetExtension(21, []byte("h")))
+		require.NoError(t, packet.SetExtension(22, []byte{0x04}))
+		packet.SetHeaderExtensionIds(RtpHeaderExtensionIds{TransportWideCc01: 20, Rid: 21, VideoOrientation: 22})
+
+		backup := packet.MapHeaderExtensions(RtpHeaderExtensionIds{TransportWideCc01: 3, Rid: 4}, "")
+
+		// Rid and not negotiated extensions are removed.
+		require.EqualValues(t, oneByteHeaderExtensionProfile, packet.ExtensionProfile)
+		require.Equal(t, []uint8{3}, packet.GetExtensionIDs())
+		require.True(t, packet.UpdateTransportWideCc01(7))
+
+		packet.RestoreHeaderExtensions(backup)
+
+		// Updating the mapped value does not alter the original one.
+		wideSeqNumber, ok := packet.ReadTransportWideCc01()
+		require.True(t, ok)
+		require.EqualValues(t, 0x0102, wideSeqNumber)
+		require.EqualValues(t, twoByteHeaderExtensionProfile, packet.ExtensionProfile)
+	})
+}
@@ -1,25 +1,94 @@
 package rtc
 
 import (
+	"encoding/binary"
+
 	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
 	"github.com/pion/rtp"
 )
 
 type RtpPacket struct {
 	rtp.Packet
-	Size                     uint64
-	payloadDescriptorHandler codecs.PayloadDescriptorHandler
+	Size                       uint64
+	payloadDescriptorHandler   codecs.PayloadDescriptorHandler
+	headerExtensionIds         RtpHeaderExtensionIds
+	dependencyDescriptorParser *codecs.DependencyDescriptorParser
+}
+
+// NewRtpPacket parses the given buffer into a RtpPacket. The packet keeps
+// referencing the buffer.
+func NewRtpPacket(data []byte) (*RtpPacket, error) {
+	packet := &RtpPacket{}
+	if err := packet.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	packet.Size = uint64(len(data))
+	return packet, nil
+}
+
+// Clone returns a deep copy of the packet which does not reference the
+// original buffer.
+func (p *RtpPacket) Clone() *RtpPacket {
+	clone := *p
+	clone.Packet = *p.Packet.Clone()
+	return &clone
+}
+
+// RtxEncode turns the packet into a RTX packet as defined in RFC 4588: the
+// original sequence number is prepended to the payload and padding is removed.
+func (p *RtpPacket) RtxEncode(payloadType uint8, ssrc uint32, seq uint16) {
+	payload := make([]byte, 2+len(p.Payload))
+	binary.BigEndian.PutUint16(payload, p.SequenceNumber)
+	copy(payload[2:], p.Payload)
+
+	if p.Padding {
+		p.Size -= uint64(p.PaddingSize)
+		p.Padding = false
+		p.PaddingSize = 0
+	}
+
+	p.Payload = payload
+	p.Size += 2
+	p.PayloadType = payloadType
+	p.SSRC = ssrc
+	p.SequenceNumber = seq
+}
+
+// RtxDecode restores the original packet from a RTX packet as defined in RFC
+// 4588. It returns false if the payload does not carry the original sequence
+// number.
+func (p *RtpPacket) RtxDecode(payloadType uint8, ssrc uint32) bool {
+	if len(p.Payload) < 2 {
+		return false
+	}
+
+	if p.Padding {
+		p.Size -= uint64(p.PaddingSize)
+		p.Padding = false
+		p.PaddingSize = 0
+	}
+
+	p.PayloadType = payloadType
+	p.SSRC = ssrc
+	p.SequenceNumber = binary.BigEndian.Uint16(p.Payload)
+	p.Payload = p.Payload[2:]
+	p.Size -= 2
+
+	return true
 }
 
 func (p *RtpPacket) SetPayloadDescriptorHandler(handler codecs.PayloadDescriptorHandler) {
 	p.payloadDescriptorHandler = handler
 }
 
+// ProcessPayload returns ok as false if the packet must be dropped according
+// to the given encoding context. Packets without payload descriptor handler are
+// always forwarded.
 func (p *RtpPacket) ProcessPayload(context *codecs.EncodingContext, data []byte) (marker, ok bool) {
 	if p.payloadDescriptorHandler != nil {
 		return p.payloadDescriptorHandler.Process(context, data)
 	}
-	return
+	return false, true
 }
 
 func (p *RtpPacket) RestorePayload() {
@@ -28,6 +97,55 @@ func (p *RtpPacket) RestorePayload() {
 	}
 }
 
+// SetHeaderExtensionIds sets the negotiated ids of the header extensions the
+// packet is read and rewritten with.
+func (p *RtpPacket) SetHeaderExtensionIds(ids RtpHeaderExtensionIds) {
+	p.headerExtensionIds = ids
+}
+
+func (p *RtpPacket) GetHeaderExtensionIds() RtpHeaderExtensionIds {
+	return p.headerExtensionIds
+}
+
+// ReadFrameMarking reads the final frame marking header extension, or the
+// draft one if the final one was not negotiated.
+func (p *RtpPacket) ReadFrameMarking() (frameMarking []byte, ok bool) {
+	id := p.headerExtensionIds.FrameMarking
+	if id == 0 {
+		id = p.headerExtensionIds.FrameMarking07
+	}
+	frameMarking = p.readExtension(id)
+	if len(frameMarking) == 0 || len(frameMarking) > 3 {
+		return nil, false
+	}
+	return frameMarking, true
+}
+
+// SetDependencyDescriptorParser sets the parser keeping the template
+// dependency structure of the stream.
+func (p *RtpPacket) SetDependencyDescriptorParser(parser *codecs.DependencyDescriptorParser) {
+	p.dependencyDescriptorParser = parser
+}
+
+func (p *RtpPacket) ReadDependencyDescriptor() (dependencyDescriptor *codecs.DependencyDescriptor, ok bool) {
+	if p.dependencyDescriptorParser == nil {
+		return nil, false
+	}
+	data := p.readExtension(p.headerExtensionIds.DependencyDescriptor)
+	if len(data) == 0 {
+		return nil, false
+	}
+	dependencyDescriptor, err := p.dependencyDescriptorParser.Parse(data)
+	if err != nil {
+		return nil, false
+	}
+	return dependencyDescriptor, true
+}
+
+func (p RtpPacket) GetPayload() []byte {
+	return p.Payload
+}
+
 func (p RtpPacket) GetSequenceNumber() uint16 {
 	return p.SequenceNumber
 }
@@ -39,6 +157,20 @@ func (p RtpPacket) IsKeyFrame() bool {
 	return false
 }
 
+func (p RtpPacket) GetSpatialLayer() uint8 {
+	if p.payloadDescriptorHandler != nil {
+		return p.payloadDescriptorHandler.GetSpatialLayer()
+	}
+	return 0
+}
+
+func (p RtpPacket) GetTemporalLayer() uint8 {
+	if p.payloadDescriptorHandler != nil {
+		return p.payloadDescriptorHandler.GetTemporalLayer()
+	}
+	return 0
+}
+
 func (p RtpPacket) GetSsrc() uint32 {
 	// Implement this method
 	return p.SSRC
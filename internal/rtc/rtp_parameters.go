@@ -0,0 +1,249 @@
+package rtc
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type MediaKind string
+
+const (
+	MediaKindAudio MediaKind = "audio"
+	MediaKindVideo MediaKind = "video"
+)
+
+// RtpParameters describe a media stream received by mediasoup from an endpoint
+// through its corresponding mediasoup Producer, or sent by mediasoup to an
+// endpoint through its corresponding mediasoup Consumer.
+type RtpParameters struct {
+	// Mid defines the MID RTP extension value as defined in the BUNDLE
+	// specification.
+	Mid string
+
+	// Codecs defines media and RTX codecs in use.
+	Codecs []*RtpCodecParameters
+
+	// HeaderExtensions defines RTP header extensions in use.
+	HeaderExtensions []RtpHeaderExtensionParameters
+
+	// Encodings defines transmitted RTP streams and their settings.
+	Encodings []RtpEncodingParameters
+
+	// Rtcp defines parameters used for RTCP.
+	Rtcp RtcpParameters
+}
+
+// RtpCodecParameters provides information on codec settings within the RTP
+// parameters.
+type RtpCodecParameters struct {
+	// MimeType defines the codec MIME media type/subtype (e.g. "audio/opus",
+	// "video/VP8").
+	MimeType string
+
+	// PayloadType defines the value that goes in the RTP Payload Type Field.
+	PayloadType uint8
+
+	// ClockRate defines codec clock rate expressed in Hertz.
+	ClockRate uint32
+
+	// Channels defines the number of channels supported (e.g. two for stereo).
+	// Just for audio.
+	Channels uint8
+
+	// Parameters defines codec-specific parameters available for signaling.
+	Parameters RtpCodecSpecificParameters
+
+	// RtcpFeedback defines transport layer and codec-specific feedback messages
+	// for this codec.
+	RtcpFeedback []RtcpFeedback
+}
+
+// RtpCodecSpecificParameters contains the codec specific parameters used by
+// the worker.
+type RtpCodecSpecificParameters struct {
+	// Apt is the associated payload type of a RTX codec.
+	Apt uint8
+
+	// Useinbandfec tells whether Opus in-band FEC is in use.
+	Useinbandfec uint8
+
+	// Usedtx tells whether Opus DTX is in use.
+	Usedtx uint8
+}
+
+// RtcpFeedback provides information on RTCP feedback messages for a specific
+// codec. Those messages can be transport layer feedback messages or
+// codec-specific feedback messages.
+type RtcpFeedback struct {
+	// Type defines RTCP feedback type.
+	Type string
+
+	// Parameter defines RTCP feedback parameter.
+	Parameter string
+}
+
+// RtpEncodingParameters provides information relating to an encoding, which
+// represents a media RTP stream and its associated RTX stream (if any).
+type RtpEncodingParameters struct {
+	// Ssrc defines the media SSRC.
+	Ssrc uint32
+
+	// Rid defines the RID RTP header extension value. Must be unique.
+	Rid string
+
+	// CodecPayloadType is the codec payload type this encoding affects.
+	// If unset, first media codec is chosen.
+	CodecPayloadType uint8
+
+	// Rtx is the RTX stream information. It must contain a numeric ssrc field
+	// indicating the RTX SSRC.
+	Rtx *RtpEncodingRtx
+
+	// Dtx indicates whether discontinuous RTP transmission will be used. Useful
+	// for audio (if the codec supports it) and for video screen sharing (when
+	// static content is being transmitted, this option disables the RTP
+	// inactivity checks in mediasoup). Default false.
+	Dtx bool
+
+	// ScalabilityMode defines the number of spatial and temporal layers in the
+	// RTP stream (e.g. 'L1T3'). See webrtc-svc.
+	ScalabilityMode string
+
+	// MaxBitrate is the maximum bitrate of the encoding.
+	MaxBitrate uint32
+}
+
+// RtpEncodingRtx represents the associated RTX stream for RTP stream.
+type RtpEncodingRtx struct {
+	Ssrc uint32
+}
+
+// RtpHeaderExtensionParameters defines a RTP header extension within the RTP
+// parameters.
+type RtpHeaderExtensionParameters struct {
+	// Uri is the URI of the RTP header extension, as defined in RFC 5285.
+	Uri string
+
+	// Id is the numeric identifier that goes in the RTP packet. Must be unique.
+	Id uint8
+
+	// Encrypt if true, the value in the header is encrypted as per RFC 6904.
+	// Default false.
+	Encrypt bool
+}
+
+// RtcpParameters provides information on RTCP settings within the RTP
+// parameters.
+type RtcpParameters struct {
+	// Cname is the Canonical Name (CNAME) used by RTCP (e.g. in SDES messages).
+	Cname string
+
+	// ReducedSize defines whether reduced size RTCP RFC 5506 is configured (if
+	// true) or compound RTCP as specified in RFC 3550 (if false). Default true.
+	ReducedSize bool
+}
+
+// RtpMapping maps the RTP parameters of a Producer to the ones used inside
+// the router.
+type RtpMapping struct {
+	Codecs    []RtpMappingCodec
+	Encodings []RtpMappingEncoding
+}
+
+type RtpMappingCodec struct {
+	PayloadType       uint8
+	MappedPayloadType uint8
+}
+
+type RtpMappingEncoding struct {
+	Ssrc            uint32
+	Rid             string
+	ScalabilityMode string
+	MappedSsrc      uint32
+}
+
+// ScalabilityMode is the parsed representation of a webrtc-svc scalability
+// mode string.
+type ScalabilityMode struct {
+	SpatialLayers  uint8
+	TemporalLayers uint8
+	Ksvc           bool
+}
+
+var scalabilityModeRegex = regexp.MustCompile(`^[LS]([1-9]\d?)T([1-9]\d?)(_KEY)?`)
+
+// ParseScalabilityMode parses strings like "L1T3", "S3T3" or "L3T3_KEY". It
+// returns a single spatial and temporal layer if the mode is invalid.
+func ParseScalabilityMode(mode string) ScalabilityMode {
+	scalabilityMode := ScalabilityMode{
+		SpatialLayers:  1,
+		TemporalLayers: 1,
+	}
+	match := scalabilityModeRegex.FindStringSubmatch(mode)
+	if match == nil {
+		return scalabilityMode
+	}
+	spatialLayers, _ := strconv.Atoi(match[1])
+	temporalLayers, _ := strconv.Atoi(match[2])
+
+	scalabilityMode.SpatialLayers = uint8(spatialLayers)
+	scalabilityMode.TemporalLayers = uint8(temporalLayers)
+	scalabilityMode.Ksvc = len(match[3]) > 0
+
+	return scalabilityMode
+}
+
+// IsRtxMimeType tells whether the given MIME type belongs to a RTX codec.
+func IsRtxMimeType(mimeType string) bool {
+	return strings.HasSuffix(strings.ToLower(mimeType), "/rtx")
+}
+
+// GetCodecForEncoding returns the media codec used by the given encoding.
+func (params *RtpParameters) GetCodecForEncoding(encoding RtpEncodingParameters) *RtpCodecParameters {
+	for _, codec := range params.Codecs {
+		if IsRtxMimeType(codec.MimeType) {
+			continue
+		}
+		if encoding.CodecPayloadType == 0 || codec.PayloadType == encoding.CodecPayloadType {
+			return codec
+		}
+	}
+	return nil
+}
+
+// GetRtxCodecForEncoding returns the RTX codec associated to the media codec
+// used by the given encoding, if any.
+func (params *RtpParameters) GetRtxCodecForEncoding(encoding RtpEncodingParameters) *RtpCodecParameters {
+	mediaCodec := params.GetCodecForEncoding(encoding)
+	if mediaCodec == nil {
+		return nil
+	}
+	for _, codec := range params.Codecs {
+		if IsRtxMimeType(codec.MimeType) && codec.Parameters.Apt == mediaCodec.PayloadType {
+			return codec
+		}
+	}
+	return nil
+}
+
+// GetHeaderExtensionId returns the negotiated id of the header extension with
+// the given URI, or 0 if not negotiated.
+func (params *RtpParameters) GetHeaderExtensionId(uri string) uint8 {
+	for _, ext := range params.HeaderExtensions {
+		if ext.Uri == uri {
+			return ext.Id
+		}
+	}
+	return 0
+}
+
+// HasRtcpFeedback tells whether the codec supports the given RTCP feedback.
+func (codec *RtpCodecParameters) HasRtcpFeedback(typ, parameter string) bool {
+	for _, fb := range codec.RtcpFeedback {
+		if fb.Type == typ && fb.Parameter == parameter {
+			return true
+		}
+	}
+	return false
+}
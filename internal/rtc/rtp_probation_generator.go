@@ -0,0 +1,65 @@
+package rtc
+
+import (
+	"github.com/pion/rtp"
+)
+
+const (
+	// RtpProbationMaxPaddingSize is the largest padding of a probation packet,
+	// its length being a single byte.
+	RtpProbationMaxPaddingSize = 255
+	// rtpProbationTimestampStep is the timestamp increase between two
+	// probation packets.
+	rtpProbationTimestampStep = 20
+)
+
+// RtpProbationGenerator creates the padding-only packets sent to probe for
+// more available outgoing bitrate, on the probation SSRC and payload type
+// negotiated with the remote endpoint, which drops them. They carry the
+// abs-send-time and transport-wide sequence number extensions so the remote
+// endpoint reports on them.
+type RtpProbationGenerator struct {
+	ssrc               uint32
+	payloadType        uint8
+	headerExtensionIds RtpHeaderExtensionIds
+	seq                uint16
+	timestamp          uint32
+}
+
+func NewRtpProbationGenerator(ssrc uint32, payloadType uint8, headerExtensionIds RtpHeaderExtensionIds) *RtpProbationGenerator {
+	return &RtpProbationGenerator{
+		ssrc:        ssrc,
+		payloadType: payloadType,
+		// Only the congestion control extensions are needed.
+		headerExtensionIds: RtpHeaderExtensionIds{
+			AbsSendTime:       headerExtensionIds.AbsSendTime,
+			TransportWideCc01: headerExtensionIds.TransportWideCc01,
+		},
+	}
+}
+
+// GetNextPacket returns the next probation packet, with the given padding and
+// no payload.
+func (g *RtpProbationGenerator) GetNextPacket(paddingSize uint8) *RtpPacket {
+	g.seq++
+	g.timestamp += rtpProbationTimestampStep
+
+	packet := &RtpPacket{
+		Packet: rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Padding:        paddingSize > 0,
+				PayloadType:    g.payloadType,
+				SequenceNumber: g.seq,
+				Timestamp:      g.timestamp,
+				SSRC:           g.ssrc,
+			},
+			PaddingSize: paddingSize,
+		},
+	}
+
+	// Add the zeroed extensions, stamped when sent. Also sets the size.
+	packet.MapHeaderExtensions(g.headerExtensionIds, "")
+
+	return packet
+}
@@ -0,0 +1,40 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRtpProbationGenerator(t *testing.T) {
+	t.Run("creates padding-only packets", func(t *testing.T) {
+		generator := NewRtpProbationGenerator(1234, 127,
+			RtpHeaderExtensionIds{Mid: 1, AbsSendTime: 2, TransportWideCc01: 3})
+
+		for i, paddingSize := range []uint8{RtpProbationMaxPaddingSize, 10} {
+			packet := generator.GetNextPacket(paddingSize)
+
+			require.EqualValues(t, 1234, packet.SSRC)
+			require.EqualValues(t, 127, packet.PayloadType)
+			require.EqualValues(t, i+1, packet.SequenceNumber)
+			require.EqualValues(t, (i+1)*20, packet.Timestamp)
+			require.Empty(t, packet.Payload)
+			require.True(t, packet.Padding)
+			require.Equal(t, paddingSize, packet.PaddingSize)
+			require.EqualValues(t, packet.MarshalSize(), packet.Size)
+
+			// The congestion control extensions are stamped when sent.
+			require.True(t, packet.UpdateAbsSendTime(1000))
+			require.True(t, packet.UpdateTransportWideCc01(uint16(i)))
+			require.Nil(t, packet.GetExtension(1))
+
+			// The marshaled packet parses back.
+			data, err := packet.Marshal()
+			require.NoError(t, err)
+			parsed, err := NewRtpPacket(data)
+			require.NoError(t, err)
+			require.Equal(t, paddingSize, parsed.PaddingSize)
+			require.Empty(t, parsed.Payload)
+		}
+	})
+}
@@ -0,0 +1,383 @@
+package rtc
+
+import (
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const RtpStreamDefaultWindowSizeMs = 2500
+
+const (
+	// RtpStreamScoreHistogramLength is the number of computed scores averaged
+	// into the stream score.
+	RtpStreamScoreHistogramLength = 24
+	// RtpStreamScoreMaxRttMs is the RTT above which the score of a sending
+	// stream is lowered proportionally.
+	RtpStreamScoreMaxRttMs = 500
+)
+
+// Sequence number validation constants of RFC 3550 appendix A.1.
+const (
+	rtpSeqMod   = 1 << 16
+	maxDropout  = 3000
+	maxMisorder = 1500
+)
+
+type RtpStreamParams struct {
+	EncodingIdx    int
+	Ssrc           uint32
+	PayloadType    uint8
+	MimeType       string
+	ClockRate      uint32
+	Rid            string
+	Cname          string
+	RtxSsrc        uint32
+	RtxPayloadType uint8
+	UseNack        bool
+	UsePli         bool
+	UseFir         bool
+	UseInBandFec   bool
+	UseDtx         bool
+	SpatialLayers  uint8
+	TemporalLayers uint8
+}
+
+type RtpStreamStats struct {
+	Type            string
+	Timestamp       int64
+	Ssrc            uint32
+	RtxSsrc         uint32
+	Rid             string
+	Kind            MediaKind
+	MimeType        string
+	PacketCount     uint64
+	ByteCount       uint64
+	Bitrate         uint32
+	NackCount       uint32
+	NackPacketCount uint32
+	// PacketsRetransmitted counts the packets received or sent again, RTX or
+	// not.
+	PacketsRetransmitted uint32
+	// PacketsRepaired counts the lost packets recovered by retransmission.
+	PacketsRepaired uint32
+	PliCount        uint32
+	FirCount        uint32
+	Score           uint8
+	// PacketsLost is the cumulative number of packets lost, negative if
+	// duplicated packets were received.
+	PacketsLost      int32
+	FractionLost     uint8
+	PacketsDiscarded uint32
+	// Jitter is the interarrival jitter in RTP timestamp units.
+	Jitter uint32
+	// RoundTripTime is in ms, only known by sending streams.
+	RoundTripTime         float32
+	RetransmissionBitrate uint32
+}
+
+// newRtpStreamParams fills the stream parameters from the given encoding and
+// its media codec.
+func newRtpStreamParams(rtpParameters *RtpParameters, encodingIdx int, mediaCodec *RtpCodecParameters) RtpStreamParams {
+	encoding := rtpParameters.Encodings[encodingIdx]
+	scalabilityMode := ParseScalabilityMode(encoding.ScalabilityMode)
+
+	params := RtpStreamParams{
+		EncodingIdx:    encodingIdx,
+		Ssrc:           encoding.Ssrc,
+		PayloadType:    mediaCodec.PayloadType,
+		MimeType:       mediaCodec.MimeType,
+		ClockRate:      mediaCodec.ClockRate,
+		Rid:            encoding.Rid,
+		Cname:          rtpParameters.Rtcp.Cname,
+		SpatialLayers:  scalabilityMode.SpatialLayers,
+		TemporalLayers: scalabilityMode.TemporalLayers,
+		UseDtx:         encoding.Dtx,
+	}
+
+	// Check in band FEC in codec parameters.
+	if mediaCodec.Parameters.Useinbandfec == 1 {
+		params.UseInBandFec = true
+	}
+
+	// Check DTX in codec parameters.
+	if mediaCodec.Parameters.Usedtx == 1 {
+		params.UseDtx = true
+	}
+
+	for _, fb := range mediaCodec.RtcpFeedback {
+		switch {
+		case fb.Type == "nack" && len(fb.Parameter) == 0:
+			params.UseNack = true
+		case fb.Type == "nack" && fb.Parameter == "pli":
+			params.UsePli = true
+		case fb.Type == "ccm" && fb.Parameter == "fir":
+			params.UseFir = true
+		}
+	}
+
+	if encoding.Rtx != nil && encoding.Rtx.Ssrc != 0 {
+		if rtxCodec := rtpParameters.GetRtxCodecForEncoding(encoding); rtxCodec != nil {
+			params.RtxSsrc = encoding.Rtx.Ssrc
+			params.RtxPayloadType = rtxCodec.PayloadType
+		}
+	}
+
+	return params
+}
+
+// RtpStream holds the state shared by receiving and sending RTP streams.
+type RtpStream struct {
+	params RtpStreamParams
+	score  uint8
+	// paused and activeSinceMs are changed by Pause() and Resume(), which may
+	// be called while packets are being received or sent.
+	paused          atomic.Bool
+	activeSinceMs   atomic.Uint64
+	nackCount       uint32
+	nackPacketCount uint32
+	// packetsRetransmitted and packetsRepaired are only updated by streams
+	// using NACK.
+	packetsRetransmitted uint32
+	packetsRepaired      uint32
+	pliCount             uint32
+	firCount             uint32
+
+	// Sequence number tracking of RFC 3550 appendix A.1.
+	seqStarted       bool
+	maxSeq           uint16
+	cycles           uint32
+	baseSeq          uint32
+	badSeq           uint32
+	packetsDiscarded uint32
+	packetsLost      int32
+	fractionLost     uint8
+
+	// scores holds the last computed scores, the oldest first.
+	scores []uint8
+}
+
+func newRtpStream(params RtpStreamParams) RtpStream {
+	return RtpStream{
+		params: params,
+	}
+}
+
+// updateSeq validates the sequence number of the given packet and updates the
+// highest one received. It returns false if the packet must be discarded
+// because of a large jump, unless two sequential packets confirm it, in which
+// case the remote is assumed to have restarted the sequence.
+func (r *RtpStream) updateSeq(packet *RtpPacket) bool {
+	seq := packet.SequenceNumber
+
+	if !r.seqStarted {
+		r.seqStarted = true
+		r.initSeq(seq)
+		return true
+	}
+
+	udelta := seq - r.maxSeq
+
+	switch {
+	// In order, with permissible gap.
+	case udelta < maxDropout:
+		// Sequence number wrapped, count another 64K cycle.
+		if seq < r.maxSeq {
+			r.cycles += rtpSeqMod
+		}
+		r.maxSeq = seq
+
+	// The sequence number made a very large jump.
+	case udelta <= rtpSeqMod-maxMisorder:
+		if uint32(seq) == r.badSeq {
+			r.initSeq(seq)
+		} else {
+			r.badSeq = (uint32(seq) + 1) & (rtpSeqMod - 1)
+			r.packetsDiscarded++
+			return false
+		}
+
+	// Duplicate or reordered packet.
+	default:
+	}
+
+	return true
+}
+
+// updateScore adds the given score to the histogram and sets the stream score
+// to the average of the histogram, weighting recent scores more. It returns
+// the previous score and whether it changed.
+func (r *RtpStream) updateScore(score uint8) (previousScore uint8, changed bool) {
+	r.scores = append(r.scores, score)
+	if len(r.scores) > RtpStreamScoreHistogramLength {
+		r.scores = r.scores[1:]
+	}
+
+	var weight, samples, totalScore int
+	for _, score := range r.scores {
+		weight++
+		samples += weight
+		totalScore += weight * int(score)
+	}
+
+	previousScore = r.score
+	r.score = uint8(math.Round(float64(totalScore) / float64(samples)))
+
+	return previousScore, r.score != previousScore
+}
+
+// computeScore returns a 0 to 10 score from the packets that should have been
+// delivered in an interval, those originally lost, those of them repaired by
+// retransmission and the number of retransmissions. Repaired packets count as
+// partially lost, the more the retransmissions needed the more. The score is
+// lowered when the given RTT in ms is too high, zero meaning unknown.
+func computeScore(total, lost, repaired, retransmitted uint32, rtt float32) uint8 {
+	if total == 0 {
+		return 0
+	}
+
+	lost = min(lost, total)
+	repaired = min(repaired, lost)
+
+	effectiveLost := float64(lost)
+
+	if repaired > 0 {
+		repairedRatio := float64(repaired) / float64(total)
+		repairedWeight := math.Pow(1/(repairedRatio+1), 4)
+
+		if retransmitted > 0 {
+			repairedWeight *= min(float64(repaired)/float64(retransmitted), 1)
+		}
+
+		effectiveLost -= float64(repaired) * repairedWeight
+	}
+
+	deliveredRatio := (float64(total) - effectiveLost) / float64(total)
+	score := math.Pow(deliveredRatio, 4) * 10
+
+	if rtt > RtpStreamScoreMaxRttMs {
+		score *= RtpStreamScoreMaxRttMs / float64(rtt)
+	}
+
+	return uint8(math.Round(score))
+}
+
+func (r *RtpStream) initSeq(seq uint16) {
+	r.cycles = 0
+	r.baseSeq = uint32(seq)
+	r.maxSeq = seq
+	r.badSeq = rtpSeqMod + 1
+}
+
+// GetExpectedPackets returns the number of packets expected since the first
+// one received.
+func (r *RtpStream) GetExpectedPackets() uint32 {
+	return r.cycles + uint32(r.maxSeq) - r.baseSeq + 1
+}
+
+// GetExtendedMaxSeq returns the highest sequence number received extended with
+// the count of sequence number cycles.
+func (r *RtpStream) GetExtendedMaxSeq() uint32 {
+	return r.cycles + uint32(r.maxSeq)
+}
+
+func (r *RtpStream) GetPacketsLost() int32 {
+	return r.packetsLost
+}
+
+func (r *RtpStream) GetFractionLost() uint8 {
+	return r.fractionLost
+}
+
+func (r *RtpStream) GetEncodingIdx() int {
+	return r.params.EncodingIdx
+}
+
+func (r *RtpStream) GetSsrc() uint32 {
+	return r.params.Ssrc
+}
+
+func (r *RtpStream) GetPayloadType() uint8 {
+	return r.params.PayloadType
+}
+
+func (r *RtpStream) GetMimeType() string {
+	return r.params.MimeType
+}
+
+func (r *RtpStream) GetClockRate() uint32 {
+	return r.params.ClockRate
+}
+
+func (r *RtpStream) GetRid() string {
+	return r.params.Rid
+}
+
+func (r *RtpStream) GetCname() string {
+	return r.params.Cname
+}
+
+func (r *RtpStream) HasRtx() bool {
+	return r.params.RtxSsrc != 0
+}
+
+func (r *RtpStream) GetRtxSsrc() uint32 {
+	return r.params.RtxSsrc
+}
+
+func (r *RtpStream) GetRtxPayloadType() uint8 {
+	return r.params.RtxPayloadType
+}
+
+func (r *RtpStream) GetSpatialLayers() uint8 {
+	return r.params.SpatialLayers
+}
+
+func (r *RtpStream) GetTemporalLayers() uint8 {
+	return r.params.TemporalLayers
+}
+
+func (r *RtpStream) GetKind() MediaKind {
+	if strings.HasPrefix(strings.ToLower(r.params.MimeType), "audio/") {
+		return MediaKindAudio
+	}
+	return MediaKindVideo
+}
+
+func (r *RtpStream) GetScore() uint8 {
+	return r.score
+}
+
+func (r *RtpStream) IsPaused() bool {
+	return r.paused.Load()
+}
+
+// GetActiveMs returns for how long the stream has been receiving or sending
+// media since it was started or resumed.
+func (r *RtpStream) GetActiveMs() uint64 {
+	activeSinceMs := r.activeSinceMs.Load()
+	if r.paused.Load() || activeSinceMs == 0 {
+		return 0
+	}
+	return uint64(time.Now().UnixMilli()) - activeSinceMs
+}
+
+func (r *RtpStream) fillStats(stats *RtpStreamStats) {
+	stats.Timestamp = time.Now().UnixMilli()
+	stats.Ssrc = r.params.Ssrc
+	stats.RtxSsrc = r.params.RtxSsrc
+	stats.Rid = r.params.Rid
+	stats.Kind = r.GetKind()
+	stats.MimeType = r.params.MimeType
+	stats.NackCount = r.nackCount
+	stats.NackPacketCount = r.nackPacketCount
+	stats.PacketsRetransmitted = r.packetsRetransmitted
+	stats.PacketsRepaired = r.packetsRepaired
+	stats.PliCount = r.pliCount
+	stats.FirCount = r.firCount
+	stats.Score = r.score
+	stats.PacketsLost = r.packetsLost
+	stats.FractionLost = r.fractionLost
+	stats.PacketsDiscarded = r.packetsDiscarded
+}
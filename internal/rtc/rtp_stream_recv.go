@@ -0,0 +1,487 @@
+package rtc
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+	"github.com/jiyeyuran/mediasoup/internal/util"
+)
+
+type RtpStreamRecvListener interface {
+	OnRtpStreamNackRequired(rtpStream *RtpStreamRecv, seqNumbers []uint16)
+	OnRtpStreamKeyFrameRequired(rtpStream *RtpStreamRecv)
+	OnRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8)
+}
+
+// transmissionCounter counts packets per spatial and temporal layer.
+type transmissionCounter struct {
+	spatialLayerCounters [][]*rtpDataCounter
+}
+
+func newTransmissionCounter(spatialLayers, temporalLayers uint8, windowSizeMs uint64) *transmissionCounter {
+	spatialLayers = max(spatialLayers, 1)
+	temporalLayers = max(temporalLayers, 1)
+
+	counters := make([][]*rtpDataCounter, spatialLayers)
+	for i := range counters {
+		counters[i] = make([]*rtpDataCounter, temporalLayers)
+		for j := range counters[i] {
+			counters[i][j] = NewRtpDataCounter(windowSizeMs)
+		}
+	}
+	return &transmissionCounter{
+		spatialLayerCounters: counters,
+	}
+}
+
+func (t *transmissionCounter) Update(packet *RtpPacket) {
+	spatialLayer := int(packet.GetSpatialLayer())
+	temporalLayer := int(packet.GetTemporalLayer())
+
+	// Sanity check. Do not allow spatial layers higher than defined.
+	if spatialLayer > len(t.spatialLayerCounters)-1 {
+		spatialLayer = len(t.spatialLayerCounters) - 1
+	}
+
+	// Sanity check. Do not allow temporal layers higher than defined.
+	if temporalLayer > len(t.spatialLayerCounters[0])-1 {
+		temporalLayer = len(t.spatialLayerCounters[0]) - 1
+	}
+
+	t.spatialLayerCounters[spatialLayer][temporalLayer].Update(packet)
+}
+
+func (t *transmissionCounter) GetBitrate(nowMs uint64) (rate uint32) {
+	for _, spatialLayerCounter := range t.spatialLayerCounters {
+		for _, temporalLayerCounter := range spatialLayerCounter {
+			rate += temporalLayerCounter.GetBitrate(nowMs)
+		}
+	}
+	return
+}
+
+// GetBitrateForLayers returns the bitrate of the given spatial layer up to
+// the given temporal layer (inclusive). It returns 0 if the given temporal
+// layer is not being received.
+func (t *transmissionCounter) GetBitrateForLayers(nowMs uint64, spatialLayer, temporalLayer uint8) (rate uint32) {
+	if int(spatialLayer) >= len(t.spatialLayerCounters) ||
+		int(temporalLayer) >= len(t.spatialLayerCounters[spatialLayer]) {
+		return 0
+	}
+	for tIdx := uint8(0); tIdx <= temporalLayer; tIdx++ {
+		tRate := t.spatialLayerCounters[spatialLayer][tIdx].GetBitrate(nowMs)
+		if tIdx == temporalLayer && tRate == 0 {
+			return 0
+		}
+		rate += tRate
+	}
+	return
+}
+
+// GetSpatialLayerBitrate returns the bitrate of all temporal layers of the
+// given spatial layer.
+func (t *transmissionCounter) GetSpatialLayerBitrate(nowMs uint64, spatialLayer uint8) (rate uint32) {
+	if int(spatialLayer) >= len(t.spatialLayerCounters) {
+		return 0
+	}
+	for _, temporalLayerCounter := range t.spatialLayerCounters[spatialLayer] {
+		rate += temporalLayerCounter.GetBitrate(nowMs)
+	}
+	return
+}
+
+// GetLayerBitrate returns the bitrate of the single given layer.
+func (t *transmissionCounter) GetLayerBitrate(nowMs uint64, spatialLayer, temporalLayer uint8) uint32 {
+	if int(spatialLayer) >= len(t.spatialLayerCounters) ||
+		int(temporalLayer) >= len(t.spatialLayerCounters[spatialLayer]) {
+		return 0
+	}
+	return t.spatialLayerCounters[spatialLayer][temporalLayer].GetBitrate(nowMs)
+}
+
+func (t *transmissionCounter) GetPacketCount() (count uint64) {
+	for _, spatialLayerCounter := range t.spatialLayerCounters {
+		for _, temporalLayerCounter := range spatialLayerCounter {
+			count += temporalLayerCounter.GetPacketCount()
+		}
+	}
+	return
+}
+
+func (t *transmissionCounter) GetBytes() (bytes uint64) {
+	for _, spatialLayerCounter := range t.spatialLayerCounters {
+		for _, temporalLayerCounter := range spatialLayerCounter {
+			bytes += temporalLayerCounter.GetBytes()
+		}
+	}
+	return
+}
+
+// RtpStreamRecv is the receiving side of a Producer encoding.
+type RtpStreamRecv struct {
+	RtpStream
+	listener      RtpStreamRecvListener
+	started       bool
+	nackGenerator *NackGenerator
+	// nackMu serializes the packets given to the NackGenerator with Pause(),
+	// which resets it.
+	nackMu              sync.Mutex
+	transmissionCounter *transmissionCounter
+	// lastSrNtpMs and lastSrRtpTs are the NTP time in ms and the RTP
+	// timestamp of the last SR, mapping the stream timestamps to the clock of
+	// the sender.
+	lastSrNtpMs uint64
+	lastSrRtpTs uint32
+	// dependencyDescriptorParser keeps the last AV1 template dependency
+	// structure of the stream.
+	dependencyDescriptorParser *codecs.DependencyDescriptorParser
+	// expectedPrior and receivedPrior are the packet counts at the time of
+	// the last receiver report, used to compute the fraction lost.
+	expectedPrior uint32
+	receivedPrior uint32
+	// The *PriorScore fields are the counters at the time of the last score
+	// update.
+	expectedPriorScore uint32
+	receivedPriorScore uint32
+	repairedPriorScore uint32
+	// jitter is the interarrival jitter in RTP timestamp units and transit the
+	// relative transit time of the last packet, as in RFC 3550 appendix A.8.
+	jitter           float64
+	transit          int32
+	transitStarted   bool
+	lastSrReceivedMs uint64
+	// lastSrTimestamp is the middle 32 bits of the NTP timestamp of the last
+	// SR received.
+	lastSrTimestamp uint32
+	logger          *slog.Logger
+}
+
+func NewRtpStreamRecv(listener RtpStreamRecvListener, params RtpStreamParams, sendNackDelayMs uint64) *RtpStreamRecv {
+	r := &RtpStreamRecv{
+		RtpStream:                  newRtpStream(params),
+		listener:                   listener,
+		transmissionCounter:        newTransmissionCounter(params.SpatialLayers, params.TemporalLayers, RtpStreamDefaultWindowSizeMs),
+		dependencyDescriptorParser: codecs.NewDependencyDescriptorParser(),
+		logger:                     slog.Default().With("typename", "RtpStreamRecv", "ssrc", params.Ssrc),
+	}
+	if params.UseNack {
+		r.nackGenerator = NewNackGenerator(r, sendNackDelayMs)
+	}
+	return r
+}
+
+// ReceivePacket returns false if the packet must be discarded.
+func (r *RtpStreamRecv) ReceivePacket(packet *RtpPacket) bool {
+	if packet.GetSsrc() != r.params.Ssrc {
+		r.logger.Warn("packet ssrc does not match stream ssrc", "packetSsrc", packet.GetSsrc())
+		return false
+	}
+
+	if !r.updateSeq(packet) {
+		r.logger.Warn("invalid packet sequence number, packet discarded", "seq", packet.SequenceNumber)
+		return false
+	}
+
+	r.calculateJitter(packet.Timestamp, uint64(time.Now().UnixMilli()))
+
+	// Pass the packet to the NackGenerator.
+	if r.nackGenerator != nil {
+		r.nackMu.Lock()
+		if !r.paused.Load() {
+			r.nackGenerator.ReceivePacket(packet, false)
+		}
+		r.nackMu.Unlock()
+	}
+
+	// Increase transmission counter.
+	r.transmissionCounter.Update(packet)
+
+	// First packet received, the stream is considered healthy until a score is
+	// computed.
+	if !r.started {
+		r.started = true
+		r.score = 10
+		r.activeSinceMs.Store(uint64(time.Now().UnixMilli()))
+	}
+
+	return true
+}
+
+// SetRtx sets the RTX stream learnt after the media one was created.
+func (r *RtpStreamRecv) SetRtx(payloadType uint8, ssrc uint32) {
+	r.params.RtxPayloadType = payloadType
+	r.params.RtxSsrc = ssrc
+}
+
+// ReceiveRtxPacket decodes the given RTX packet into the original one. It
+// returns false if the packet must be discarded, which includes recovering a
+// packet that was not NACKed.
+func (r *RtpStreamRecv) ReceiveRtxPacket(packet *RtpPacket) bool {
+	if !r.params.UseNack {
+		r.logger.Warn("NACK not supported, ignoring RTX packet")
+		return false
+	}
+
+	if !r.HasRtx() || packet.GetSsrc() != r.params.RtxSsrc {
+		r.logger.Warn("packet ssrc does not match stream RTX ssrc", "packetSsrc", packet.GetSsrc())
+		return false
+	}
+
+	if packet.PayloadType != r.params.RtxPayloadType {
+		r.logger.Warn("ignoring RTX packet with invalid payload type", "payloadType", packet.PayloadType)
+		return false
+	}
+
+	rtxSeq := packet.SequenceNumber
+
+	if !packet.RtxDecode(r.params.PayloadType, r.params.Ssrc) {
+		r.logger.Debug("ignoring empty RTX packet", "rtxSeq", rtxSeq)
+		return false
+	}
+
+	r.logger.Debug("RTX packet decoded", "rtxSeq", rtxSeq, "seq", packet.SequenceNumber)
+
+	if !r.updateSeq(packet) {
+		r.logger.Warn("invalid RTX packet sequence number, packet discarded", "seq", packet.SequenceNumber)
+		return false
+	}
+
+	if r.nackGenerator == nil {
+		return false
+	}
+
+	// Only NACKed packets are accepted.
+	r.nackMu.Lock()
+	nacked := !r.paused.Load() && r.nackGenerator.ReceivePacket(packet, true)
+	r.nackMu.Unlock()
+
+	if !nacked {
+		return false
+	}
+
+	r.packetsRetransmitted++
+	r.packetsRepaired++
+
+	// Increase transmission counter.
+	r.transmissionCounter.Update(packet)
+
+	return true
+}
+
+// ReceiveSenderReport keeps the NTP time in ms and the RTP timestamp of the
+// last SR of the stream.
+func (r *RtpStreamRecv) ReceiveSenderReport(ntpMs uint64, rtpTs uint32) {
+	r.lastSrNtpMs = ntpMs
+	r.lastSrRtpTs = rtpTs
+}
+
+// GetSenderReportNtpMs returns the NTP time in ms of the last SR, zero if none
+// was received.
+func (r *RtpStreamRecv) GetSenderReportNtpMs() uint64 {
+	return r.lastSrNtpMs
+}
+
+// GetSenderReportTs returns the RTP timestamp of the last SR.
+func (r *RtpStreamRecv) GetSenderReportTs() uint32 {
+	return r.lastSrRtpTs
+}
+
+// ReceiveRtcpSenderReport keeps the time of the given SR so the next receiver
+// report can tell the sender how long ago it was received, and its NTP to RTP
+// timestamp mapping.
+func (r *RtpStreamRecv) ReceiveRtcpSenderReport(report *rtcp.SenderReport) {
+	ntp := util.Ntp{Seconds: report.NtpSec, Fractions: report.NtpFrac}
+
+	r.lastSrReceivedMs = uint64(time.Now().UnixMilli())
+	r.lastSrTimestamp = ntp.Compact()
+
+	r.ReceiveSenderReport(util.NtpToTimeMs(ntp), report.RtpTs)
+}
+
+// GetRtcpReceiverReport returns the report block of the stream, updating the
+// loss figures and the score since the previous call.
+func (r *RtpStreamRecv) GetRtcpReceiverReport() rtcp.ReceptionReport {
+	nowMs := uint64(time.Now().UnixMilli())
+
+	expected := r.GetExpectedPackets()
+	received := uint32(r.transmissionCounter.GetPacketCount())
+
+	// More packets than expected may be received due to duplicates.
+	r.packetsLost = int32(int64(expected) - int64(received))
+
+	expectedInterval := int64(expected) - int64(r.expectedPrior)
+	r.expectedPrior = expected
+
+	receivedInterval := int64(received) - int64(r.receivedPrior)
+	r.receivedPrior = received
+
+	lostInterval := expectedInterval - receivedInterval
+
+	if expectedInterval <= 0 || lostInterval <= 0 {
+		r.fractionLost = 0
+	} else {
+		r.fractionLost = uint8(min(lostInterval<<8/expectedInterval, 255))
+	}
+
+	report := rtcp.ReceptionReport{
+		Ssrc:         r.params.Ssrc,
+		FractionLost: r.fractionLost,
+		TotalLost:    r.packetsLost,
+		LastSeq:      r.GetExtendedMaxSeq(),
+		Jitter:       uint32(r.jitter),
+		Lsr:          r.lastSrTimestamp,
+	}
+
+	// Delay since the last SR in 1/65536 seconds.
+	if r.lastSrReceivedMs != 0 {
+		report.Dlsr = uint32((nowMs - r.lastSrReceivedMs) * 65536 / 1000)
+	}
+
+	r.updateReceivedScore()
+
+	return report
+}
+
+// updateReceivedScore computes the score of the packets received since the
+// previous call.
+func (r *RtpStreamRecv) updateReceivedScore() {
+	totalExpected := r.GetExpectedPackets()
+	expected := totalExpected - r.expectedPriorScore
+	r.expectedPriorScore = totalExpected
+
+	totalReceived := uint32(r.transmissionCounter.GetPacketCount())
+	received := totalReceived - r.receivedPriorScore
+	r.receivedPriorScore = totalReceived
+
+	totalRepaired := r.packetsRepaired
+	repaired := totalRepaired - r.repairedPriorScore
+	r.repairedPriorScore = totalRepaired
+
+	// Nothing is expected while paused.
+	if r.paused.Load() {
+		return
+	}
+
+	// Received packets include the repaired ones, which were lost at first.
+	var lost uint32
+	if expected > received {
+		lost = expected - received
+	}
+	lost += repaired
+
+	// Every repaired packet was retransmitted once.
+	score := computeScore(expected, lost, repaired, repaired, 0)
+
+	if previousScore, changed := r.updateScore(score); changed {
+		r.listener.OnRtpStreamScore(r, r.score, previousScore)
+	}
+}
+
+func (r *RtpStreamRecv) RequestKeyFrame() {
+	if r.params.UsePli {
+		r.pliCount++
+	} else if r.params.UseFir {
+		r.firCount++
+	} else {
+		return
+	}
+
+	r.listener.OnRtpStreamKeyFrameRequired(r)
+}
+
+func (r *RtpStreamRecv) Pause() {
+	r.nackMu.Lock()
+	defer r.nackMu.Unlock()
+
+	r.paused.Store(true)
+
+	if r.nackGenerator != nil {
+		r.nackGenerator.Reset()
+	}
+}
+
+func (r *RtpStreamRecv) Resume() {
+	r.paused.Store(false)
+
+	if r.activeSinceMs.Load() != 0 {
+		r.activeSinceMs.Store(uint64(time.Now().UnixMilli()))
+	}
+}
+
+// calculateJitter updates the interarrival jitter with the given packet RTP
+// timestamp and arrival time.
+func (r *RtpStreamRecv) calculateJitter(rtpTimestamp uint32, nowMs uint64) {
+	if r.params.ClockRate == 0 {
+		return
+	}
+
+	transit := int32(uint32(nowMs*uint64(r.params.ClockRate)/1000) - rtpTimestamp)
+
+	// First packet, nothing to compare with.
+	if !r.transitStarted {
+		r.transitStarted = true
+		r.transit = transit
+		return
+	}
+
+	d := transit - r.transit
+	r.transit = transit
+	if d < 0 {
+		d = -d
+	}
+
+	r.jitter += (float64(d) - r.jitter) / 16
+}
+
+func (r *RtpStreamRecv) GetBitrate(nowMs uint64) uint32 {
+	return r.transmissionCounter.GetBitrate(nowMs)
+}
+
+func (r *RtpStreamRecv) GetBitrateForLayers(nowMs uint64, spatialLayer, temporalLayer uint8) uint32 {
+	return r.transmissionCounter.GetBitrateForLayers(nowMs, spatialLayer, temporalLayer)
+}
+
+func (r *RtpStreamRecv) GetSpatialLayerBitrate(nowMs uint64, spatialLayer uint8) uint32 {
+	return r.transmissionCounter.GetSpatialLayerBitrate(nowMs, spatialLayer)
+}
+
+func (r *RtpStreamRecv) GetLayerBitrate(nowMs uint64, spatialLayer, temporalLayer uint8) uint32 {
+	return r.transmissionCounter.GetLayerBitrate(nowMs, spatialLayer, temporalLayer)
+}
+
+func (r *RtpStreamRecv) GetStats() RtpStreamStats {
+	nowMs := uint64(time.Now().UnixMilli())
+
+	stats := RtpStreamStats{
+		Type:        "inbound-rtp",
+		PacketCount: r.transmissionCounter.GetPacketCount(),
+		ByteCount:   r.transmissionCounter.GetBytes(),
+		Bitrate:     r.transmissionCounter.GetBitrate(nowMs),
+	}
+	r.fillStats(&stats)
+	stats.Jitter = uint32(r.jitter)
+
+	return stats
+}
+
+func (r *RtpStreamRecv) Close() {
+	if r.nackGenerator != nil {
+		r.nackGenerator.Close()
+	}
+}
+
+func (r *RtpStreamRecv) OnNackGeneratorNackRequired(seqNumbers []uint16) {
+	r.nackCount++
+	r.nackPacketCount += uint32(len(seqNumbers))
+
+	r.listener.OnRtpStreamNackRequired(r, seqNumbers)
+}
+
+func (r *RtpStreamRecv) OnNackGeneratorKeyFrameRequired() {
+	r.logger.Debug("key frame required")
+
+	r.RequestKeyFrame()
+}
@@ -0,0 +1,149 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+type TestRtpStreamRecvListener struct {
+	nackedSeqNumbers []uint16
+	keyFrameRequests int
+	scores           []uint8
+}
+
+func (l *TestRtpStreamRecvListener) OnRtpStreamNackRequired(rtpStream *RtpStreamRecv, seqNumbers []uint16) {
+	l.nackedSeqNumbers = append(l.nackedSeqNumbers, seqNumbers...)
+}
+
+func (l *TestRtpStreamRecvListener) OnRtpStreamKeyFrameRequired(rtpStream *RtpStreamRecv) {
+	l.keyFrameRequests++
+}
+
+func (l *TestRtpStreamRecvListener) OnRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8) {
+	l.scores = append(l.scores, score)
+}
+
+func createTestRtpStreamRecv() *RtpStreamRecv {
+	return NewRtpStreamRecv(&TestRtpStreamRecvListener{}, RtpStreamParams{
+		Ssrc:        1111,
+		PayloadType: 100,
+		MimeType:    "audio/opus",
+		ClockRate:   48000,
+	}, 0)
+}
+
+func TestRtpStreamRecv(t *testing.T) {
+	t.Run("receiver reports carry cumulative and fractional loss", func(t *testing.T) {
+		rtpStream := createTestRtpStreamRecv()
+
+		// 10 packets expected, 2 lost.
+		for _, seq := range []uint16{1, 2, 3, 5, 6, 7, 8, 10} {
+			require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil)))
+		}
+
+		report := rtpStream.GetRtcpReceiverReport()
+		require.EqualValues(t, 1111, report.Ssrc)
+		require.EqualValues(t, 10, report.LastSeq)
+		require.EqualValues(t, 2, report.TotalLost)
+		require.EqualValues(t, 2*256/10, report.FractionLost)
+		require.Zero(t, report.Lsr)
+		require.Zero(t, report.Dlsr)
+
+		// No loss since the previous report.
+		for seq := uint16(11); seq <= 20; seq++ {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+
+		report = rtpStream.GetRtcpReceiverReport()
+		require.EqualValues(t, 2, report.TotalLost)
+		require.Zero(t, report.FractionLost)
+
+		stats := rtpStream.GetStats()
+		require.EqualValues(t, 2, stats.PacketsLost)
+		require.Zero(t, stats.FractionLost)
+	})
+
+	t.Run("sequence number cycles extend the highest sequence number", func(t *testing.T) {
+		rtpStream := createTestRtpStreamRecv()
+
+		for _, seq := range []uint16{65534, 65535, 0, 1} {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+
+		report := rtpStream.GetRtcpReceiverReport()
+		require.EqualValues(t, 1<<16|1, report.LastSeq)
+		require.Zero(t, report.TotalLost)
+	})
+
+	t.Run("large sequence number jumps are discarded until confirmed", func(t *testing.T) {
+		rtpStream := createTestRtpStreamRecv()
+
+		require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 100, 100, nil)))
+		require.False(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 20000, 100, nil)))
+
+		// Reordered packet.
+		require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 99, 100, nil)))
+
+		// Two sequential packets restart the sequence.
+		require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 20001, 100, nil)))
+		require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 20002, 100, nil)))
+
+		require.EqualValues(t, 20002, rtpStream.GetExtendedMaxSeq())
+		require.EqualValues(t, 2, rtpStream.GetExpectedPackets())
+		require.EqualValues(t, 1, rtpStream.GetStats().PacketsDiscarded)
+	})
+
+	t.Run("computes the interarrival jitter", func(t *testing.T) {
+		rtpStream := createTestRtpStreamRecv()
+
+		// 20 ms packets, the second one arriving 10 ms late.
+		rtpStream.calculateJitter(0, 1000)
+		rtpStream.calculateJitter(960, 1030)
+		require.InDelta(t, 480.0/16, rtpStream.jitter, 0.001)
+
+		rtpStream.calculateJitter(1920, 1040)
+		require.InDelta(t, 30+(480-30)/16.0, rtpStream.jitter, 0.001)
+	})
+
+	t.Run("receiver reports reference the last sender report", func(t *testing.T) {
+		rtpStream := createTestRtpStreamRecv()
+		rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 1, 100, nil))
+
+		rtpStream.ReceiveRtcpSenderReport(&rtcp.SenderReport{Ssrc: 1111, NtpSec: 0x12345678, NtpFrac: 0x9abcdef0})
+		rtpStream.lastSrReceivedMs -= 500
+
+		report := rtpStream.GetRtcpReceiverReport()
+		require.EqualValues(t, 0x56789abc, report.Lsr)
+		require.InDelta(t, 65536/2, report.Dlsr, 65536/100)
+	})
+	t.Run("receiver reports update the score", func(t *testing.T) {
+		listener := &TestRtpStreamRecvListener{}
+		rtpStream := NewRtpStreamRecv(listener, RtpStreamParams{
+			Ssrc:        1111,
+			PayloadType: 100,
+			MimeType:    "audio/opus",
+			ClockRate:   48000,
+		}, 0)
+
+		for seq := uint16(1); seq <= 10; seq++ {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+		require.EqualValues(t, 10, rtpStream.GetScore())
+
+		// Still perfect, no event.
+		rtpStream.GetRtcpReceiverReport()
+		require.Empty(t, listener.scores)
+
+		// 4 out of 9 packets lost.
+		for seq := uint16(11); seq < 20; seq += 2 {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+		rtpStream.GetRtcpReceiverReport()
+
+		// (10*1 + 1*2) / 3.
+		require.Equal(t, []uint8{4}, listener.scores)
+		require.EqualValues(t, 4, rtpStream.GetStats().Score)
+	})
+}
@@ -0,0 +1,317 @@
+package rtc
+
+import (
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+	"github.com/jiyeyuran/mediasoup/internal/util"
+	"github.com/pion/rtp"
+)
+
+// RtpStreamSendDefaultRtt is the RTT in ms assumed until it is measured.
+const RtpStreamSendDefaultRtt = 100
+
+type RtpStreamSendListener interface {
+	OnRtpStreamRetransmitRtpPacket(rtpStream *RtpStreamSend, packet *RtpPacket)
+	OnRtpStreamScore(rtpStream *RtpStreamSend, score, previousScore uint8)
+}
+
+// RtpStreamSend is the sending side of a Consumer encoding.
+type RtpStreamSend struct {
+	RtpStream
+	listener              RtpStreamSendListener
+	started               bool
+	transmissionCounter   *rtpDataCounter
+	retransmissionCounter *rtpDataCounter
+	retransmissionBuffer  *RetransmissionBuffer
+	// rtxSeqManager numbers the RTX packets, whatever the original sequence
+	// number they carry.
+	rtxSeqManager *SeqManager[uint16]
+	// rtt is the round trip time in ms, zero if unknown.
+	rtt float32
+	// maxPacketTs is the highest RTP timestamp sent and maxPacketMs the time it
+	// was sent at, used to map NTP time to RTP time in sender reports.
+	maxPacketTs uint32
+	maxPacketMs uint64
+	// The *PriorScore fields are the counters at the time of the last score
+	// update.
+	sentPriorScore          uint32
+	lostPriorScore          int32
+	repairedPriorScore      uint32
+	retransmittedPriorScore uint32
+	logger                  *slog.Logger
+}
+
+func NewRtpStreamSend(listener RtpStreamSendListener, params RtpStreamParams) *RtpStreamSend {
+	r := &RtpStreamSend{
+		RtpStream:             newRtpStream(params),
+		listener:              listener,
+		transmissionCounter:   NewRtpDataCounter(RtpStreamDefaultWindowSizeMs),
+		retransmissionCounter: NewRtpDataCounter(RtpStreamDefaultWindowSizeMs),
+		rtxSeqManager:         NewSeqManager[uint16](),
+		logger:                slog.Default().With("typename", "RtpStreamSend", "ssrc", params.Ssrc),
+	}
+
+	// The RTX sequence numbers start after a random one.
+	r.rtxSeqManager.Input(uint16(rand.Uint32()))
+
+	if params.UseNack {
+		if r.GetKind() == MediaKindAudio {
+			r.retransmissionBuffer = NewRetransmissionBuffer(RetransmissionBufferMaxAudioAgeMs)
+		} else {
+			r.retransmissionBuffer = NewRetransmissionBuffer(RetransmissionBufferMaxVideoAgeMs)
+		}
+	}
+
+	return r
+}
+
+// ReceivePacket returns false if the packet must not be sent.
+func (r *RtpStreamSend) ReceivePacket(packet *RtpPacket) bool {
+	if packet.GetSsrc() != r.params.Ssrc {
+		r.logger.Warn("packet ssrc does not match stream ssrc", "packetSsrc", packet.GetSsrc())
+		return false
+	}
+
+	nowMs := uint64(time.Now().UnixMilli())
+
+	// Store the packet so it can be resent when NACKed.
+	if r.retransmissionBuffer != nil {
+		r.retransmissionBuffer.Insert(packet, nowMs)
+	}
+
+	// Increase transmission counter.
+	r.transmissionCounter.Update(packet)
+
+	if r.maxPacketMs == 0 || IsSeqHigherThan(packet.Timestamp, r.maxPacketTs) {
+		r.maxPacketTs = packet.Timestamp
+		r.maxPacketMs = nowMs
+	}
+
+	// First packet sent, the stream is considered healthy until a score is
+	// computed.
+	if !r.started {
+		r.started = true
+		r.score = 10
+	}
+
+	r.activeSinceMs.CompareAndSwap(0, nowMs)
+
+	return true
+}
+
+// ReceiveNack resends the requested packets still in the retransmission
+// buffer, over RTX if the stream has it. A packet is not resent again within
+// the RTT, since the previous retransmission may still be in flight.
+func (r *RtpStreamSend) ReceiveNack(nackPacket *rtcp.Nack) {
+	r.nackCount++
+
+	nowMs := uint64(time.Now().UnixMilli())
+	rtt := uint64(r.rtt)
+	if rtt == 0 {
+		rtt = RtpStreamSendDefaultRtt
+	}
+
+	for _, seq := range nackPacket.GetSequenceNumbers() {
+		r.nackPacketCount++
+
+		if r.retransmissionBuffer == nil {
+			continue
+		}
+
+		item := r.retransmissionBuffer.Get(seq, nowMs)
+		if item == nil {
+			r.logger.Debug("NACKed packet not found in the retransmission buffer", "seq", seq)
+			continue
+		}
+
+		if item.resentAtMs != 0 && nowMs-item.resentAtMs <= rtt {
+			r.logger.Debug("ignoring NACK for packet resent less than a RTT ago", "seq", seq)
+			continue
+		}
+
+		// The stored packet is kept as sent, the transport stamping the
+		// retransmitted one.
+		packet := item.packet.Clone()
+
+		if r.HasRtx() {
+			// Each retransmission takes the RTX sequence number following the
+			// last one sent.
+			r.rtxSeqManager.Sync(item.seq - 1)
+			rtxSeq, _ := r.rtxSeqManager.Input(item.seq)
+
+			packet.RtxEncode(r.params.RtxPayloadType, r.params.RtxSsrc, rtxSeq)
+		}
+
+		item.resentAtMs = nowMs
+		item.sentTimes++
+
+		r.packetsRetransmitted++
+		if item.sentTimes == 1 {
+			r.packetsRepaired++
+		}
+		r.retransmissionCounter.Update(packet)
+
+		r.listener.OnRtpStreamRetransmitRtpPacket(r, packet)
+	}
+}
+
+// GetRtxPaddingPacket returns a RTX packet with the given padding and no
+// payload, used to probe for more available bitrate, or nil if the stream has
+// no RTX or nothing has been sent yet. Missing the original sequence number,
+// it is discarded by the remote endpoint.
+func (r *RtpStreamSend) GetRtxPaddingPacket(paddingSize uint8) *RtpPacket {
+	if !r.HasRtx() || r.maxPacketMs == 0 {
+		return nil
+	}
+
+	// Take the RTX sequence number following the last one sent.
+	maxInput := r.rtxSeqManager.GetMaxInput()
+	r.rtxSeqManager.Sync(maxInput)
+	rtxSeq, _ := r.rtxSeqManager.Input(maxInput + 1)
+
+	packet := &RtpPacket{
+		Packet: rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Padding:        paddingSize > 0,
+				PayloadType:    r.params.RtxPayloadType,
+				SequenceNumber: rtxSeq,
+				Timestamp:      r.maxPacketTs,
+				SSRC:           r.params.RtxSsrc,
+			},
+			PaddingSize: paddingSize,
+		},
+	}
+	packet.Size = uint64(packet.MarshalSize())
+
+	return packet
+}
+
+// ReceiveRtcpReceiverReport updates the RTT and the loss figures reported by
+// the remote endpoint.
+func (r *RtpStreamSend) ReceiveRtcpReceiverReport(report *rtcp.ReceptionReport) {
+	nowMs := uint64(time.Now().UnixMilli())
+	compactNtp := util.TimeMsToNtp(nowMs).Compact()
+
+	// RTT in 1/65536 seconds, as defined in RFC 3550 section 6.4.1.
+	if report.Lsr != 0 && report.Dlsr != 0 && compactNtp > report.Lsr+report.Dlsr {
+		rtt := compactNtp - report.Lsr - report.Dlsr
+		r.rtt = float32(rtt>>16)*1000 + float32(rtt&0xFFFF)*1000/65536
+	}
+
+	r.packetsLost = report.TotalLost
+	r.fractionLost = report.FractionLost
+
+	r.updateSentScore()
+}
+
+// updateSentScore computes the score of the packets sent since the previous
+// receiver report.
+func (r *RtpStreamSend) updateSentScore() {
+	totalSent := uint32(r.transmissionCounter.GetPacketCount())
+	sent := totalSent - r.sentPriorScore
+	r.sentPriorScore = totalSent
+
+	totalLost := max(r.packetsLost, 0)
+	lost := uint32(max(totalLost-r.lostPriorScore, 0))
+	r.lostPriorScore = totalLost
+
+	totalRepaired := r.packetsRepaired
+	repaired := totalRepaired - r.repairedPriorScore
+	r.repairedPriorScore = totalRepaired
+
+	totalRetransmitted := r.packetsRetransmitted
+	retransmitted := totalRetransmitted - r.retransmittedPriorScore
+	r.retransmittedPriorScore = totalRetransmitted
+
+	// Nothing is sent while paused.
+	if r.paused.Load() {
+		return
+	}
+
+	score := uint8(10)
+
+	// The remote does not report repaired packets as lost.
+	if sent > 0 {
+		score = computeScore(sent, lost+repaired, repaired, retransmitted, r.rtt)
+	}
+
+	if previousScore, changed := r.updateScore(score); changed {
+		r.listener.OnRtpStreamScore(r, r.score, previousScore)
+	}
+}
+
+// GetRtcpSenderReport returns a sender report mapping the given NTP time to
+// the RTP time of the stream, or nil if nothing has been sent yet.
+func (r *RtpStreamSend) GetRtcpSenderReport(nowMs uint64) *rtcp.SenderReport {
+	if r.transmissionCounter.GetPacketCount() == 0 {
+		return nil
+	}
+
+	ntp := util.TimeMsToNtp(nowMs)
+
+	// Extrapolate the RTP timestamp of the last sent packet to now.
+	diffMs := nowMs - r.maxPacketMs
+	diffTs := uint32(diffMs * uint64(r.params.ClockRate) / 1000)
+
+	return &rtcp.SenderReport{
+		Ssrc:        r.params.Ssrc,
+		NtpSec:      ntp.Seconds,
+		NtpFrac:     ntp.Fractions,
+		RtpTs:       r.maxPacketTs + diffTs,
+		PacketCount: uint32(r.transmissionCounter.GetPacketCount()),
+		OctetCount:  uint32(r.transmissionCounter.GetBytes()),
+	}
+}
+
+// GetRtcpSdesChunk returns the SDES chunk carrying the stream CNAME.
+func (r *RtpStreamSend) GetRtcpSdesChunk() rtcp.SdesChunk {
+	return rtcp.SdesChunk{
+		Ssrc:  r.params.Ssrc,
+		Items: []rtcp.SdesItem{{Type: rtcp.SdesItemCname, Text: r.params.Cname}},
+	}
+}
+
+// GetRtt returns the round trip time in ms, zero if unknown.
+func (r *RtpStreamSend) GetRtt() float32 {
+	return r.rtt
+}
+
+func (r *RtpStreamSend) Pause() {
+	r.paused.Store(true)
+
+	if r.retransmissionBuffer != nil {
+		r.retransmissionBuffer.Clear()
+	}
+}
+
+func (r *RtpStreamSend) Resume() {
+	r.paused.Store(false)
+
+	if r.activeSinceMs.Load() != 0 {
+		r.activeSinceMs.Store(uint64(time.Now().UnixMilli()))
+	}
+}
+
+func (r *RtpStreamSend) GetBitrate(nowMs uint64) uint32 {
+	return r.transmissionCounter.GetBitrate(nowMs)
+}
+
+func (r *RtpStreamSend) GetStats() RtpStreamStats {
+	nowMs := uint64(time.Now().UnixMilli())
+
+	stats := RtpStreamStats{
+		Type:        "outbound-rtp",
+		PacketCount: r.transmissionCounter.GetPacketCount(),
+		ByteCount:   r.transmissionCounter.GetBytes(),
+		Bitrate:     r.transmissionCounter.GetBitrate(nowMs),
+	}
+	r.fillStats(&stats)
+	stats.RoundTripTime = r.rtt
+	stats.RetransmissionBitrate = r.retransmissionCounter.GetBitrate(nowMs)
+
+	return stats
+}
@@ -0,0 +1,269 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+	"github.com/jiyeyuran/mediasoup/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+type TestRtpStreamSendListener struct {
+	resentPackets []*RtpPacket
+	scores        []uint8
+}
+
+func (l *TestRtpStreamSendListener) OnRtpStreamRetransmitRtpPacket(rtpStream *RtpStreamSend, packet *RtpPacket) {
+	l.resentPackets = append(l.resentPackets, packet.Clone())
+}
+
+func (l *TestRtpStreamSendListener) OnRtpStreamScore(rtpStream *RtpStreamSend, score, previousScore uint8) {
+	l.scores = append(l.scores, score)
+}
+
+func createTestRtpStreamSendParams() RtpStreamParams {
+	return RtpStreamParams{
+		Ssrc:        1111,
+		PayloadType: 100,
+		MimeType:    "video/VP8",
+		ClockRate:   90000,
+		UseNack:     true,
+	}
+}
+
+func TestRtpStreamSend(t *testing.T) {
+	t.Run("resends NACKed packets", func(t *testing.T) {
+		listener := &TestRtpStreamSendListener{}
+		rtpStream := NewRtpStreamSend(listener, createTestRtpStreamSendParams())
+
+		for seq := uint16(65534); seq != 2; seq++ {
+			require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil)))
+		}
+
+		// Sequence number 10 was never sent.
+		rtpStream.ReceiveNack(&rtcp.Nack{MediaSsrc: 1111, Items: rtcp.NewNackItems([]uint16{65535, 1, 10})})
+
+		require.Len(t, listener.resentPackets, 2)
+		require.EqualValues(t, 65535, listener.resentPackets[0].SequenceNumber)
+		require.EqualValues(t, 1, listener.resentPackets[1].SequenceNumber)
+		require.EqualValues(t, 1111, listener.resentPackets[1].SSRC)
+		require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, listener.resentPackets[1].Payload)
+
+		stats := rtpStream.GetStats()
+		require.EqualValues(t, 1, stats.NackCount)
+		require.EqualValues(t, 3, stats.NackPacketCount)
+	})
+
+	t.Run("resends over RTX with its own sequence numbers", func(t *testing.T) {
+		listener := &TestRtpStreamSendListener{}
+		params := createTestRtpStreamSendParams()
+		params.RtxSsrc = 2222
+		params.RtxPayloadType = 101
+		rtpStream := NewRtpStreamSend(listener, params)
+
+		for seq := uint16(100); seq < 105; seq++ {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+
+		rtpStream.ReceiveNack(&rtcp.Nack{MediaSsrc: 1111, Items: rtcp.NewNackItems([]uint16{101, 103})})
+
+		require.Len(t, listener.resentPackets, 2)
+		for i, origSeq := range []uint16{101, 103} {
+			packet := listener.resentPackets[i]
+			require.EqualValues(t, 2222, packet.SSRC)
+			require.EqualValues(t, 101, packet.PayloadType)
+			require.Equal(t, origSeq, binary.BigEndian.Uint16(packet.Payload))
+			require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, packet.Payload[2:])
+		}
+		require.Equal(t, listener.resentPackets[0].SequenceNumber+1, listener.resentPackets[1].SequenceNumber)
+	})
+
+	t.Run("does not resend a packet twice within the RTT", func(t *testing.T) {
+		listener := &TestRtpStreamSendListener{}
+		params := createTestRtpStreamSendParams()
+		params.RtxSsrc = 2222
+		params.RtxPayloadType = 101
+		rtpStream := NewRtpStreamSend(listener, params)
+
+		rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 100, 100, nil))
+
+		nack := &rtcp.Nack{MediaSsrc: 1111, Items: rtcp.NewNackItems([]uint16{100})}
+		rtpStream.ReceiveNack(nack)
+		rtpStream.ReceiveNack(nack)
+		require.Len(t, listener.resentPackets, 1)
+
+		// Pretend the retransmission happened more than a RTT ago.
+		nowMs := uint64(time.Now().UnixMilli())
+		rtpStream.retransmissionBuffer.Get(100, nowMs).resentAtMs = nowMs - RtpStreamSendDefaultRtt - 1
+
+		rtpStream.ReceiveNack(nack)
+		require.Len(t, listener.resentPackets, 2)
+		require.EqualValues(t, 2, rtpStream.GetStats().PacketsRetransmitted)
+
+		// Every retransmission is a new RTX packet, the stored one being kept
+		// as sent.
+		first, second := listener.resentPackets[0], listener.resentPackets[1]
+		require.NotSame(t, first, second)
+		require.Equal(t, first.Payload, second.Payload)
+		require.Equal(t, first.SequenceNumber+1, second.SequenceNumber)
+
+		stored := rtpStream.retransmissionBuffer.Get(100, nowMs).packet
+		require.EqualValues(t, 1111, stored.SSRC)
+		require.EqualValues(t, 100, stored.SequenceNumber)
+		require.EqualValues(t, 100, stored.PayloadType)
+		require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, stored.Payload)
+	})
+
+	t.Run("creates RTX padding packets numbered along retransmissions", func(t *testing.T) {
+		listener := &TestRtpStreamSendListener{}
+		params := createTestRtpStreamSendParams()
+		params.RtxSsrc = 2222
+		params.RtxPayloadType = 101
+		rtpStream := NewRtpStreamSend(listener, params)
+
+		// Nothing to pad yet.
+		require.Nil(t, rtpStream.GetRtxPaddingPacket(RtpProbationMaxPaddingSize))
+
+		packet := createTestRtpPacket(t, 1111, 100, 100, nil)
+		packet.Timestamp = 3000
+		rtpStream.ReceivePacket(packet)
+		rtpStream.ReceiveNack(&rtcp.Nack{MediaSsrc: 1111, Items: rtcp.NewNackItems([]uint16{100})})
+		require.Len(t, listener.resentPackets, 1)
+		rtxSeq := listener.resentPackets[0].SequenceNumber
+
+		for i, paddingSize := range []uint8{RtpProbationMaxPaddingSize, 10} {
+			padding := rtpStream.GetRtxPaddingPacket(paddingSize)
+
+			require.EqualValues(t, 2222, padding.SSRC)
+			require.EqualValues(t, 101, padding.PayloadType)
+			require.Equal(t, rtxSeq+uint16(i)+1, padding.SequenceNumber)
+			require.EqualValues(t, 3000, padding.Timestamp)
+			require.Empty(t, padding.Payload)
+			require.True(t, padding.Padding)
+			require.Equal(t, paddingSize, padding.PaddingSize)
+			require.EqualValues(t, padding.MarshalSize(), padding.Size)
+		}
+
+		// Retransmissions follow the padding packets.
+		rtpStream.retransmissionBuffer.Get(100, uint64(time.Now().UnixMilli())).resentAtMs = 0
+		rtpStream.ReceiveNack(&rtcp.Nack{MediaSsrc: 1111, Items: rtcp.NewNackItems([]uint16{100})})
+		require.Equal(t, rtxSeq+3, listener.resentPackets[1].SequenceNumber)
+	})
+
+	t.Run("does not create RTX padding packets without RTX", func(t *testing.T) {
+		rtpStream := NewRtpStreamSend(&TestRtpStreamSendListener{}, createTestRtpStreamSendParams())
+
+		rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 100, 100, nil))
+
+		require.Nil(t, rtpStream.GetRtxPaddingPacket(RtpProbationMaxPaddingSize))
+	})
+
+	t.Run("does not store packets without NACK support", func(t *testing.T) {
+		listener := &TestRtpStreamSendListener{}
+		params := createTestRtpStreamSendParams()
+		params.UseNack = false
+		rtpStream := NewRtpStreamSend(listener, params)
+
+		rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 100, 100, nil))
+		rtpStream.ReceiveNack(&rtcp.Nack{MediaSsrc: 1111, Items: rtcp.NewNackItems([]uint16{100})})
+
+		require.Empty(t, listener.resentPackets)
+		require.EqualValues(t, 1, rtpStream.GetStats().NackPacketCount)
+	})
+	t.Run("sender reports map NTP time to RTP time", func(t *testing.T) {
+		rtpStream := NewRtpStreamSend(&TestRtpStreamSendListener{}, createTestRtpStreamSendParams())
+		require.Nil(t, rtpStream.GetRtcpSenderReport(uint64(time.Now().UnixMilli())))
+
+		for seq := uint16(1); seq <= 3; seq++ {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+		// Reordered timestamps do not move the mapping back.
+		rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 2, 100, nil))
+
+		nowMs := rtpStream.maxPacketMs + 100
+		report := rtpStream.GetRtcpSenderReport(nowMs)
+		require.NotNil(t, report)
+
+		ntp := util.TimeMsToNtp(nowMs)
+		require.EqualValues(t, 1111, report.Ssrc)
+		require.Equal(t, ntp.Seconds, report.NtpSec)
+		require.Equal(t, ntp.Fractions, report.NtpFrac)
+		// Timestamp of seq 3 plus 100 ms at 90 kHz.
+		require.EqualValues(t, 3*3000+9000, report.RtpTs)
+		require.EqualValues(t, 4, report.PacketCount)
+		require.EqualValues(t, 4*16, report.OctetCount)
+	})
+
+	t.Run("computes the RTT from receiver reports", func(t *testing.T) {
+		rtpStream := NewRtpStreamSend(&TestRtpStreamSendListener{}, createTestRtpStreamSendParams())
+
+		// SR sent 100 ms ago, held by the remote for 40 ms.
+		lsr := util.TimeMsToNtp(uint64(time.Now().UnixMilli()) - 100).Compact()
+		rtpStream.ReceiveRtcpReceiverReport(&rtcp.ReceptionReport{
+			Ssrc:         1111,
+			FractionLost: 25,
+			TotalLost:    3,
+			Lsr:          lsr,
+			Dlsr:         40 * 65536 / 1000,
+		})
+
+		require.InDelta(t, 60, rtpStream.GetRtt(), 5)
+
+		stats := rtpStream.GetStats()
+		require.InDelta(t, 60, stats.RoundTripTime, 5)
+		require.EqualValues(t, 3, stats.PacketsLost)
+		require.EqualValues(t, 25, stats.FractionLost)
+
+		// Reports without LSR keep the previous RTT.
+		rtpStream.ReceiveRtcpReceiverReport(&rtcp.ReceptionReport{Ssrc: 1111})
+		require.InDelta(t, 60, rtpStream.GetRtt(), 5)
+	})
+	t.Run("receiver reports update the score", func(t *testing.T) {
+		listener := &TestRtpStreamSendListener{}
+		rtpStream := NewRtpStreamSend(listener, createTestRtpStreamSendParams())
+
+		for seq := uint16(1); seq <= 10; seq++ {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+
+		rtpStream.ReceiveRtcpReceiverReport(&rtcp.ReceptionReport{Ssrc: 1111})
+		require.Empty(t, listener.scores)
+		require.EqualValues(t, 10, rtpStream.GetScore())
+
+		for seq := uint16(11); seq <= 20; seq++ {
+			rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+		}
+
+		// The remote lost all of them.
+		rtpStream.ReceiveRtcpReceiverReport(&rtcp.ReceptionReport{Ssrc: 1111, TotalLost: 10})
+
+		// (10*1 + 0*2) / 3.
+		require.Equal(t, []uint8{3}, listener.scores)
+	})
+
+	t.Run("sent packets do not reset a computed score", func(t *testing.T) {
+		listener := &TestRtpStreamSendListener{}
+		rtpStream := NewRtpStreamSend(listener, createTestRtpStreamSendParams())
+
+		require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, 1, 100, nil)))
+		require.EqualValues(t, 10, rtpStream.GetScore())
+
+		// The remote loses everything.
+		seq := uint16(2)
+		for i := int32(1); rtpStream.GetScore() != 0; i++ {
+			require.Less(t, i, int32(100))
+			for ; seq <= uint16(i*10); seq++ {
+				rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil))
+			}
+			rtpStream.ReceiveRtcpReceiverReport(&rtcp.ReceptionReport{Ssrc: 1111, TotalLost: i*10 + 1})
+		}
+		require.EqualValues(t, 0, listener.scores[len(listener.scores)-1])
+		scores := len(listener.scores)
+
+		require.True(t, rtpStream.ReceivePacket(createTestRtpPacket(t, 1111, seq, 100, nil)))
+		require.EqualValues(t, 0, rtpStream.GetScore())
+		require.Len(t, listener.scores, scores)
+	})
+}
@@ -0,0 +1,65 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeScore(t *testing.T) {
+	testCases := []struct {
+		name                                 string
+		total, lost, repaired, retransmitted uint32
+		rtt                                  float32
+		score                                uint8
+	}{
+		{"nothing expected", 0, 0, 0, 0, 0, 0},
+		{"no loss", 100, 0, 0, 0, 0, 10},
+		{"loss", 100, 10, 0, 0, 0, 7},
+		{"more lost than expected", 100, 200, 0, 0, 0, 0},
+		{"loss repaired by a single retransmission", 100, 10, 10, 10, 0, 9},
+		{"loss repaired by many retransmissions", 100, 10, 10, 40, 0, 7},
+		{"no loss with acceptable RTT", 100, 0, 0, 0, RtpStreamScoreMaxRttMs, 10},
+		{"no loss with high RTT", 100, 0, 0, 0, 2 * RtpStreamScoreMaxRttMs, 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.score, computeScore(tc.total, tc.lost, tc.repaired, tc.retransmitted, tc.rtt))
+		})
+	}
+}
+
+func TestRtpStreamScore(t *testing.T) {
+	t.Run("averages the last scores weighting the recent ones more", func(t *testing.T) {
+		rtpStream := newRtpStream(RtpStreamParams{})
+
+		previousScore, changed := rtpStream.updateScore(10)
+		require.Zero(t, previousScore)
+		require.True(t, changed)
+		require.EqualValues(t, 10, rtpStream.GetScore())
+
+		// (10*1 + 0*2) / 3.
+		previousScore, changed = rtpStream.updateScore(0)
+		require.EqualValues(t, 10, previousScore)
+		require.True(t, changed)
+		require.EqualValues(t, 3, rtpStream.GetScore())
+
+		// (10*1 + 0*2 + 3*3) / 6.
+		_, changed = rtpStream.updateScore(3)
+		require.False(t, changed)
+		require.EqualValues(t, 3, rtpStream.GetScore())
+	})
+
+	t.Run("only keeps the last scores", func(t *testing.T) {
+		rtpStream := newRtpStream(RtpStreamParams{})
+
+		rtpStream.updateScore(0)
+		for i := 0; i < RtpStreamScoreHistogramLength; i++ {
+			rtpStream.updateScore(10)
+		}
+
+		require.Len(t, rtpStream.scores, RtpStreamScoreHistogramLength)
+		require.EqualValues(t, 10, rtpStream.GetScore())
+	})
+}
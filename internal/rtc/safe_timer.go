@@ -6,30 +6,34 @@ import (
 )
 
 type SafeTimer struct {
-	timer    *time.Timer
-	active   bool
-	mu       sync.Mutex
-	callback func() // Define a callback function
+	timer  *time.Timer
+	active bool
+	// generation is increased on every Reset and Stop so an expiration
+	// racing with them does not call the callback.
+	generation uint64
+	mu         sync.Mutex
+	callback   func() // Define a callback function
 }
 
 // NewSafeTimer creates and starts a new SafeTimer with the given duration and callback.
 func NewSafeTimer(duration time.Duration, cb func()) *SafeTimer {
 	st := &SafeTimer{
-		timer:    time.NewTimer(duration),
-		active:   true,
 		callback: cb,
 	}
-	go st.waitTimer()
+	st.Reset(duration)
 	return st
 }
 
-// waitTimer waits for the timer to expire, calls the callback, and sets the active flag to false.
-func (st *SafeTimer) waitTimer() {
-	<-st.timer.C
+// onTimer calls the callback and sets the active flag to false, unless the
+// timer was reset or stopped since it was armed. The callback may reset the
+// timer.
+func (st *SafeTimer) onTimer(generation uint64) {
 	st.mu.Lock()
-	if st.active {
-		st.active = false
+	if generation != st.generation || !st.active {
+		st.mu.Unlock()
+		return
 	}
+	st.active = false
 	st.mu.Unlock()
 	if st.callback != nil {
 		st.callback() // Execute the callback function
@@ -40,22 +44,25 @@ func (st *SafeTimer) waitTimer() {
 func (st *SafeTimer) Stop() bool {
 	st.mu.Lock()
 	defer st.mu.Unlock()
-	stopped := st.timer.Stop()
-	if !stopped && st.active {
-		<-st.timer.C // Ensure that the channel is drained.
-	}
+	stopped := st.active
+	st.timer.Stop()
+	st.generation++
 	st.active = false
 	return stopped
 }
 
-// Reset resets the timer to a new duration.
+// Reset resets the timer to a new duration, whether it already fired or not.
 func (st *SafeTimer) Reset(duration time.Duration) {
 	st.mu.Lock()
 	defer st.mu.Unlock()
-	if !st.timer.Stop() && st.active {
-		<-st.timer.C // Drain the channel if the timer already expired.
+	if st.timer != nil {
+		st.timer.Stop()
 	}
-	st.timer.Reset(duration)
+	st.generation++
+	generation := st.generation
+	st.timer = time.AfterFunc(duration, func() {
+		st.onTimer(generation)
+	})
 	st.active = true
 }
 
@@ -97,4 +97,24 @@ func TestSafeTimer(t *testing.T) {
 		require.False(t, stopped)
 		require.False(t, timer.IsActive())
 	})
+
+	t.Run("reset from the callback fires again", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(3)
+
+		var timer *SafeTimer
+		calls := 0
+		timer = NewSafeTimer(time.Hour, func() {
+			calls++
+			wg.Done()
+			if calls < 3 {
+				timer.Reset(10 * time.Millisecond)
+			}
+		})
+		timer.Reset(10 * time.Millisecond)
+
+		wg.Wait()
+		require.Equal(t, 3, calls)
+		require.False(t, timer.IsActive())
+	})
 }
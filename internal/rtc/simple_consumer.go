@@ -0,0 +1,284 @@
+package rtc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+// SimpleConsumer forwards a single Producer stream.
+type SimpleConsumer struct {
+	*consumer
+	rtpStream         *RtpStreamSend
+	producerRtpStream *RtpStreamRecv
+	rtpSeqManager     *SeqManager[uint16]
+	keyFrameSupported bool
+	syncRequired      bool
+	tsSyncRequired    bool
+	tsOffset          uint32
+	lastSentTs        uint32
+	lastSentAtMs      uint64
+	encodingContext   *codecs.EncodingContext
+}
+
+func NewSimpleConsumer(id string, listener ConsumerListener, options *ConsumerOptions) *SimpleConsumer {
+	c := &SimpleConsumer{
+		consumer:      newConsumer(ConsumerTypeSimple, id, listener, options),
+		rtpSeqManager: NewSeqManager[uint16](),
+		syncRequired:  true,
+	}
+
+	params := c.createRtpStreamParams(0)
+	c.rtpStream = NewRtpStreamSend(c, params)
+	c.keyFrameSupported = codecs.CanBeKeyFrame(params.MimeType)
+
+	// Create the encoding context for Opus, so DTX packets can be dropped.
+	if c.kind == MediaKindAudio {
+		switch strings.ToLower(params.MimeType) {
+		case "audio/opus", "audio/multiopus":
+			c.encodingContext = newEncodingContext(codecs.EncodingContextParams{})
+			c.encodingContext.SetIgnoreDtx(options.IgnoreDtx)
+		}
+	}
+
+	if c.IsPaused() || c.IsProducerPaused() {
+		c.rtpStream.Pause()
+	}
+
+	return c
+}
+
+func (c *SimpleConsumer) Pause() {
+	if !c.pause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *SimpleConsumer) Resume() {
+	if !c.resume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *SimpleConsumer) ProducerPaused() {
+	if !c.producerPause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *SimpleConsumer) ProducerResumed() {
+	if !c.producerResume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *SimpleConsumer) ProducerRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	c.producerRtpStream = rtpStream
+
+	// Ask for a key frame to start sending.
+	if c.syncRequired && c.IsActive() {
+		c.RequestKeyFrame()
+	}
+}
+
+func (c *SimpleConsumer) ProducerNewRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	// The Producer stream changed, so sequence numbers and timestamps of the
+	// new stream are not related to the ones sent so far.
+	if c.producerRtpStream != nil && c.producerRtpStream != rtpStream {
+		c.syncRequired = true
+		c.tsSyncRequired = true
+	}
+
+	c.ProducerRtpStream(rtpStream, mappedSsrc)
+}
+
+func (c *SimpleConsumer) ProducerRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8) {
+	c.emitScore()
+}
+
+func (c *SimpleConsumer) SendRtpPacket(packet *RtpPacket) {
+	if !c.IsActive() {
+		return
+	}
+
+	// If we need to sync, support key frames and this is not a key frame, ignore
+	// the packet.
+	if c.syncRequired && c.keyFrameSupported && !packet.IsKeyFrame() {
+		return
+	}
+
+	// Packets with only padding are not forwarded.
+	if len(packet.Payload) == 0 {
+		c.rtpSeqManager.Drop(packet.SequenceNumber)
+		return
+	}
+
+	// NOTE: This may happen if this Consumer supports just some codecs of those
+	// in the corresponding Producer.
+	if !c.isSupportedPayloadType(packet.PayloadType) {
+		c.rtpSeqManager.Drop(packet.SequenceNumber)
+		return
+	}
+
+	if c.encodingContext != nil {
+		if _, ok := packet.ProcessPayload(c.encodingContext, packet.Payload); !ok {
+			c.rtpSeqManager.Drop(packet.SequenceNumber)
+			return
+		}
+	}
+
+	nowMs := uint64(time.Now().UnixMilli())
+
+	// Whether this is the first packet after re-sync.
+	isSyncPacket := c.syncRequired
+
+	// Sync sequence number and timestamp if required.
+	if isSyncPacket {
+		if packet.IsKeyFrame() {
+			c.logger.Debug("sync key frame received")
+		}
+
+		c.rtpSeqManager.Sync(packet.SequenceNumber - 1)
+
+		// Keep the timestamp going forward as if the new stream had been there
+		// since the last sent packet.
+		if c.tsSyncRequired {
+			elapsedTs := uint32((nowMs - c.lastSentAtMs) * uint64(c.rtpStream.GetClockRate()) / 1000)
+			c.tsOffset = packet.Timestamp - (c.lastSentTs + max(elapsedTs, 1))
+			c.tsSyncRequired = false
+		}
+
+		c.syncRequired = false
+	}
+
+	// Update RTP seq number and timestamp.
+	seq, ok := c.rtpSeqManager.Input(packet.SequenceNumber)
+	if !ok {
+		if c.encodingContext != nil {
+			packet.RestorePayload()
+		}
+		return
+	}
+	timestamp := packet.Timestamp - c.tsOffset
+
+	// Save original packet fields.
+	origSsrc := packet.SSRC
+	origSeq := packet.SequenceNumber
+	origTimestamp := packet.Timestamp
+
+	// Rewrite packet.
+	packet.SSRC = c.rtpParameters.Encodings[0].Ssrc
+	packet.SequenceNumber = seq
+	origHeaderExtensions := packet.MapHeaderExtensions(c.headerExtensionIds, c.rtpParameters.Mid)
+	packet.Timestamp = timestamp
+
+	if isSyncPacket {
+		c.logger.Debug("sending sync packet", "ssrc", packet.SSRC, "seq", seq,
+			"ts", timestamp, "origSeq", origSeq, "origTs", origTimestamp)
+	}
+
+	// Process the packet.
+	if c.rtpStream.ReceivePacket(packet) {
+		c.lastSentTs = timestamp
+		c.lastSentAtMs = nowMs
+
+		// Send the packet.
+		c.listener.OnConsumerSendRtpPacket(c, packet)
+	} else {
+		c.logger.Warn("failed to send packet", "ssrc", packet.SSRC, "seq", seq, "origSeq", origSeq)
+	}
+
+	// Restore packet fields.
+	packet.SSRC = origSsrc
+	packet.SequenceNumber = origSeq
+	packet.RestoreHeaderExtensions(origHeaderExtensions)
+	packet.Timestamp = origTimestamp
+
+	// Restore the original payload if needed.
+	if c.encodingContext != nil {
+		packet.RestorePayload()
+	}
+}
+
+func (c *SimpleConsumer) ReceiveNack(nackPacket *rtcp.Nack) {
+	if !c.IsActive() {
+		return
+	}
+
+	c.rtpStream.ReceiveNack(nackPacket)
+}
+
+func (c *SimpleConsumer) ReceiveRtcpReceiverReport(report *rtcp.ReceptionReport) {
+	c.rtpStream.ReceiveRtcpReceiverReport(report)
+}
+
+func (c *SimpleConsumer) GetRtcp(nowMs uint64) []rtcp.Packet {
+	return getRtcpPackets(nowMs, c.rtpStream)
+}
+
+func (c *SimpleConsumer) RequestKeyFrame() {
+	if c.kind != MediaKindVideo {
+		return
+	}
+
+	mappedSsrc := c.consumableRtpEncodings[0].Ssrc
+
+	c.listener.OnConsumerKeyFrameRequested(c, mappedSsrc)
+}
+
+func (c *SimpleConsumer) GetScore() ConsumerScore {
+	score := ConsumerScore{
+		Score:          c.rtpStream.GetScore(),
+		ProducerScores: []uint8{0},
+	}
+
+	if c.producerRtpStream != nil {
+		score.ProducerScore = c.producerRtpStream.GetScore()
+		score.ProducerScores[0] = score.ProducerScore
+	}
+
+	return score
+}
+
+func (c *SimpleConsumer) GetStats() []RtpStreamStats {
+	stats := []RtpStreamStats{c.rtpStream.GetStats()}
+
+	if c.producerRtpStream != nil {
+		stats = append(stats, c.producerRtpStream.GetStats())
+	}
+
+	return stats
+}
+
+func (c *SimpleConsumer) Close() {}
+
+func (c *SimpleConsumer) userOnPaused() {
+	c.rtpStream.Pause()
+}
+
+func (c *SimpleConsumer) userOnResumed() {
+	c.syncRequired = true
+	c.rtpStream.Resume()
+
+	if c.IsActive() {
+		c.RequestKeyFrame()
+	}
+}
+
+func (c *SimpleConsumer) OnRtpStreamRetransmitRtpPacket(rtpStream *RtpStreamSend, packet *RtpPacket) {
+	c.listener.OnConsumerRetransmitRtpPacket(c, packet)
+}
+
+func (c *SimpleConsumer) OnRtpStreamScore(rtpStream *RtpStreamSend, score, previousScore uint8) {
+	c.emitScore()
+}
+
+func (c *SimpleConsumer) emitScore() {
+	c.listener.OnConsumerScore(c, c.GetScore())
+}
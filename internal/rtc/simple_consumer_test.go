@@ -0,0 +1,380 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+type TestSentPacket struct {
+	ssrc      uint32
+	seq       uint16
+	timestamp uint32
+	marker    bool
+}
+
+type TestConsumerListener struct {
+	sentPackets         []TestSentPacket
+	resentPackets       []TestSentPacket
+	scores              []ConsumerScore
+	keyFrameRequests    []uint32
+	bitrateChanges      int
+	zeroBitrates        int
+	onKeyFrameRequested func(mappedSsrc uint32)
+	onSendRtpPacket     func(packet *RtpPacket)
+}
+
+func (l *TestConsumerListener) OnConsumerSendRtpPacket(consumer Consumer, packet *RtpPacket) {
+	l.sentPackets = append(l.sentPackets, TestSentPacket{
+		ssrc:      packet.SSRC,
+		seq:       packet.SequenceNumber,
+		timestamp: packet.Timestamp,
+		marker:    packet.Marker,
+	})
+	if l.onSendRtpPacket != nil {
+		l.onSendRtpPacket(packet)
+	}
+}
+
+func (l *TestConsumerListener) OnConsumerRetransmitRtpPacket(consumer Consumer, packet *RtpPacket) {
+	l.resentPackets = append(l.resentPackets, TestSentPacket{
+		ssrc:      packet.SSRC,
+		seq:       packet.SequenceNumber,
+		timestamp: packet.Timestamp,
+		marker:    packet.Marker,
+	})
+}
+
+func (l *TestConsumerListener) OnConsumerKeyFrameRequested(consumer Consumer, mappedSsrc uint32) {
+	l.keyFrameRequests = append(l.keyFrameRequests, mappedSsrc)
+	if l.onKeyFrameRequested != nil {
+		l.onKeyFrameRequested(mappedSsrc)
+	}
+}
+
+func (l *TestConsumerListener) OnConsumerScore(consumer Consumer, score ConsumerScore) {
+	l.scores = append(l.scores, score)
+}
+
+func (l *TestConsumerListener) OnConsumerNeedBitrateChange(consumer Consumer) {
+	l.bitrateChanges++
+}
+
+func (l *TestConsumerListener) OnConsumerNeedZeroBitrate(consumer Consumer) {
+	l.zeroBitrates++
+}
+
+func (l *TestConsumerListener) sentSeqs() []uint16 {
+	seqs := make([]uint16, 0, len(l.sentPackets))
+	for _, packet := range l.sentPackets {
+		seqs = append(seqs, packet.seq)
+	}
+	return seqs
+}
+
+func createTestSimpleConsumerOptions(kind MediaKind) *ConsumerOptions {
+	mimeType := "video/VP8"
+	if kind == MediaKindAudio {
+		mimeType = "audio/opus"
+	}
+	return &ConsumerOptions{
+		ProducerId: "p1",
+		Kind:       kind,
+		RtpParameters: RtpParameters{
+			Codecs: []*RtpCodecParameters{
+				{MimeType: mimeType, PayloadType: 100, ClockRate: 90000},
+			},
+			Encodings: []RtpEncodingParameters{{Ssrc: 5555}},
+		},
+		ConsumableRtpEncodings: []RtpEncodingParameters{{Ssrc: 9001}},
+	}
+}
+
+func TestSimpleConsumer(t *testing.T) {
+	t.Run("forwards packets rewriting ssrc and sequence numbers", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimpleConsumer("c1", listener, createTestSimpleConsumerOptions(MediaKindAudio))
+
+		for _, seq := range []uint16{1000, 1001, 1002} {
+			packet := createTestRtpPacket(t, 9001, seq, 100, nil)
+			consumer.SendRtpPacket(packet)
+
+			// Original fields are restored once sent.
+			require.EqualValues(t, 9001, packet.SSRC)
+			require.Equal(t, seq, packet.SequenceNumber)
+		}
+
+		// Padding only packet.
+		padding := createTestRtpPacket(t, 9001, 1003, 100, nil)
+		padding.Payload = nil
+		consumer.SendRtpPacket(padding)
+
+		// Unsupported payload type.
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 1004, 111, nil))
+
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 1005, 100, nil))
+
+		require.Equal(t, []uint16{1, 2, 3, 4}, listener.sentSeqs())
+		for _, packet := range listener.sentPackets {
+			require.EqualValues(t, 5555, packet.ssrc)
+		}
+
+		stats := consumer.GetStats()
+		require.Len(t, stats, 1)
+		require.EqualValues(t, 4, stats[0].PacketCount)
+	})
+
+	t.Run("maps header extensions into the consumer ones", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		options := createTestSimpleConsumerOptions(MediaKindAudio)
+		options.RtpParameters.Mid = "1"
+		options.RtpParameters.HeaderExtensions = []RtpHeaderExtensionParameters{
+			{Uri: MidHeaderExtensionUri, Id: 1},
+			{Uri: AbsSendTimeHeaderExtensionUri, Id: 2},
+			{Uri: SsrcAudioLevelHeaderExtensionUri, Id: 20},
+		}
+		consumer := NewSimpleConsumer("c1", listener, options)
+
+		packet := createTestRtpPacket(t, 9001, 1000, 100, map[uint8][]byte{
+			4: []byte("0"),
+			5: {0x80 | 30},
+			6: []byte("h"),
+		})
+		packet.SetHeaderExtensionIds(RtpHeaderExtensionIds{Mid: 4, SsrcAudioLevel: 5, Rid: 6})
+		size := packet.Size
+
+		listener.onSendRtpPacket = func(packet *RtpPacket) {
+			// The audio level id requires the two-byte format.
+			require.EqualValues(t, twoByteHeaderExtensionProfile, packet.ExtensionProfile)
+			require.ElementsMatch(t, []uint8{1, 2, 20}, packet.GetExtensionIDs())
+
+			mid, ok := packet.ReadMid()
+			require.True(t, ok)
+			require.Equal(t, "1", mid)
+
+			// Added to be stamped by the transport.
+			absSendTime, ok := packet.ReadAbsSendTime()
+			require.True(t, ok)
+			require.Zero(t, absSendTime)
+
+			volume, voice, ok := packet.ReadSsrcAudioLevel()
+			require.True(t, ok)
+			require.EqualValues(t, 30, volume)
+			require.True(t, voice)
+
+			data, err := packet.Marshal()
+			require.NoError(t, err)
+			require.EqualValues(t, len(data), packet.Size)
+		}
+		consumer.SendRtpPacket(packet)
+		require.Len(t, listener.sentPackets, 1)
+
+		// Original extensions are restored once sent.
+		require.EqualValues(t, size, packet.Size)
+		require.ElementsMatch(t, []uint8{4, 5, 6}, packet.GetExtensionIDs())
+		mid, ok := packet.ReadMid()
+		require.True(t, ok)
+		require.Equal(t, "0", mid)
+	})
+
+	t.Run("drops opus DTX packets only when ignoring DTX", func(t *testing.T) {
+		send := func(consumer *SimpleConsumer, seq uint16, payload []byte) {
+			packet := createTestRtpPacket(t, 9001, seq, 100, nil)
+			packet.Payload = payload
+			packet.SetPayloadDescriptorHandler(codecs.GetPayloadDescriptorHandler("audio/opus", packet))
+			consumer.SendRtpPacket(packet)
+		}
+
+		for _, ignoreDtx := range []bool{false, true} {
+			listener := &TestConsumerListener{}
+			options := createTestSimpleConsumerOptions(MediaKindAudio)
+			options.IgnoreDtx = ignoreDtx
+			consumer := NewSimpleConsumer("c1", listener, options)
+
+			send(consumer, 1000, []byte{0x78, 0x01, 0x02})
+			send(consumer, 1001, []byte{0x78})
+			send(consumer, 1002, []byte{0x78, 0x00})
+			send(consumer, 1003, []byte{0x78, 0x01, 0x02})
+
+			if ignoreDtx {
+				require.Equal(t, []uint16{1, 2}, listener.sentSeqs())
+			} else {
+				require.Equal(t, []uint16{1, 2, 3, 4}, listener.sentSeqs())
+			}
+		}
+	})
+
+	t.Run("restores the payload of a packet whose sequence number was dropped", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimpleConsumer("c1", listener, createTestSimpleConsumerOptions(MediaKindAudio))
+
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 1000, 100, nil))
+
+		// Padding only packet, its sequence number is dropped.
+		packet := createTestRtpPacket(t, 9001, 1001, 100, nil)
+		packet.Payload = nil
+		consumer.SendRtpPacket(packet)
+
+		handler := NewTestPayloadDescriptorHandler(false)
+		packet = createTestRtpPacket(t, 9001, 1001, 100, nil)
+		packet.SetPayloadDescriptorHandler(handler)
+		consumer.SendRtpPacket(packet)
+
+		require.Equal(t, []uint16{1}, listener.sentSeqs())
+		require.Equal(t, 1, handler.restored)
+	})
+
+	t.Run("paused consumer does not forward and re-syncs on resume", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimpleConsumer("c1", listener, createTestSimpleConsumerOptions(MediaKindVideo))
+
+		packet := createTestRtpPacket(t, 9001, 10, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 11, 100, nil))
+
+		consumer.Pause()
+		require.False(t, consumer.IsActive())
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 12, 100, nil))
+
+		consumer.Resume()
+		require.Equal(t, []uint32{9001}, listener.keyFrameRequests)
+
+		packet = createTestRtpPacket(t, 9001, 500, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 501, 100, nil))
+
+		consumer.ProducerPaused()
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 502, 100, nil))
+		consumer.ProducerResumed()
+
+		packet = createTestRtpPacket(t, 9001, 503, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+
+		require.Equal(t, []uint16{1, 2, 3, 4, 5}, listener.sentSeqs())
+		require.Equal(t, []uint32{9001, 9001}, listener.keyFrameRequests)
+	})
+
+	t.Run("waits for a key frame when sync is required", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimpleConsumer("c1", listener, createTestSimpleConsumerOptions(MediaKindVideo))
+
+		packet := createTestRtpPacket(t, 9001, 20, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(false))
+		consumer.SendRtpPacket(packet)
+		require.Empty(t, listener.sentPackets)
+
+		packet = createTestRtpPacket(t, 9001, 21, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(true))
+		consumer.SendRtpPacket(packet)
+
+		packet = createTestRtpPacket(t, 9001, 22, 100, nil)
+		packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(false))
+		consumer.SendRtpPacket(packet)
+
+		require.Equal(t, []uint16{1, 2}, listener.sentSeqs())
+	})
+
+	t.Run("timestamps keep going forward when the producer stream changes", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimpleConsumer("c1", listener, createTestSimpleConsumerOptions(MediaKindAudio))
+
+		params := RtpStreamParams{Ssrc: 9001, ClockRate: 90000, MimeType: "audio/opus"}
+		consumer.ProducerRtpStream(NewRtpStreamRecv(nil, params, 0), 9001)
+
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 100, 100, nil))
+		consumer.ProducerNewRtpStream(NewRtpStreamRecv(nil, params, 0), 9001)
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 5, 100, nil))
+
+		require.Equal(t, []uint16{1, 2}, listener.sentSeqs())
+		require.Greater(t, listener.sentPackets[1].timestamp, listener.sentPackets[0].timestamp)
+	})
+
+	t.Run("key frames are requested through the producer", func(t *testing.T) {
+		producerListener := NewTestProducerListener()
+		producer := NewProducer("p1", producerListener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+		producer.keyFrameRequestManager.KeyFrameReceived(1111)
+
+		listener := &TestConsumerListener{onKeyFrameRequested: producer.RequestKeyFrame}
+		consumer := NewSimpleConsumer("c1", listener, createTestSimpleConsumerOptions(MediaKindVideo))
+		consumer.ProducerRtpStream(producer.GetRtpStreams()[0], 9001)
+
+		producerListener.Lock()
+		defer producerListener.Unlock()
+
+		require.Equal(t, []uint32{9001}, listener.keyFrameRequests)
+		require.Equal(t, 2, producerListener.keyFrameRequired[1111])
+	})
+	t.Run("answers NACKs over RTX with the rewritten sequence numbers", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		options := createTestSimpleConsumerOptions(MediaKindAudio)
+		options.RtpParameters.Codecs[0].RtcpFeedback = []RtcpFeedback{{Type: "nack"}}
+		options.RtpParameters.Codecs = append(options.RtpParameters.Codecs, &RtpCodecParameters{
+			MimeType:    "audio/rtx",
+			PayloadType: 101,
+			ClockRate:   90000,
+			Parameters:  RtpCodecSpecificParameters{Apt: 100},
+		})
+		options.RtpParameters.Encodings[0].Rtx = &RtpEncodingRtx{Ssrc: 5556}
+		consumer := NewSimpleConsumer("c1", listener, options)
+
+		for _, seq := range []uint16{1000, 1001, 1002} {
+			consumer.SendRtpPacket(createTestRtpPacket(t, 9001, seq, 100, nil))
+		}
+
+		consumer.ReceiveNack(&rtcp.Nack{MediaSsrc: 5555, Items: rtcp.NewNackItems([]uint16{2, 3})})
+
+		require.Len(t, listener.resentPackets, 2)
+		for _, packet := range listener.resentPackets {
+			require.EqualValues(t, 5556, packet.ssrc)
+		}
+		require.Equal(t, listener.resentPackets[0].seq+1, listener.resentPackets[1].seq)
+
+		stats := consumer.GetStats()
+		require.EqualValues(t, 1, stats[0].NackCount)
+		require.EqualValues(t, 2, stats[0].NackPacketCount)
+	})
+	t.Run("RTCP carries a sender report and the CNAME", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		options := createTestSimpleConsumerOptions(MediaKindAudio)
+		options.RtpParameters.Rtcp.Cname = "cname"
+		consumer := NewSimpleConsumer("c1", listener, options)
+
+		require.Empty(t, consumer.GetRtcp(uint64(time.Now().UnixMilli())))
+
+		consumer.SendRtpPacket(createTestRtpPacket(t, 9001, 1000, 100, nil))
+
+		packets := consumer.GetRtcp(uint64(time.Now().UnixMilli()))
+		require.Len(t, packets, 2)
+		require.EqualValues(t, 5555, packets[0].(*rtcp.SenderReport).Ssrc)
+		require.Equal(t, []rtcp.SdesChunk{{
+			Ssrc:  5555,
+			Items: []rtcp.SdesItem{{Type: rtcp.SdesItemCname, Text: "cname"}},
+		}}, packets[1].(*rtcp.SourceDescription).Chunks)
+	})
+	t.Run("scores changes are emitted", func(t *testing.T) {
+		producerListener := NewTestProducerListener()
+		producer := NewProducer("p1", producerListener, createTestVideoProducerOptions())
+		defer producer.Close()
+
+		producer.ReceiveRtpPacket(createTestRtpPacket(t, 1111, 1, 101, nil))
+
+		listener := &TestConsumerListener{}
+		consumer := NewSimpleConsumer("c1", listener, createTestSimpleConsumerOptions(MediaKindVideo))
+		require.Equal(t, ConsumerScore{ProducerScores: []uint8{0}}, consumer.GetScore())
+
+		producerRtpStream := producer.GetRtpStreams()[0]
+		consumer.ProducerRtpStream(producerRtpStream, 9001)
+		consumer.ProducerRtpStreamScore(producerRtpStream, 10, 0)
+
+		require.Equal(t, []ConsumerScore{{ProducerScore: 10, ProducerScores: []uint8{10}}}, listener.scores)
+	})
+}
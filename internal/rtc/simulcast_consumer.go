@@ -0,0 +1,846 @@
+package rtc
+
+import (
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+const (
+	// StreamMinActiveMs is the minimum time a producer stream must be active
+	// before switching to it while another one is being sent.
+	StreamMinActiveMs = 2000
+	// BweDowngradeConservativeMs is the time during which higher spatial layers
+	// are not considered after a downgrade due to bandwidth limitations.
+	BweDowngradeConservativeMs = 10000
+	// BweDowngradeMinActiveMs is the minimum time the consumer must have been
+	// sending before a layer downgrade is considered due to the bandwidth.
+	BweDowngradeMinActiveMs = 8000
+	// MaxExtraOffsetMs is the maximum delay allowed for the stream when
+	// switching layers.
+	MaxExtraOffsetMs = 75
+)
+
+// SimulcastConsumer forwards one of the encodings of a simulcast Producer,
+// switching between them according to the preferred layers and the
+// available bitrate.
+type SimulcastConsumer struct {
+	*consumer
+	rtpStream                      *RtpStreamSend
+	producerRtpStreams             []*RtpStreamRecv
+	mapMappedSsrcSpatialLayer      map[uint32]int16
+	rtpSeqManager                  *SeqManager[uint16]
+	encodingContext                *codecs.EncodingContext
+	priority                       uint8
+	externallyManagedBitrate       bool
+	preferredSpatialLayer          int16
+	preferredTemporalLayer         int16
+	provisionalTargetSpatialLayer  int16
+	provisionalTargetTemporalLayer int16
+	targetSpatialLayer             int16
+	targetTemporalLayer            int16
+	currentSpatialLayer            int16
+	syncRequired                   bool
+	spatialLayerToSync             int16
+	keyFrameForTsOffsetRequested   bool
+	lastBweDowngradeAtMs           uint64
+	// tsSpatialLayer is the spatial layer whose timestamps tsOffset maps to
+	// the sent ones, -1 if none was sent yet. tsExtraOffset is the part of
+	// tsOffset added to keep the sent timestamps increasing on a switch.
+	tsSpatialLayer int16
+	tsOffset       uint32
+	tsExtraOffset  uint32
+	lastSentTs     uint32
+	lastSentAtMs   uint64
+}
+
+func NewSimulcastConsumer(id string, listener ConsumerListener, options *ConsumerOptions) *SimulcastConsumer {
+	c := &SimulcastConsumer{
+		consumer:                       newConsumer(ConsumerTypeSimulcast, id, listener, options),
+		mapMappedSsrcSpatialLayer:      make(map[uint32]int16),
+		rtpSeqManager:                  NewSeqManager[uint16](),
+		priority:                       1,
+		provisionalTargetSpatialLayer:  -1,
+		provisionalTargetTemporalLayer: -1,
+		targetSpatialLayer:             -1,
+		targetTemporalLayer:            -1,
+		currentSpatialLayer:            -1,
+		syncRequired:                   true,
+		spatialLayerToSync:             -1,
+		tsSpatialLayer:                 -1,
+	}
+
+	// Ensure there are as many spatial layers as encodings.
+	spatialLayers := len(c.consumableRtpEncodings)
+	temporalLayers := ParseScalabilityMode(c.consumableRtpEncodings[0].ScalabilityMode).TemporalLayers
+
+	for idx, encoding := range c.consumableRtpEncodings {
+		c.mapMappedSsrcSpatialLayer[encoding.Ssrc] = int16(idx)
+	}
+	c.producerRtpStreams = make([]*RtpStreamRecv, spatialLayers)
+
+	// Set preferred highest spatial and temporal layers by default.
+	c.preferredSpatialLayer = int16(spatialLayers - 1)
+	c.preferredTemporalLayer = int16(temporalLayers - 1)
+
+	if options.PreferredLayers != nil {
+		c.preferredSpatialLayer = min(int16(options.PreferredLayers.SpatialLayer), int16(spatialLayers-1))
+		c.preferredTemporalLayer = min(int16(options.PreferredLayers.TemporalLayer), int16(temporalLayers-1))
+	}
+
+	params := c.createRtpStreamParams(0)
+	params.SpatialLayers = uint8(spatialLayers)
+	params.TemporalLayers = temporalLayers
+	c.rtpStream = NewRtpStreamSend(c, params)
+
+	if c.IsPaused() || c.IsProducerPaused() {
+		c.rtpStream.Pause()
+	}
+
+	c.encodingContext = newEncodingContext(codecs.EncodingContextParams{
+		SpatialLayers:  uint8(spatialLayers),
+		TemporalLayers: temporalLayers,
+	})
+
+	return c
+}
+
+func (c *SimulcastConsumer) Pause() {
+	if !c.pause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *SimulcastConsumer) Resume() {
+	if !c.resume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *SimulcastConsumer) ProducerPaused() {
+	if !c.producerPause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *SimulcastConsumer) ProducerResumed() {
+	if !c.producerResume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *SimulcastConsumer) ProducerRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	spatialLayer, ok := c.mapMappedSsrcSpatialLayer[mappedSsrc]
+	if !ok {
+		c.logger.Warn("unknown mapped ssrc", "mappedSsrc", mappedSsrc)
+		return
+	}
+
+	c.producerRtpStreams[spatialLayer] = rtpStream
+}
+
+func (c *SimulcastConsumer) ProducerNewRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	c.ProducerRtpStream(rtpStream, mappedSsrc)
+
+	if c.IsActive() {
+		c.MayChangeLayers(false)
+	}
+}
+
+// ProducerRtpStreamScore must be called when the score of a Producer stream
+// changes.
+func (c *SimulcastConsumer) ProducerRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8) {
+	if c.IsActive() {
+		// Just check target layers if the stream has died or reborned.
+		if !c.externallyManagedBitrate || score == 0 || previousScore == 0 {
+			c.MayChangeLayers(false)
+		}
+	}
+
+	c.emitScore()
+}
+
+func (c *SimulcastConsumer) SetExternallyManagedBitrate() {
+	c.externallyManagedBitrate = true
+}
+
+func (c *SimulcastConsumer) SetPriority(priority uint8) {
+	c.priority = max(priority, 1)
+}
+
+func (c *SimulcastConsumer) GetPreferredLayers() ConsumerLayers {
+	return ConsumerLayers{
+		SpatialLayer:  uint8(c.preferredSpatialLayer),
+		TemporalLayer: uint8(c.preferredTemporalLayer),
+	}
+}
+
+func (c *SimulcastConsumer) SetPreferredLayers(layers ConsumerLayers) {
+	c.preferredSpatialLayer = min(int16(layers.SpatialLayer), int16(len(c.producerRtpStreams)-1))
+	c.preferredTemporalLayer = min(int16(layers.TemporalLayer), int16(c.rtpStream.GetTemporalLayers()-1))
+
+	c.logger.Debug("preferred layers changed",
+		"spatialLayer", c.preferredSpatialLayer, "temporalLayer", c.preferredTemporalLayer)
+
+	if c.IsActive() {
+		c.MayChangeLayers(true)
+	}
+}
+
+// GetCurrentLayers returns the layers being sent, or nil if none.
+func (c *SimulcastConsumer) GetCurrentLayers() *ConsumerLayers {
+	if c.currentSpatialLayer == -1 || c.encodingContext.GetCurrentTemporalLayer() == -1 {
+		return nil
+	}
+	return &ConsumerLayers{
+		SpatialLayer:  uint8(c.currentSpatialLayer),
+		TemporalLayer: uint8(c.encodingContext.GetCurrentTemporalLayer()),
+	}
+}
+
+// GetTargetLayers returns the layers the consumer is switching to, or nil if
+// none.
+func (c *SimulcastConsumer) GetTargetLayers() *ConsumerLayers {
+	if c.targetSpatialLayer == -1 {
+		return nil
+	}
+	return &ConsumerLayers{
+		SpatialLayer:  uint8(c.targetSpatialLayer),
+		TemporalLayer: uint8(c.targetTemporalLayer),
+	}
+}
+
+// GetBitratePriority returns 0 if the consumer does not need any bitrate.
+func (c *SimulcastConsumer) GetBitratePriority() uint8 {
+	if !c.IsActive() {
+		return 0
+	}
+	return c.priority
+}
+
+// IncreaseLayer selects the next provisional layer whose bitrate fits in the
+// given one, and returns the bitrate it requires. It returns 0 if no layer
+// could be increased.
+func (c *SimulcastConsumer) IncreaseLayer(bitrate uint32) uint32 {
+	// If already in the preferred layers, do nothing.
+	if c.provisionalTargetSpatialLayer == c.preferredSpatialLayer &&
+		c.provisionalTargetTemporalLayer == c.preferredTemporalLayer {
+		return 0
+	}
+
+	var (
+		requiredBitrate uint32
+		spatialLayer    int16
+		temporalLayer   int16
+		found           bool
+	)
+
+	nowMs := uint64(time.Now().UnixMilli())
+
+	for sIdx, producerRtpStream := range c.producerRtpStreams {
+		spatialLayer = int16(sIdx)
+
+		// If this is higher than current spatial layer and we moved to current
+		// spatial layer due to BWE limitations, check how much it has elapsed
+		// since then.
+		if nowMs-c.lastBweDowngradeAtMs < BweDowngradeConservativeMs {
+			if c.provisionalTargetSpatialLayer > -1 && spatialLayer > c.currentSpatialLayer {
+				continue
+			}
+		}
+
+		// Ignore spatial layers lower than the one we already have.
+		if spatialLayer < c.provisionalTargetSpatialLayer {
+			continue
+		}
+
+		// Ignore spatial layers for non existing Producer streams or for those
+		// with score 0.
+		if producerRtpStream == nil || producerRtpStream.GetScore() == 0 {
+			continue
+		}
+
+		// If the stream has not been active time enough and we have an active
+		// one already, move to the next spatial layer.
+		if spatialLayer != c.provisionalTargetSpatialLayer &&
+			c.provisionalTargetSpatialLayer != -1 &&
+			producerRtpStream.GetActiveMs() < StreamMinActiveMs {
+			continue
+		}
+
+		// Check bitrate of every temporal layer.
+		for temporalLayer = 0; temporalLayer < int16(producerRtpStream.GetTemporalLayers()); temporalLayer++ {
+			// Ignore temporal layers lower than the one we already have (taking
+			// into account the spatial layer too).
+			if spatialLayer == c.provisionalTargetSpatialLayer &&
+				temporalLayer <= c.provisionalTargetTemporalLayer {
+				continue
+			}
+
+			requiredBitrate = producerRtpStream.GetLayerBitrate(nowMs, 0, uint8(temporalLayer))
+
+			// This is simulcast so we must substract the bitrate of the current
+			// temporal spatial layer if this is the temporal layer 0 of a higher
+			// spatial layer.
+			if requiredBitrate > 0 && temporalLayer == 0 &&
+				c.provisionalTargetSpatialLayer > -1 &&
+				spatialLayer > c.provisionalTargetSpatialLayer {
+				provisionalProducerRtpStream := c.producerRtpStreams[c.provisionalTargetSpatialLayer]
+				provisionalRequiredBitrate := provisionalProducerRtpStream.GetBitrateForLayers(
+					nowMs, 0, uint8(c.provisionalTargetTemporalLayer))
+
+				if requiredBitrate > provisionalRequiredBitrate {
+					requiredBitrate -= provisionalRequiredBitrate
+				} else {
+					// Don't set 0 since it would be ignored.
+					requiredBitrate = 1
+				}
+			}
+
+			// If active layer, end iterations here. Otherwise move to next
+			// spatial layer.
+			break
+		}
+
+		if requiredBitrate > 0 {
+			found = true
+			break
+		}
+
+		// If this is the preferred or higher spatial layer, take it and exit.
+		if spatialLayer >= c.preferredSpatialLayer {
+			break
+		}
+	}
+
+	// No higher active layers found, or no luck.
+	if !found || requiredBitrate > bitrate {
+		return 0
+	}
+
+	// Set provisional layers.
+	c.provisionalTargetSpatialLayer = spatialLayer
+	c.provisionalTargetTemporalLayer = temporalLayer
+
+	return requiredBitrate
+}
+
+// ApplyLayers sets the provisional layers chosen by IncreaseLayer as target
+// layers.
+func (c *SimulcastConsumer) ApplyLayers() {
+	provisionalTargetSpatialLayer := c.provisionalTargetSpatialLayer
+	provisionalTargetTemporalLayer := c.provisionalTargetTemporalLayer
+
+	// Reset provisional target layers.
+	c.provisionalTargetSpatialLayer = -1
+	c.provisionalTargetTemporalLayer = -1
+
+	if !c.IsActive() {
+		return
+	}
+
+	if provisionalTargetSpatialLayer != c.targetSpatialLayer ||
+		provisionalTargetTemporalLayer != c.targetTemporalLayer {
+		c.updateTargetLayers(provisionalTargetSpatialLayer, provisionalTargetTemporalLayer)
+
+		// If this looks like a spatial layer downgrade due to BWE limitations,
+		// set member.
+		if c.rtpStream.GetActiveMs() > BweDowngradeMinActiveMs &&
+			c.targetSpatialLayer < c.currentSpatialLayer &&
+			c.currentSpatialLayer <= c.preferredSpatialLayer {
+			c.logger.Debug("possible target spatial layer downgrade due to BWE limitation")
+
+			c.lastBweDowngradeAtMs = uint64(time.Now().UnixMilli())
+		}
+	}
+}
+
+// GetDesiredBitrate returns the bitrate of the highest active Producer
+// stream.
+func (c *SimulcastConsumer) GetDesiredBitrate() uint32 {
+	if !c.IsActive() {
+		return 0
+	}
+
+	nowMs := uint64(time.Now().UnixMilli())
+	var desiredBitrate uint32
+
+	// Let's iterate all streams of the Producer (from highest to lowest) and
+	// obtain their bitrate. Use the first one with bitrate.
+	for sIdx := len(c.producerRtpStreams) - 1; sIdx >= 0; sIdx-- {
+		producerRtpStream := c.producerRtpStreams[sIdx]
+		if producerRtpStream == nil {
+			continue
+		}
+		desiredBitrate = producerRtpStream.GetBitrate(nowMs)
+		if desiredBitrate > 0 {
+			break
+		}
+	}
+
+	// If the consumer max bitrate was given and it's greater than computed one,
+	// then use it.
+	if maxBitrate := c.rtpParameters.Encodings[0].MaxBitrate; maxBitrate > desiredBitrate {
+		desiredBitrate = maxBitrate
+	}
+
+	return desiredBitrate
+}
+
+// GetRtxPaddingPacket returns a RTX padding packet, with the header extensions
+// of the consumer, or nil if the consumer cannot send any.
+func (c *SimulcastConsumer) GetRtxPaddingPacket(paddingSize uint8) *RtpPacket {
+	if !c.IsActive() {
+		return nil
+	}
+
+	packet := c.rtpStream.GetRtxPaddingPacket(paddingSize)
+	if packet == nil {
+		return nil
+	}
+
+	// Add the zeroed congestion control extensions, stamped when sent.
+	packet.MapHeaderExtensions(c.headerExtensionIds, c.rtpParameters.Mid)
+
+	return packet
+}
+
+func (c *SimulcastConsumer) SendRtpPacket(packet *RtpPacket) {
+	if !c.IsActive() {
+		return
+	}
+
+	if c.targetTemporalLayer == -1 {
+		return
+	}
+
+	// NOTE: This may happen if this Consumer supports just some codecs of those
+	// in the corresponding Producer.
+	if !c.isSupportedPayloadType(packet.PayloadType) {
+		return
+	}
+
+	spatialLayer, ok := c.mapMappedSsrcSpatialLayer[packet.SSRC]
+	if !ok {
+		return
+	}
+
+	shouldSwitchCurrentSpatialLayer := false
+
+	// Check whether this is the packet we are waiting for in order to update
+	// the current spatial layer.
+	if c.currentSpatialLayer != c.targetSpatialLayer && spatialLayer == c.targetSpatialLayer {
+		// Ignore if not a key frame.
+		if !packet.IsKeyFrame() {
+			return
+		}
+
+		// Timestamps of another stream can only be mapped once the sender
+		// reports of both streams are received.
+		if c.tsSpatialLayer != -1 && spatialLayer != c.tsSpatialLayer &&
+			(c.producerRtpStreams[c.tsSpatialLayer].GetSenderReportNtpMs() == 0 ||
+				c.producerRtpStreams[spatialLayer].GetSenderReportNtpMs() == 0) {
+			return
+		}
+
+		shouldSwitchCurrentSpatialLayer = true
+
+		// Need to resync the stream.
+		c.syncRequired = true
+		c.spatialLayerToSync = spatialLayer
+	} else if spatialLayer != c.currentSpatialLayer {
+		// If the packet belongs to different spatial layer than the one being
+		// sent, drop it.
+		return
+	}
+
+	// If we need to sync and this is not a key frame, ignore the packet.
+	if c.syncRequired && !packet.IsKeyFrame() {
+		return
+	}
+
+	// Packets with only padding are not forwarded.
+	if len(packet.Payload) == 0 {
+		c.rtpSeqManager.Drop(packet.SequenceNumber)
+		return
+	}
+
+	nowMs := uint64(time.Now().UnixMilli())
+
+	// Whether this is the first packet after re-sync.
+	isSyncPacket := c.syncRequired
+
+	// Sync sequence number and timestamp if required.
+	if isSyncPacket && (c.spatialLayerToSync == -1 || c.spatialLayerToSync == spatialLayer) {
+		if packet.IsKeyFrame() {
+			c.logger.Debug("sync key frame received")
+		}
+
+		// Keep mapping the timestamps of the previous stream without the extra
+		// offset it needed.
+		tsOffset := c.tsOffset + c.tsExtraOffset
+		var tsExtraOffset uint32
+
+		// Map the timestamps of the new stream to the ones of the previous
+		// stream through the NTP times of their sender reports, so the sent
+		// timestamps stay in sync with the capture time.
+		if c.tsSpatialLayer != -1 && spatialLayer != c.tsSpatialLayer {
+			previousRtpStream := c.producerRtpStreams[c.tsSpatialLayer]
+			producerRtpStream := c.producerRtpStreams[spatialLayer]
+
+			ntpMs1 := previousRtpStream.GetSenderReportNtpMs()
+			ts1 := previousRtpStream.GetSenderReportTs()
+			ntpMs2 := producerRtpStream.GetSenderReportNtpMs()
+			ts2 := producerRtpStream.GetSenderReportTs()
+
+			diffMs := int64(ntpMs2) - int64(ntpMs1)
+			diffTs := diffMs * int64(c.rtpStream.GetClockRate()) / 1000
+
+			tsOffset += ts2 - uint32(diffTs) - ts1
+		}
+
+		// Apply an extra offset if new ts is lower/equal than the highest one
+		// sent.
+		if shouldSwitchCurrentSpatialLayer && c.lastSentAtMs != 0 &&
+			!IsSeqHigherThan(packet.Timestamp-tsOffset, c.lastSentTs) {
+			maxTsExtraOffset := uint32(MaxExtraOffsetMs * uint64(c.rtpStream.GetClockRate()) / 1000)
+			tsExtraOffset = c.lastSentTs - (packet.Timestamp - tsOffset) + 1
+
+			if c.keyFrameForTsOffsetRequested {
+				// Give up and use the theoretical offset.
+				if tsExtraOffset > maxTsExtraOffset {
+					c.logger.Warn("giving up on proper stream switching after got a requested keyframe for which still too high RTP timestamp extra offset is needed",
+						"tsExtraOffset", tsExtraOffset)
+					tsExtraOffset = 1
+				}
+			} else if tsExtraOffset > maxTsExtraOffset {
+				c.logger.Warn("cannot switch stream due to too high RTP timestamp extra offset needed, requesting keyframe",
+					"tsExtraOffset", tsExtraOffset)
+
+				c.requestKeyFrameForTargetSpatialLayer()
+				c.keyFrameForTsOffsetRequested = true
+
+				// Reset flags since we are discarding this key frame.
+				c.syncRequired = false
+				c.spatialLayerToSync = -1
+
+				return
+			}
+
+			tsOffset -= tsExtraOffset
+		}
+
+		c.tsSpatialLayer = spatialLayer
+		c.tsExtraOffset = tsExtraOffset
+		c.tsOffset = tsOffset
+
+		// Sync our RTP stream's sequence number.
+		c.rtpSeqManager.Sync(packet.SequenceNumber - 1)
+		c.encodingContext.SyncRequired()
+
+		c.syncRequired = false
+		c.spatialLayerToSync = -1
+		c.keyFrameForTsOffsetRequested = false
+	}
+
+	// Update current spatial layer if needed.
+	if shouldSwitchCurrentSpatialLayer {
+		c.currentSpatialLayer = c.targetSpatialLayer
+
+		// Update target and current temporal layer.
+		c.encodingContext.SetTargetTemporalLayer(c.targetTemporalLayer)
+		c.encodingContext.SetCurrentTemporalLayer(int16(packet.GetTemporalLayer()))
+
+		c.logger.Debug("current layers changed",
+			"spatialLayer", c.currentSpatialLayer, "temporalLayer", c.encodingContext.GetCurrentTemporalLayer())
+	}
+
+	// Rewrite payload if needed. Drop packet if necessary.
+	marker, ok := packet.ProcessPayload(c.encodingContext, packet.Payload)
+	if !ok {
+		c.rtpSeqManager.Drop(packet.SequenceNumber)
+		return
+	}
+
+	// Update RTP seq number and timestamp based on the offset.
+	seq, ok := c.rtpSeqManager.Input(packet.SequenceNumber)
+	if !ok {
+		packet.RestorePayload()
+		return
+	}
+	timestamp := packet.Timestamp - c.tsOffset
+
+	// Save original packet fields.
+	origSsrc := packet.SSRC
+	origSeq := packet.SequenceNumber
+	origTimestamp := packet.Timestamp
+	origMarker := packet.Marker
+
+	// Rewrite packet.
+	packet.SSRC = c.rtpParameters.Encodings[0].Ssrc
+	packet.SequenceNumber = seq
+	origHeaderExtensions := packet.MapHeaderExtensions(c.headerExtensionIds, c.rtpParameters.Mid)
+	packet.Timestamp = timestamp
+	if marker {
+		packet.Marker = true
+	}
+
+	if isSyncPacket {
+		c.logger.Debug("sending sync packet", "ssrc", packet.SSRC, "seq", seq,
+			"ts", timestamp, "origSeq", origSeq, "origTs", origTimestamp)
+	}
+
+	// Process the packet.
+	if c.rtpStream.ReceivePacket(packet) {
+		if c.lastSentAtMs == 0 || IsSeqHigherThan(timestamp, c.lastSentTs) {
+			c.lastSentTs = timestamp
+			c.lastSentAtMs = nowMs
+		}
+
+		// Send the packet.
+		c.listener.OnConsumerSendRtpPacket(c, packet)
+	} else {
+		c.logger.Warn("failed to send packet", "ssrc", packet.SSRC, "seq", seq, "origSeq", origSeq)
+	}
+
+	// Restore packet fields.
+	packet.SSRC = origSsrc
+	packet.SequenceNumber = origSeq
+	packet.RestoreHeaderExtensions(origHeaderExtensions)
+	packet.Timestamp = origTimestamp
+	packet.Marker = origMarker
+
+	// Restore the original payload if needed.
+	packet.RestorePayload()
+}
+
+func (c *SimulcastConsumer) ReceiveNack(nackPacket *rtcp.Nack) {
+	if !c.IsActive() {
+		return
+	}
+
+	c.rtpStream.ReceiveNack(nackPacket)
+}
+
+func (c *SimulcastConsumer) ReceiveRtcpReceiverReport(report *rtcp.ReceptionReport) {
+	c.rtpStream.ReceiveRtcpReceiverReport(report)
+}
+
+func (c *SimulcastConsumer) GetRtcp(nowMs uint64) []rtcp.Packet {
+	return getRtcpPackets(nowMs, c.rtpStream)
+}
+
+func (c *SimulcastConsumer) RequestKeyFrame() {
+	if c.kind != MediaKindVideo {
+		return
+	}
+
+	c.requestKeyFrameForTargetSpatialLayer()
+
+	if c.currentSpatialLayer != -1 && c.currentSpatialLayer != c.targetSpatialLayer {
+		c.requestKeyFrameForSpatialLayer(c.currentSpatialLayer)
+	}
+}
+
+func (c *SimulcastConsumer) GetScore() ConsumerScore {
+	score := ConsumerScore{
+		Score:          c.rtpStream.GetScore(),
+		ProducerScores: make([]uint8, len(c.producerRtpStreams)),
+	}
+
+	for idx, producerRtpStream := range c.producerRtpStreams {
+		if producerRtpStream != nil {
+			score.ProducerScores[idx] = producerRtpStream.GetScore()
+		}
+	}
+
+	if c.currentSpatialLayer >= 0 && int(c.currentSpatialLayer) < len(score.ProducerScores) {
+		score.ProducerScore = score.ProducerScores[c.currentSpatialLayer]
+	}
+
+	return score
+}
+
+func (c *SimulcastConsumer) GetStats() []RtpStreamStats {
+	stats := []RtpStreamStats{c.rtpStream.GetStats()}
+
+	if c.currentSpatialLayer != -1 {
+		if producerRtpStream := c.producerRtpStreams[c.currentSpatialLayer]; producerRtpStream != nil {
+			stats = append(stats, producerRtpStream.GetStats())
+		}
+	}
+
+	return stats
+}
+
+func (c *SimulcastConsumer) Close() {}
+
+// MayChangeLayers recalculates the target layers. If the bitrate is managed
+// externally, the listener is asked to redistribute it instead.
+func (c *SimulcastConsumer) MayChangeLayers(force bool) {
+	newTargetSpatialLayer, newTargetTemporalLayer, changed := c.recalculateTargetLayers()
+	if !changed {
+		return
+	}
+
+	// If bitrate externally managed, don't bother the transport unless the
+	// newTargetSpatialLayer has changed (or force is true). This is because,
+	// if bitrate is externally managed, the target temporal layer is managed by
+	// the available given bitrate so the transport will let us change it when
+	// it considers.
+	if c.externallyManagedBitrate {
+		if newTargetSpatialLayer != c.targetSpatialLayer || force {
+			c.listener.OnConsumerNeedBitrateChange(c)
+		}
+	} else {
+		c.updateTargetLayers(newTargetSpatialLayer, newTargetTemporalLayer)
+	}
+}
+
+func (c *SimulcastConsumer) recalculateTargetLayers() (newTargetSpatialLayer, newTargetTemporalLayer int16, changed bool) {
+	// Start with no layers.
+	newTargetSpatialLayer = -1
+	newTargetTemporalLayer = -1
+
+	nowMs := uint64(time.Now().UnixMilli())
+
+	for sIdx, producerRtpStream := range c.producerRtpStreams {
+		spatialLayer := int16(sIdx)
+
+		// If this is higher than current spatial layer and we moved to current
+		// spatial layer due to BWE limitations, check how much it has elapsed
+		// since then.
+		if nowMs-c.lastBweDowngradeAtMs < BweDowngradeConservativeMs {
+			if newTargetSpatialLayer > -1 && spatialLayer > c.currentSpatialLayer {
+				continue
+			}
+		}
+
+		// Ignore spatial layers for non existing Producer streams or for those
+		// with score 0.
+		if producerRtpStream == nil || producerRtpStream.GetScore() == 0 {
+			continue
+		}
+
+		// If the stream has not been active time enough and we have an active
+		// one already, move to the next spatial layer.
+		// NOTE: Require bitrate externally managed for this.
+		if c.externallyManagedBitrate && newTargetSpatialLayer != -1 &&
+			producerRtpStream.GetActiveMs() < StreamMinActiveMs {
+			continue
+		}
+
+		newTargetSpatialLayer = spatialLayer
+
+		// If this is the preferred or higher spatial layer take it and exit.
+		if spatialLayer >= c.preferredSpatialLayer {
+			break
+		}
+	}
+
+	if newTargetSpatialLayer != -1 {
+		if newTargetSpatialLayer == c.preferredSpatialLayer {
+			newTargetTemporalLayer = c.preferredTemporalLayer
+		} else if newTargetSpatialLayer < c.preferredSpatialLayer {
+			newTargetTemporalLayer = int16(c.rtpStream.GetTemporalLayers() - 1)
+		} else {
+			newTargetTemporalLayer = 0
+		}
+	}
+
+	// Return true if any target layer changed.
+	changed = newTargetSpatialLayer != c.targetSpatialLayer || newTargetTemporalLayer != c.targetTemporalLayer
+
+	return
+}
+
+func (c *SimulcastConsumer) updateTargetLayers(newTargetSpatialLayer, newTargetTemporalLayer int16) {
+	if newTargetSpatialLayer == -1 {
+		// Unset current and target layers.
+		c.targetSpatialLayer = -1
+		c.targetTemporalLayer = -1
+		c.currentSpatialLayer = -1
+
+		c.encodingContext.SetTargetTemporalLayer(-1)
+		c.encodingContext.SetCurrentTemporalLayer(-1)
+
+		c.logger.Debug("target layers changed", "spatialLayer", -1, "temporalLayer", -1)
+
+		return
+	}
+
+	c.targetSpatialLayer = newTargetSpatialLayer
+	c.targetTemporalLayer = newTargetTemporalLayer
+
+	// If the new target spatial layer matches the current one, apply the new
+	// target temporal layer now.
+	if c.targetSpatialLayer == c.currentSpatialLayer {
+		c.encodingContext.SetTargetTemporalLayer(c.targetTemporalLayer)
+	}
+
+	c.logger.Debug("target layers changed",
+		"spatialLayer", c.targetSpatialLayer, "temporalLayer", c.targetTemporalLayer)
+
+	// If the target spatial layer is different than the current one, request
+	// a key frame.
+	if c.targetSpatialLayer != c.currentSpatialLayer {
+		c.requestKeyFrameForTargetSpatialLayer()
+	}
+}
+
+func (c *SimulcastConsumer) requestKeyFrameForTargetSpatialLayer() {
+	if c.targetSpatialLayer == -1 {
+		return
+	}
+	c.requestKeyFrameForSpatialLayer(c.targetSpatialLayer)
+}
+
+func (c *SimulcastConsumer) requestKeyFrameForSpatialLayer(spatialLayer int16) {
+	if c.kind != MediaKindVideo || c.producerRtpStreams[spatialLayer] == nil {
+		return
+	}
+
+	mappedSsrc := c.consumableRtpEncodings[spatialLayer].Ssrc
+
+	c.listener.OnConsumerKeyFrameRequested(c, mappedSsrc)
+}
+
+func (c *SimulcastConsumer) userOnPaused() {
+	c.rtpStream.Pause()
+
+	c.updateTargetLayers(-1, -1)
+
+	// Tell the transport so it can distribute available bitrate into other
+	// consumers.
+	if c.externallyManagedBitrate {
+		c.listener.OnConsumerNeedZeroBitrate(c)
+	}
+}
+
+func (c *SimulcastConsumer) userOnResumed() {
+	c.syncRequired = true
+	c.spatialLayerToSync = -1
+	c.keyFrameForTsOffsetRequested = false
+	c.rtpStream.Resume()
+
+	if c.IsActive() {
+		c.MayChangeLayers(true)
+	}
+}
+
+func (c *SimulcastConsumer) OnRtpStreamRetransmitRtpPacket(rtpStream *RtpStreamSend, packet *RtpPacket) {
+	c.listener.OnConsumerRetransmitRtpPacket(c, packet)
+}
+
+func (c *SimulcastConsumer) OnRtpStreamScore(rtpStream *RtpStreamSend, score, previousScore uint8) {
+	c.emitScore()
+}
+
+func (c *SimulcastConsumer) emitScore() {
+	c.listener.OnConsumerScore(c, c.GetScore())
+}
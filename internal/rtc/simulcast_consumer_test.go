@@ -0,0 +1,230 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createTestSimulcastConsumerOptions() *ConsumerOptions {
+	return &ConsumerOptions{
+		ProducerId: "p1",
+		Kind:       MediaKindVideo,
+		RtpParameters: RtpParameters{
+			Codecs: []*RtpCodecParameters{
+				{MimeType: "video/VP8", PayloadType: 100, ClockRate: 90000},
+			},
+			Encodings: []RtpEncodingParameters{{Ssrc: 5555}},
+		},
+		ConsumableRtpEncodings: []RtpEncodingParameters{
+			{Ssrc: 9001, ScalabilityMode: "L1T3"},
+			{Ssrc: 9002, ScalabilityMode: "L1T3"},
+			{Ssrc: 9003, ScalabilityMode: "L1T3"},
+		},
+	}
+}
+
+func createTestSimulcastProducerRtpStreams(t *testing.T) []*RtpStreamRecv {
+	var streams []*RtpStreamRecv
+	for _, ssrc := range []uint32{9001, 9002, 9003} {
+		params := RtpStreamParams{Ssrc: ssrc, ClockRate: 90000, MimeType: "video/VP8", TemporalLayers: 3}
+		stream := NewRtpStreamRecv(nil, params, 0)
+		require.True(t, stream.ReceivePacket(createTestRtpPacket(t, ssrc, 1, 100, nil)))
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// receiveTestSenderReports gives the streams sender reports taken at the same
+// time, with the given RTP timestamps.
+func receiveTestSenderReports(streams []*RtpStreamRecv, rtpTimestamps ...uint32) {
+	for idx, stream := range streams {
+		stream.ReceiveSenderReport(1700000000000, rtpTimestamps[idx])
+	}
+}
+
+func createTestSimulcastPacket(t *testing.T, ssrc uint32, seq uint16, isKeyFrame bool) *RtpPacket {
+	packet := createTestRtpPacket(t, ssrc, seq, 100, nil)
+	packet.SetPayloadDescriptorHandler(NewTestPayloadDescriptorHandler(isKeyFrame))
+	return packet
+}
+
+func TestSimulcastConsumer(t *testing.T) {
+	t.Run("switches spatial layer on key frame of the target layer", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimulcastConsumer("c1", listener, createTestSimulcastConsumerOptions())
+
+		streams := createTestSimulcastProducerRtpStreams(t)
+		for idx, stream := range streams {
+			consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+		}
+		// Test packets have a timestamp of 3000 times their sequence number.
+		receiveTestSenderReports(streams, 597000, 0, 300000)
+
+		// Target layers move up as new streams appear.
+		require.Equal(t, []uint32{9001, 9002, 9003}, listener.keyFrameRequests)
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}, consumer.GetTargetLayers())
+		require.Nil(t, consumer.GetCurrentLayers())
+
+		// Not a key frame of the target layer.
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 100, false))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 200, true))
+		require.Empty(t, listener.sentPackets)
+
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 101, true))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 102, false))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 201, false))
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 2, TemporalLayer: 0}, consumer.GetCurrentLayers())
+
+		// Move to the lowest spatial layer.
+		consumer.SetPreferredLayers(ConsumerLayers{SpatialLayer: 0, TemporalLayer: 5})
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 2}, consumer.GetTargetLayers())
+		require.Equal(t, uint32(9001), listener.keyFrameRequests[len(listener.keyFrameRequests)-1])
+
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 202, false))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 103, false))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 203, true))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 104, true))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 204, false))
+
+		require.Equal(t, []uint16{1, 2, 3, 4, 5}, listener.sentSeqs())
+		for idx, packet := range listener.sentPackets {
+			require.EqualValues(t, 5555, packet.ssrc)
+			if idx > 0 {
+				require.True(t, IsSeqHigherThan(packet.timestamp, listener.sentPackets[idx-1].timestamp))
+			}
+		}
+		// Timestamps of the lowest stream are mapped to the ones of the
+		// highest stream through the sender reports.
+		require.EqualValues(t, 309000, listener.sentPackets[2].timestamp)
+		require.EqualValues(t, 312000, listener.sentPackets[3].timestamp)
+		require.EqualValues(t, 315000, listener.sentPackets[4].timestamp)
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, consumer.GetCurrentLayers())
+	})
+
+	t.Run("maps timestamps between streams through sender reports", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		options := createTestSimulcastConsumerOptions()
+		options.PreferredLayers = &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 2}
+		consumer := NewSimulcastConsumer("c1", listener, options)
+
+		streams := createTestSimulcastProducerRtpStreams(t)
+		for idx, stream := range streams {
+			consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+		}
+
+		// The first stream is sent as is.
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 10, true))
+		require.EqualValues(t, 30000, listener.sentPackets[0].timestamp)
+
+		// Not switching until the sender reports of both streams are received.
+		consumer.SetPreferredLayers(ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2})
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 20, true))
+		require.Len(t, listener.sentPackets, 1)
+
+		// The highest stream timestamp 60000 was taken along the lowest one
+		// 30000.
+		ntpMs := uint64(1700000000000)
+		for _, sr := range []struct {
+			stream *RtpStreamRecv
+			ntpMs  uint64
+			rtpTs  uint32
+		}{
+			{streams[0], ntpMs, 30000},
+			{streams[2], ntpMs + 100, 69000},
+		} {
+			sr.stream.ReceiveSenderReport(sr.ntpMs, sr.rtpTs)
+		}
+
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 21, true))
+		require.Len(t, listener.sentPackets, 2)
+		require.EqualValues(t, 33000, listener.sentPackets[1].timestamp)
+
+		// Back to the lowest stream, whose mapped timestamp is not higher than
+		// the last one sent.
+		consumer.SetPreferredLayers(ConsumerLayers{SpatialLayer: 0, TemporalLayer: 2})
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 11, true))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 12, false))
+		require.Len(t, listener.sentPackets, 4)
+		require.EqualValues(t, 33001, listener.sentPackets[2].timestamp)
+		require.EqualValues(t, 36001, listener.sentPackets[3].timestamp)
+	})
+
+	t.Run("restores the payload of a packet whose sequence number was dropped", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		options := createTestSimulcastConsumerOptions()
+		options.PreferredLayers = &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 2}
+		consumer := NewSimulcastConsumer("c1", listener, options)
+
+		for idx, stream := range createTestSimulcastProducerRtpStreams(t) {
+			consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+		}
+
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9001, 10, true))
+
+		// Padding only packet, its sequence number is dropped.
+		packet := createTestSimulcastPacket(t, 9001, 11, false)
+		packet.Payload = nil
+		consumer.SendRtpPacket(packet)
+
+		handler := NewTestPayloadDescriptorHandler(false)
+		packet = createTestRtpPacket(t, 9001, 11, 100, nil)
+		packet.SetPayloadDescriptorHandler(handler)
+		consumer.SendRtpPacket(packet)
+
+		require.Equal(t, []uint16{1}, listener.sentSeqs())
+		require.Equal(t, 1, handler.restored)
+	})
+
+	t.Run("paused consumer unsets layers and re-syncs on resume", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimulcastConsumer("c1", listener, createTestSimulcastConsumerOptions())
+
+		for idx, stream := range createTestSimulcastProducerRtpStreams(t) {
+			consumer.ProducerRtpStream(stream, 9001+uint32(idx))
+		}
+		consumer.MayChangeLayers(false)
+
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 10, true))
+		consumer.Pause()
+		require.Nil(t, consumer.GetTargetLayers())
+		require.EqualValues(t, 0, consumer.GetBitratePriority())
+
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 11, false))
+		consumer.Resume()
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 12, false))
+		consumer.SendRtpPacket(createTestSimulcastPacket(t, 9003, 13, true))
+
+		require.Equal(t, []uint16{1, 2}, listener.sentSeqs())
+	})
+
+	t.Run("externally managed bitrate increases layers step by step", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSimulcastConsumer("c1", listener, createTestSimulcastConsumerOptions())
+		consumer.SetExternallyManagedBitrate()
+
+		for idx, stream := range createTestSimulcastProducerRtpStreams(t) {
+			consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+		}
+
+		// Layers are not changed until the transport distributes the bitrate.
+		require.Equal(t, 3, listener.bitrateChanges)
+		require.Nil(t, consumer.GetTargetLayers())
+		require.NotZero(t, consumer.GetDesiredBitrate())
+
+		require.Zero(t, consumer.IncreaseLayer(1))
+
+		// Lowest layer of the lowest stream.
+		require.NotZero(t, consumer.IncreaseLayer(10_000_000))
+
+		// Other streams have not been active long enough.
+		require.Zero(t, consumer.IncreaseLayer(10_000_000))
+
+		consumer.ApplyLayers()
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, consumer.GetTargetLayers())
+		require.Equal(t, []uint32{9001}, listener.keyFrameRequests)
+
+		consumer.Pause()
+		require.Equal(t, 1, listener.zeroBitrates)
+	})
+}
@@ -0,0 +1,610 @@
+package rtc
+
+import (
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+// SvcConsumer forwards a single SVC Producer stream, dropping the packets that
+// belong to layers above the target ones.
+type SvcConsumer struct {
+	*consumer
+	rtpStream                      *RtpStreamSend
+	producerRtpStream              *RtpStreamRecv
+	rtpSeqManager                  *SeqManager[uint16]
+	encodingContext                *codecs.EncodingContext
+	priority                       uint8
+	externallyManagedBitrate       bool
+	preferredSpatialLayer          int16
+	preferredTemporalLayer         int16
+	provisionalTargetSpatialLayer  int16
+	provisionalTargetTemporalLayer int16
+	syncRequired                   bool
+	lastBweDowngradeAtMs           uint64
+}
+
+func NewSvcConsumer(id string, listener ConsumerListener, options *ConsumerOptions) *SvcConsumer {
+	c := &SvcConsumer{
+		consumer:                       newConsumer(ConsumerTypeSvc, id, listener, options),
+		rtpSeqManager:                  NewSeqManager[uint16](),
+		priority:                       1,
+		provisionalTargetSpatialLayer:  -1,
+		provisionalTargetTemporalLayer: -1,
+		syncRequired:                   true,
+	}
+
+	scalabilityMode := ParseScalabilityMode(c.rtpParameters.Encodings[0].ScalabilityMode)
+
+	if scalabilityMode.SpatialLayers < 2 && scalabilityMode.TemporalLayers < 2 {
+		c.logger.Warn("svc consumer with a single layer", "scalabilityMode", c.rtpParameters.Encodings[0].ScalabilityMode)
+	}
+
+	// Set preferred highest spatial and temporal layers by default.
+	c.preferredSpatialLayer = int16(scalabilityMode.SpatialLayers - 1)
+	c.preferredTemporalLayer = int16(scalabilityMode.TemporalLayers - 1)
+
+	if options.PreferredLayers != nil {
+		c.preferredSpatialLayer = min(int16(options.PreferredLayers.SpatialLayer), int16(scalabilityMode.SpatialLayers-1))
+		c.preferredTemporalLayer = min(int16(options.PreferredLayers.TemporalLayer), int16(scalabilityMode.TemporalLayers-1))
+	}
+
+	params := c.createRtpStreamParams(0)
+	c.rtpStream = NewRtpStreamSend(c, params)
+
+	if c.IsPaused() || c.IsProducerPaused() {
+		c.rtpStream.Pause()
+	}
+
+	c.encodingContext = newEncodingContext(codecs.EncodingContextParams{
+		SpatialLayers:  scalabilityMode.SpatialLayers,
+		TemporalLayers: scalabilityMode.TemporalLayers,
+		Ksvc:           scalabilityMode.Ksvc,
+	})
+
+	return c
+}
+
+func (c *SvcConsumer) Pause() {
+	if !c.pause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *SvcConsumer) Resume() {
+	if !c.resume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *SvcConsumer) ProducerPaused() {
+	if !c.producerPause() {
+		return
+	}
+	c.userOnPaused()
+}
+
+func (c *SvcConsumer) ProducerResumed() {
+	if !c.producerResume() {
+		return
+	}
+	c.userOnResumed()
+}
+
+func (c *SvcConsumer) ProducerRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	c.producerRtpStream = rtpStream
+}
+
+func (c *SvcConsumer) ProducerNewRtpStream(rtpStream *RtpStreamRecv, mappedSsrc uint32) {
+	c.producerRtpStream = rtpStream
+
+	if c.IsActive() {
+		c.MayChangeLayers(false)
+	}
+}
+
+// ProducerRtpStreamScore must be called when the score of the Producer stream
+// changes.
+func (c *SvcConsumer) ProducerRtpStreamScore(rtpStream *RtpStreamRecv, score, previousScore uint8) {
+	if c.IsActive() {
+		// Just check target layers if the stream has died or reborned.
+		if !c.externallyManagedBitrate || score == 0 || previousScore == 0 {
+			c.MayChangeLayers(false)
+		}
+	}
+
+	c.emitScore()
+}
+
+func (c *SvcConsumer) SetExternallyManagedBitrate() {
+	c.externallyManagedBitrate = true
+}
+
+func (c *SvcConsumer) SetPriority(priority uint8) {
+	c.priority = max(priority, 1)
+}
+
+func (c *SvcConsumer) GetPreferredLayers() ConsumerLayers {
+	return ConsumerLayers{
+		SpatialLayer:  uint8(c.preferredSpatialLayer),
+		TemporalLayer: uint8(c.preferredTemporalLayer),
+	}
+}
+
+func (c *SvcConsumer) SetPreferredLayers(layers ConsumerLayers) {
+	c.preferredSpatialLayer = min(int16(layers.SpatialLayer), int16(c.encodingContext.GetSpatialLayers()-1))
+	c.preferredTemporalLayer = min(int16(layers.TemporalLayer), int16(c.encodingContext.GetTemporalLayers()-1))
+
+	c.logger.Debug("preferred layers changed",
+		"spatialLayer", c.preferredSpatialLayer, "temporalLayer", c.preferredTemporalLayer)
+
+	if c.IsActive() {
+		c.MayChangeLayers(true)
+	}
+}
+
+// GetCurrentLayers returns the layers being sent, or nil if none.
+func (c *SvcConsumer) GetCurrentLayers() *ConsumerLayers {
+	if c.encodingContext.GetCurrentSpatialLayer() == -1 {
+		return nil
+	}
+	return &ConsumerLayers{
+		SpatialLayer:  uint8(c.encodingContext.GetCurrentSpatialLayer()),
+		TemporalLayer: uint8(c.encodingContext.GetCurrentTemporalLayer()),
+	}
+}
+
+// GetTargetLayers returns the layers the consumer is switching to, or nil if
+// none.
+func (c *SvcConsumer) GetTargetLayers() *ConsumerLayers {
+	if c.encodingContext.GetTargetSpatialLayer() == -1 {
+		return nil
+	}
+	return &ConsumerLayers{
+		SpatialLayer:  uint8(c.encodingContext.GetTargetSpatialLayer()),
+		TemporalLayer: uint8(c.encodingContext.GetTargetTemporalLayer()),
+	}
+}
+
+// GetBitratePriority returns 0 if the consumer does not need any bitrate.
+func (c *SvcConsumer) GetBitratePriority() uint8 {
+	if !c.IsActive() {
+		return 0
+	}
+	return c.priority
+}
+
+// IncreaseLayer selects the next provisional layer whose bitrate fits in the
+// given one, and returns the bitrate it requires. It returns 0 if no layer
+// could be increased.
+func (c *SvcConsumer) IncreaseLayer(bitrate uint32) uint32 {
+	if c.producerRtpStream == nil || c.producerRtpStream.GetScore() == 0 {
+		return 0
+	}
+
+	// If already in the preferred layers, do nothing.
+	if c.provisionalTargetSpatialLayer == c.preferredSpatialLayer &&
+		c.provisionalTargetTemporalLayer == c.preferredTemporalLayer {
+		return 0
+	}
+
+	var (
+		requiredBitrate uint32
+		spatialLayer    int16
+		temporalLayer   int16
+		found           bool
+	)
+
+	nowMs := uint64(time.Now().UnixMilli())
+
+	for spatialLayer = 0; spatialLayer < int16(c.producerRtpStream.GetSpatialLayers()); spatialLayer++ {
+		// If this is higher than current spatial layer and we moved to current
+		// spatial layer due to BWE limitations, check how much it has elapsed
+		// since then.
+		if nowMs-c.lastBweDowngradeAtMs < BweDowngradeConservativeMs {
+			if c.provisionalTargetSpatialLayer > -1 &&
+				spatialLayer > c.encodingContext.GetCurrentSpatialLayer() {
+				break
+			}
+		}
+
+		// Ignore spatial layers lower than the one we already have.
+		if spatialLayer < c.provisionalTargetSpatialLayer {
+			continue
+		}
+
+		for temporalLayer = 0; temporalLayer < int16(c.producerRtpStream.GetTemporalLayers()); temporalLayer++ {
+			// Ignore temporal layers lower than the one we already have (taking
+			// into account the spatial layer too).
+			if spatialLayer == c.provisionalTargetSpatialLayer &&
+				temporalLayer <= c.provisionalTargetTemporalLayer {
+				continue
+			}
+
+			requiredBitrate = c.producerRtpStream.GetLayerBitrate(nowMs, uint8(spatialLayer), uint8(temporalLayer))
+
+			// If active layer, end iterations here. Otherwise move to next
+			// spatial layer.
+			break
+		}
+
+		if requiredBitrate > 0 {
+			found = true
+			break
+		}
+
+		// If this is the preferred or higher spatial layer, take it and exit.
+		if spatialLayer >= c.preferredSpatialLayer {
+			break
+		}
+	}
+
+	// No higher active layers found, or no luck.
+	if !found || requiredBitrate > bitrate {
+		return 0
+	}
+
+	// Set provisional layers.
+	c.provisionalTargetSpatialLayer = spatialLayer
+	c.provisionalTargetTemporalLayer = temporalLayer
+
+	return requiredBitrate
+}
+
+// ApplyLayers sets the provisional layers chosen by IncreaseLayer as target
+// layers.
+func (c *SvcConsumer) ApplyLayers() {
+	provisionalTargetSpatialLayer := c.provisionalTargetSpatialLayer
+	provisionalTargetTemporalLayer := c.provisionalTargetTemporalLayer
+
+	// Reset provisional target layers.
+	c.provisionalTargetSpatialLayer = -1
+	c.provisionalTargetTemporalLayer = -1
+
+	if !c.IsActive() {
+		return
+	}
+
+	if provisionalTargetSpatialLayer != c.encodingContext.GetTargetSpatialLayer() ||
+		provisionalTargetTemporalLayer != c.encodingContext.GetTargetTemporalLayer() {
+		c.updateTargetLayers(provisionalTargetSpatialLayer, provisionalTargetTemporalLayer)
+
+		// If this looks like a spatial layer downgrade due to BWE limitations,
+		// set member.
+		if c.rtpStream.GetActiveMs() > BweDowngradeMinActiveMs &&
+			c.encodingContext.GetTargetSpatialLayer() < c.encodingContext.GetCurrentSpatialLayer() &&
+			c.encodingContext.GetCurrentSpatialLayer() <= c.preferredSpatialLayer {
+			c.logger.Debug("possible target spatial layer downgrade due to BWE limitation")
+
+			c.lastBweDowngradeAtMs = uint64(time.Now().UnixMilli())
+		}
+	}
+}
+
+// GetDesiredBitrate returns the bitrate of the whole Producer stream.
+func (c *SvcConsumer) GetDesiredBitrate() uint32 {
+	if !c.IsActive() || c.producerRtpStream == nil {
+		return 0
+	}
+
+	desiredBitrate := c.producerRtpStream.GetBitrate(uint64(time.Now().UnixMilli()))
+
+	// If the consumer max bitrate was given and it's greater than computed one,
+	// then use it.
+	if maxBitrate := c.rtpParameters.Encodings[0].MaxBitrate; maxBitrate > desiredBitrate {
+		desiredBitrate = maxBitrate
+	}
+
+	return desiredBitrate
+}
+
+// GetRtxPaddingPacket returns a RTX padding packet, with the header extensions
+// of the consumer, or nil if the consumer cannot send any.
+func (c *SvcConsumer) GetRtxPaddingPacket(paddingSize uint8) *RtpPacket {
+	if !c.IsActive() {
+		return nil
+	}
+
+	packet := c.rtpStream.GetRtxPaddingPacket(paddingSize)
+	if packet == nil {
+		return nil
+	}
+
+	// Add the zeroed congestion control extensions, stamped when sent.
+	packet.MapHeaderExtensions(c.headerExtensionIds, c.rtpParameters.Mid)
+
+	return packet
+}
+
+func (c *SvcConsumer) SendRtpPacket(packet *RtpPacket) {
+	if !c.IsActive() {
+		return
+	}
+
+	// Don't forward packets until some layers have been chosen.
+	if c.encodingContext.GetTargetSpatialLayer() == -1 || c.encodingContext.GetTargetTemporalLayer() == -1 {
+		return
+	}
+
+	// NOTE: This may happen if this Consumer supports just some codecs of those
+	// in the corresponding Producer.
+	if !c.isSupportedPayloadType(packet.PayloadType) {
+		c.rtpSeqManager.Drop(packet.SequenceNumber)
+		return
+	}
+
+	// If we need to sync and this is not a key frame, ignore the packet.
+	if c.syncRequired && !packet.IsKeyFrame() {
+		return
+	}
+
+	// Packets with only padding are not forwarded.
+	if len(packet.Payload) == 0 {
+		c.rtpSeqManager.Drop(packet.SequenceNumber)
+		return
+	}
+
+	// Whether this is the first packet after re-sync.
+	isSyncPacket := c.syncRequired
+
+	// Sync sequence number if required.
+	if isSyncPacket {
+		if packet.IsKeyFrame() {
+			c.logger.Debug("sync key frame received")
+		}
+
+		c.rtpSeqManager.Sync(packet.SequenceNumber - 1)
+		c.encodingContext.SyncRequired()
+
+		c.syncRequired = false
+	}
+
+	previousSpatialLayer := c.encodingContext.GetCurrentSpatialLayer()
+	previousTemporalLayer := c.encodingContext.GetCurrentTemporalLayer()
+
+	// Rewrite payload if needed. Drop packet if necessary. The payload
+	// descriptor handler also applies the K-SVC rules for switching spatial
+	// layers.
+	marker, ok := packet.ProcessPayload(c.encodingContext, packet.Payload)
+	if !ok {
+		c.rtpSeqManager.Drop(packet.SequenceNumber)
+		return
+	}
+
+	if previousSpatialLayer != c.encodingContext.GetCurrentSpatialLayer() ||
+		previousTemporalLayer != c.encodingContext.GetCurrentTemporalLayer() {
+		c.logger.Debug("current layers changed",
+			"spatialLayer", c.encodingContext.GetCurrentSpatialLayer(),
+			"temporalLayer", c.encodingContext.GetCurrentTemporalLayer())
+	}
+
+	// Update RTP seq number based on the offset.
+	seq, ok := c.rtpSeqManager.Input(packet.SequenceNumber)
+	if !ok {
+		packet.RestorePayload()
+		return
+	}
+
+	// Save original packet fields.
+	origSsrc := packet.SSRC
+	origSeq := packet.SequenceNumber
+	origMarker := packet.Marker
+
+	// Rewrite packet.
+	packet.SSRC = c.rtpParameters.Encodings[0].Ssrc
+	packet.SequenceNumber = seq
+	origHeaderExtensions := packet.MapHeaderExtensions(c.headerExtensionIds, c.rtpParameters.Mid)
+	if marker {
+		packet.Marker = true
+	}
+
+	if isSyncPacket {
+		c.logger.Debug("sending sync packet", "ssrc", packet.SSRC, "seq", seq, "origSeq", origSeq)
+	}
+
+	// Process the packet.
+	if c.rtpStream.ReceivePacket(packet) {
+		// Send the packet.
+		c.listener.OnConsumerSendRtpPacket(c, packet)
+	} else {
+		c.logger.Warn("failed to send packet", "ssrc", packet.SSRC, "seq", seq, "origSeq", origSeq)
+	}
+
+	// Restore packet fields.
+	packet.SSRC = origSsrc
+	packet.SequenceNumber = origSeq
+	packet.RestoreHeaderExtensions(origHeaderExtensions)
+	packet.Marker = origMarker
+
+	// Restore the original payload if needed.
+	packet.RestorePayload()
+}
+
+func (c *SvcConsumer) ReceiveNack(nackPacket *rtcp.Nack) {
+	if !c.IsActive() {
+		return
+	}
+
+	c.rtpStream.ReceiveNack(nackPacket)
+}
+
+func (c *SvcConsumer) ReceiveRtcpReceiverReport(report *rtcp.ReceptionReport) {
+	c.rtpStream.ReceiveRtcpReceiverReport(report)
+}
+
+func (c *SvcConsumer) GetRtcp(nowMs uint64) []rtcp.Packet {
+	return getRtcpPackets(nowMs, c.rtpStream)
+}
+
+func (c *SvcConsumer) RequestKeyFrame() {
+	if c.kind != MediaKindVideo || c.producerRtpStream == nil {
+		return
+	}
+
+	mappedSsrc := c.consumableRtpEncodings[0].Ssrc
+
+	c.listener.OnConsumerKeyFrameRequested(c, mappedSsrc)
+}
+
+func (c *SvcConsumer) GetScore() ConsumerScore {
+	score := ConsumerScore{
+		Score:          c.rtpStream.GetScore(),
+		ProducerScores: []uint8{0},
+	}
+
+	if c.producerRtpStream != nil {
+		score.ProducerScore = c.producerRtpStream.GetScore()
+		score.ProducerScores[0] = score.ProducerScore
+	}
+
+	return score
+}
+
+func (c *SvcConsumer) GetStats() []RtpStreamStats {
+	stats := []RtpStreamStats{c.rtpStream.GetStats()}
+
+	if c.producerRtpStream != nil {
+		stats = append(stats, c.producerRtpStream.GetStats())
+	}
+
+	return stats
+}
+
+func (c *SvcConsumer) Close() {}
+
+// MayChangeLayers recalculates the target layers. If the bitrate is managed
+// externally, the listener is asked to redistribute it instead.
+func (c *SvcConsumer) MayChangeLayers(force bool) {
+	newTargetSpatialLayer, newTargetTemporalLayer, changed := c.recalculateTargetLayers()
+	if !changed {
+		return
+	}
+
+	// If bitrate externally managed, don't bother the transport unless the
+	// newTargetSpatialLayer has changed (or force is true). This is because,
+	// if bitrate is externally managed, the target temporal layer is managed by
+	// the available given bitrate so the transport will let us change it when
+	// it considers.
+	if c.externallyManagedBitrate {
+		if newTargetSpatialLayer != c.encodingContext.GetTargetSpatialLayer() || force {
+			c.listener.OnConsumerNeedBitrateChange(c)
+		}
+	} else {
+		c.updateTargetLayers(newTargetSpatialLayer, newTargetTemporalLayer)
+	}
+}
+
+func (c *SvcConsumer) recalculateTargetLayers() (newTargetSpatialLayer, newTargetTemporalLayer int16, changed bool) {
+	// Start with no layers.
+	newTargetSpatialLayer = -1
+	newTargetTemporalLayer = -1
+
+	if c.producerRtpStream != nil && c.producerRtpStream.GetScore() > 0 {
+		nowMs := uint64(time.Now().UnixMilli())
+
+		for spatialLayer := int16(0); spatialLayer < int16(c.producerRtpStream.GetSpatialLayers()); spatialLayer++ {
+			// If this is higher than current spatial layer and we moved to current
+			// spatial layer due to BWE limitations, check how much it has elapsed
+			// since then.
+			if nowMs-c.lastBweDowngradeAtMs < BweDowngradeConservativeMs {
+				if newTargetSpatialLayer > -1 && spatialLayer > c.encodingContext.GetCurrentSpatialLayer() {
+					continue
+				}
+			}
+
+			// Ignore spatial layers not being received.
+			if c.producerRtpStream.GetSpatialLayerBitrate(nowMs, uint8(spatialLayer)) == 0 {
+				continue
+			}
+
+			newTargetSpatialLayer = spatialLayer
+
+			// If this is the preferred or higher spatial layer take it and exit.
+			if spatialLayer >= c.preferredSpatialLayer {
+				break
+			}
+		}
+	}
+
+	if newTargetSpatialLayer != -1 {
+		if newTargetSpatialLayer == c.preferredSpatialLayer {
+			newTargetTemporalLayer = c.preferredTemporalLayer
+		} else if newTargetSpatialLayer < c.preferredSpatialLayer {
+			newTargetTemporalLayer = int16(c.encodingContext.GetTemporalLayers() - 1)
+		} else {
+			newTargetTemporalLayer = 0
+		}
+	}
+
+	// Return true if any target layer changed.
+	changed = newTargetSpatialLayer != c.encodingContext.GetTargetSpatialLayer() ||
+		newTargetTemporalLayer != c.encodingContext.GetTargetTemporalLayer()
+
+	return
+}
+
+func (c *SvcConsumer) updateTargetLayers(newTargetSpatialLayer, newTargetTemporalLayer int16) {
+	if newTargetSpatialLayer == -1 {
+		// Unset current and target layers.
+		c.encodingContext.SetTargetSpatialLayer(-1)
+		c.encodingContext.SetTargetTemporalLayer(-1)
+		c.encodingContext.SetCurrentSpatialLayer(-1)
+		c.encodingContext.SetCurrentTemporalLayer(-1)
+
+		c.logger.Debug("target layers changed", "spatialLayer", -1, "temporalLayer", -1)
+
+		return
+	}
+
+	c.encodingContext.SetTargetSpatialLayer(newTargetSpatialLayer)
+	c.encodingContext.SetTargetTemporalLayer(newTargetTemporalLayer)
+
+	c.logger.Debug("target layers changed",
+		"spatialLayer", newTargetSpatialLayer, "temporalLayer", newTargetTemporalLayer)
+
+	// Upgrading the spatial layer needs a key frame. With K-SVC every spatial
+	// layer is an independent stream, so a key frame is needed for any spatial
+	// layer switch.
+	currentSpatialLayer := c.encodingContext.GetCurrentSpatialLayer()
+
+	if newTargetSpatialLayer > currentSpatialLayer ||
+		(c.encodingContext.IsKSvc() && newTargetSpatialLayer != currentSpatialLayer) {
+		c.RequestKeyFrame()
+	}
+}
+
+func (c *SvcConsumer) userOnPaused() {
+	c.rtpStream.Pause()
+
+	c.updateTargetLayers(-1, -1)
+
+	// Tell the transport so it can distribute available bitrate into other
+	// consumers.
+	if c.externallyManagedBitrate {
+		c.listener.OnConsumerNeedZeroBitrate(c)
+	}
+}
+
+func (c *SvcConsumer) userOnResumed() {
+	c.syncRequired = true
+	c.rtpStream.Resume()
+
+	if c.IsActive() {
+		c.MayChangeLayers(true)
+	}
+}
+
+func (c *SvcConsumer) OnRtpStreamRetransmitRtpPacket(rtpStream *RtpStreamSend, packet *RtpPacket) {
+	c.listener.OnConsumerRetransmitRtpPacket(c, packet)
+}
+
+func (c *SvcConsumer) OnRtpStreamScore(rtpStream *RtpStreamSend, score, previousScore uint8) {
+	c.emitScore()
+}
+
+func (c *SvcConsumer) emitScore() {
+	c.listener.OnConsumerScore(c, c.GetScore())
+}
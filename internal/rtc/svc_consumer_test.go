@@ -0,0 +1,173 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/codecs"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSvcPayloadDescriptorHandler drops layers above the target ones and only
+// switches spatial layer up on key frames.
+type TestSvcPayloadDescriptorHandler struct {
+	spatialLayer  uint8
+	temporalLayer uint8
+	isKeyFrame    bool
+	// restored counts the calls to Restore().
+	restored int
+}
+
+func (h *TestSvcPayloadDescriptorHandler) Dump() {}
+
+func (h *TestSvcPayloadDescriptorHandler) Process(context *codecs.EncodingContext, data []byte) (bool, bool) {
+	spatialLayer := int16(h.spatialLayer)
+	temporalLayer := int16(h.temporalLayer)
+
+	if spatialLayer > context.GetTargetSpatialLayer() || temporalLayer > context.GetTargetTemporalLayer() {
+		return false, false
+	}
+	if spatialLayer > context.GetCurrentSpatialLayer() {
+		if !h.isKeyFrame {
+			return false, false
+		}
+		context.SetCurrentSpatialLayer(spatialLayer)
+	}
+	if context.IsKSvc() && spatialLayer != context.GetCurrentSpatialLayer() {
+		return false, false
+	}
+	if temporalLayer > context.GetCurrentTemporalLayer() {
+		context.SetCurrentTemporalLayer(temporalLayer)
+	}
+	return false, true
+}
+
+func (h *TestSvcPayloadDescriptorHandler) Restore(data []byte) {
+	h.restored++
+}
+
+func (h *TestSvcPayloadDescriptorHandler) GetSpatialLayer() uint8 {
+	return h.spatialLayer
+}
+
+func (h *TestSvcPayloadDescriptorHandler) GetTemporalLayer() uint8 {
+	return h.temporalLayer
+}
+
+func (h *TestSvcPayloadDescriptorHandler) IsKeyFrame() bool {
+	return h.isKeyFrame
+}
+
+func createTestSvcPacket(t *testing.T, seq uint16, spatialLayer, temporalLayer uint8, isKeyFrame bool) *RtpPacket {
+	packet := createTestRtpPacket(t, 9001, seq, 100, nil)
+	packet.SetPayloadDescriptorHandler(&TestSvcPayloadDescriptorHandler{
+		spatialLayer:  spatialLayer,
+		temporalLayer: temporalLayer,
+		isKeyFrame:    isKeyFrame,
+	})
+	return packet
+}
+
+func createTestSvcConsumerOptions(scalabilityMode string) *ConsumerOptions {
+	return &ConsumerOptions{
+		ProducerId: "p1",
+		Kind:       MediaKindVideo,
+		RtpParameters: RtpParameters{
+			Codecs: []*RtpCodecParameters{
+				{MimeType: "video/VP9", PayloadType: 100, ClockRate: 90000},
+			},
+			Encodings: []RtpEncodingParameters{{Ssrc: 5555, ScalabilityMode: scalabilityMode}},
+		},
+		ConsumableRtpEncodings: []RtpEncodingParameters{{Ssrc: 9001, ScalabilityMode: scalabilityMode}},
+	}
+}
+
+func createTestSvcProducerRtpStream(t *testing.T) *RtpStreamRecv {
+	params := RtpStreamParams{Ssrc: 9001, ClockRate: 90000, MimeType: "video/VP9", SpatialLayers: 3, TemporalLayers: 3}
+	stream := NewRtpStreamRecv(nil, params, 0)
+	for spatialLayer := uint8(0); spatialLayer < 3; spatialLayer++ {
+		require.True(t, stream.ReceivePacket(createTestSvcPacket(t, uint16(spatialLayer), spatialLayer, 0, false)))
+	}
+	return stream
+}
+
+func TestSvcConsumer(t *testing.T) {
+	t.Run("drops layers above the target ones keeping sequence numbers gapless", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		options := createTestSvcConsumerOptions("L3T3")
+		options.PreferredLayers = &ConsumerLayers{SpatialLayer: 1, TemporalLayer: 1}
+		consumer := NewSvcConsumer("c1", listener, options)
+
+		consumer.ProducerNewRtpStream(createTestSvcProducerRtpStream(t), 9001)
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 1, TemporalLayer: 1}, consumer.GetTargetLayers())
+		require.Equal(t, []uint32{9001}, listener.keyFrameRequests)
+
+		// Waiting for a key frame.
+		consumer.SendRtpPacket(createTestSvcPacket(t, 100, 0, 0, false))
+
+		consumer.SendRtpPacket(createTestSvcPacket(t, 101, 0, 0, true))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 102, 1, 0, true))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 103, 2, 0, true))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 104, 0, 1, false))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 105, 1, 2, false))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 106, 1, 1, false))
+
+		require.Equal(t, []uint16{1, 2, 3, 4}, listener.sentSeqs())
+		for _, packet := range listener.sentPackets {
+			require.EqualValues(t, 5555, packet.ssrc)
+		}
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 1, TemporalLayer: 1}, consumer.GetCurrentLayers())
+	})
+
+	t.Run("k-svc requests a key frame when switching spatial layer down", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSvcConsumer("c1", listener, createTestSvcConsumerOptions("L3T3_KEY"))
+
+		consumer.ProducerNewRtpStream(createTestSvcProducerRtpStream(t), 9001)
+		require.Equal(t, []uint32{9001}, listener.keyFrameRequests)
+
+		consumer.SendRtpPacket(createTestSvcPacket(t, 1, 0, 0, true))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 2, 1, 0, true))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 3, 2, 0, true))
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 2, TemporalLayer: 0}, consumer.GetCurrentLayers())
+
+		consumer.SetPreferredLayers(ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0})
+		require.Equal(t, []uint32{9001, 9001}, listener.keyFrameRequests)
+	})
+
+	t.Run("restores the payload of a packet whose sequence number was dropped", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSvcConsumer("c1", listener, createTestSvcConsumerOptions("L3T3"))
+
+		consumer.ProducerNewRtpStream(createTestSvcProducerRtpStream(t), 9001)
+		consumer.SendRtpPacket(createTestSvcPacket(t, 100, 0, 0, true))
+
+		// Padding only packet, its sequence number is dropped.
+		packet := createTestSvcPacket(t, 101, 0, 0, false)
+		packet.Payload = nil
+		consumer.SendRtpPacket(packet)
+
+		packet = createTestSvcPacket(t, 101, 0, 0, false)
+		consumer.SendRtpPacket(packet)
+
+		require.Equal(t, []uint16{1}, listener.sentSeqs())
+		require.Equal(t, 1, packet.payloadDescriptorHandler.(*TestSvcPayloadDescriptorHandler).restored)
+	})
+
+	t.Run("paused consumer unsets layers and re-syncs on resume", func(t *testing.T) {
+		listener := &TestConsumerListener{}
+		consumer := NewSvcConsumer("c1", listener, createTestSvcConsumerOptions("L3T3"))
+		consumer.ProducerNewRtpStream(createTestSvcProducerRtpStream(t), 9001)
+
+		consumer.SendRtpPacket(createTestSvcPacket(t, 10, 0, 0, true))
+
+		consumer.Pause()
+		require.Nil(t, consumer.GetTargetLayers())
+		require.Nil(t, consumer.GetCurrentLayers())
+
+		consumer.Resume()
+		consumer.SendRtpPacket(createTestSvcPacket(t, 20, 0, 0, false))
+		consumer.SendRtpPacket(createTestSvcPacket(t, 21, 0, 0, true))
+
+		require.Equal(t, []uint16{1, 2}, listener.sentSeqs())
+	})
+}
@@ -1,5 +1,26 @@
 package rtc
 
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+const (
+	// ProbationClusterIntervalMs is the minimum interval between two clusters
+	// of probation packets.
+	ProbationClusterIntervalMs = 100
+	// probationMaxBitrateFactor limits the bitrate probed for to this factor
+	// of the available outgoing bitrate.
+	probationMaxBitrateFactor = 1.5
+	// transportSendBitrateWindowMs is the window the sent bitrate is computed
+	// on.
+	transportSendBitrateWindowMs = 1000
+)
+
 type SctpState int
 
 const (
@@ -17,6 +38,45 @@ type Transport struct {
 	initialAvailableOutgoingBitrate uint32 // Assuming the unit is bps
 	sctpAssociation                 *SctpAssociation
 	listener                        TransportListener
+	// mu guards the state below, changed from the goroutines receiving and
+	// sending packets and from the one of the feedback timer. The listener is
+	// called with it held so the packets are sent in order.
+	mu     sync.Mutex
+	closed bool
+	// transportWideCcSeq is the last transport-wide sequence number sent.
+	transportWideCcSeq uint16
+	// tccServer is created with the first producer negotiating transport-wide
+	// sequence numbers.
+	tccServer *TransportCongestionControlServer
+	// tccClient is created with the first packet sent with a transport-wide
+	// sequence number.
+	tccClient                *TransportCongestionControlClient
+	availableOutgoingBitrate uint32
+	// rembServer is created with the first producer negotiating abs-send-time
+	// but not transport-wide sequence numbers.
+	rembServer *RemoteBitrateEstimator
+	// availableIncomingBitrate smooths the estimates reported in REMB packets.
+	availableIncomingBitrate *TrendCalculator
+	// consumers are the consumers sending through this transport, by id.
+	consumers map[string]Consumer
+	// bitrateManagedConsumers are the layered consumers whose layers are
+	// chosen to fit the available outgoing bitrate, by id.
+	bitrateManagedConsumers map[string]LayeredConsumer
+	// sendBitrate is the rate of the sent packets, probation ones included.
+	sendBitrate *RateCalculator
+	// probationSsrc and probationPayloadType are the ones negotiated for the
+	// probation packets, zero if none.
+	probationSsrc        uint32
+	probationPayloadType uint8
+	// probationGenerator is created with the first consumer whose bitrate is
+	// managed, if a probation SSRC was negotiated. Otherwise the consumers
+	// probe with RTX padding.
+	probationGenerator *RtpProbationGenerator
+	// probationTargetBitrate is the bitrate probation packets make the sent
+	// one reach while the consumers desire more than the available outgoing
+	// bitrate, zero if not probing.
+	probationTargetBitrate   uint32
+	lastProbationClusterAtMs uint64
 	// Add other attributes as needed
 }
 
@@ -24,20 +84,21 @@ type SctpAssociation struct {
 	// Define attributes
 }
 
+// TransportListener methods sending packets are called one at a time, with
+// the transport locked, so they must not call back into it.
 type TransportListener interface {
 	OnTransportProducerClosed(transport *Transport, producer *Producer)
-	OnTransportConsumerClosed(transport *Transport, consumer *Consumer)
+	OnTransportConsumerClosed(transport *Transport, consumer Consumer)
+	// OnTransportSendRtpPacket is called with a packet of the given consumer
+	// ready to be sent to the remote endpoint. The consumer is nil for the
+	// probation packets sent on the probation SSRC.
+	OnTransportSendRtpPacket(transport *Transport, consumer Consumer, packet *RtpPacket)
+	// OnTransportSendRtcpPacket is called with a RTCP packet generated by the
+	// transport to be sent to the remote endpoint.
+	OnTransportSendRtcpPacket(transport *Transport, packet rtcp.Packet)
 	// Define other callback methods as needed
 }
 
-type Producer struct {
-	// Define attributes
-}
-
-type Consumer struct {
-	// Define attributes
-}
-
 type TimerHandle struct {
 	// Define attributes
 }
@@ -52,14 +113,32 @@ type TransportOptions struct {
 	MaxSctpMessageSize              uint32
 	SctpSendBufferSize              uint32
 	IsDataChannel                   bool
+
+	// ProbationSsrc and ProbationPayloadType are the ones negotiated with the
+	// remote endpoint for the probation packets. If not set, the probation
+	// packets are sent as RTX padding of the consumers.
+	ProbationSsrc        uint32
+	ProbationPayloadType uint8
 }
 
 func NewTransport(id string, listener TransportListener, options *TransportOptions) *Transport {
 	// Initialize Transport instance using the provided id, listener, and options
 	transport := &Transport{
 		id:                              id,
+		listener:                        listener,
 		direct:                          options.Direct,
 		initialAvailableOutgoingBitrate: options.InitialAvailableOutgoingBitrate,
+		availableOutgoingBitrate:        options.InitialAvailableOutgoingBitrate,
+		availableIncomingBitrate:        NewTrendCalculator(),
+		consumers:                       make(map[string]Consumer),
+		bitrateManagedConsumers:         make(map[string]LayeredConsumer),
+		sendBitrate:                     NewRateCalculator(transportSendBitrateWindowMs, 8000, 100),
+		probationSsrc:                   options.ProbationSsrc,
+		probationPayloadType:            options.ProbationPayloadType,
+	}
+
+	if transport.availableOutgoingBitrate == 0 {
+		transport.availableOutgoingBitrate = TransportCcDefaultInitialBitrate
 	}
 
 	if options.Direct {
@@ -78,9 +157,361 @@ func NewTransport(id string, listener TransportListener, options *TransportOptio
 }
 
 func (transport *Transport) Close() {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
 	// Implement Transport closing logic
+	transport.closed = true
+
+	if transport.tccServer != nil {
+		transport.tccServer.Close()
+	}
+	if transport.tccClient != nil {
+		transport.tccClient.Close()
+	}
+	if transport.rembServer != nil {
+		transport.rembServer.Close()
+	}
+}
+
+// GetAvailableOutgoingBitrate returns the estimated available outgoing
+// bitrate, the initial one until the remote endpoint reports on the sent
+// packets.
+func (transport *Transport) GetAvailableOutgoingBitrate() uint32 {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	return transport.availableOutgoingBitrate
+}
+
+// GetAvailableIncomingBitrate returns the estimated available incoming
+// bitrate reported to the remote endpoint in REMB packets, zero if not known.
+func (transport *Transport) GetAvailableIncomingBitrate() uint32 {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	return transport.availableIncomingBitrate.GetValue()
+}
+
+// AddConsumer registers a consumer sending through this transport. The
+// bitrate of the layered ones negotiating transport-wide sequence numbers is
+// managed by the transport.
+func (transport *Transport) AddConsumer(consumer Consumer) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.consumers[consumer.Id()] = consumer
+
+	headerExtensionIds := NewRtpHeaderExtensionIds(consumer.GetRtpParameters().HeaderExtensions)
+
+	layeredConsumer, ok := consumer.(LayeredConsumer)
+	if !ok || headerExtensionIds.TransportWideCc01 == 0 {
+		return
+	}
+
+	if transport.tccClient == nil {
+		transport.tccClient = NewTransportCongestionControlClient(transport, transport.initialAvailableOutgoingBitrate)
+	}
+	if transport.probationGenerator == nil && transport.probationSsrc != 0 {
+		transport.probationGenerator = NewRtpProbationGenerator(transport.probationSsrc, transport.probationPayloadType, headerExtensionIds)
+	}
+
+	layeredConsumer.SetExternallyManagedBitrate()
+	transport.bitrateManagedConsumers[consumer.Id()] = layeredConsumer
+
+	transport.distributeAvailableOutgoingBitrate()
+}
+
+// RemoveConsumer unregisters a consumer, the bitrate it used being given to
+// the other ones.
+func (transport *Transport) RemoveConsumer(consumer Consumer) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	delete(transport.consumers, consumer.Id())
+
+	if _, ok := transport.bitrateManagedConsumers[consumer.Id()]; ok {
+		delete(transport.bitrateManagedConsumers, consumer.Id())
+		transport.distributeAvailableOutgoingBitrate()
+	}
 }
 
 func (transport *Transport) CloseProducersAndConsumers() {
 	// Implement closing logic for producers and consumers
 }
+
+// ReceiveRtpPacket handles a packet received from the remote endpoint for the
+// given producer.
+func (transport *Transport) ReceiveRtpPacket(producer *Producer, packet *RtpPacket) ReceiveRtpPacketResult {
+	nowMs := uint64(time.Now().UnixMilli())
+
+	transport.mu.Lock()
+
+	if !transport.closed {
+		if transport.tccServer == nil && producer.headerExtensionIds.TransportWideCc01 != 0 {
+			transport.tccServer = NewTransportCongestionControlServer(transport)
+		}
+		if transport.rembServer == nil && producer.headerExtensionIds.AbsSendTime != 0 &&
+			producer.headerExtensionIds.TransportWideCc01 == 0 {
+			transport.rembServer = NewRemoteBitrateEstimator(transport)
+		}
+	}
+	tccServer := transport.tccServer
+	rembServer := transport.rembServer
+
+	transport.mu.Unlock()
+
+	// The congestion control extensions are read with the ids of the
+	// producer, which sets them on the packet afterwards. The estimators call
+	// back into the transport, so it is not locked meanwhile.
+	if tccServer != nil {
+		tccServer.IncomingPacket(nowMs, packet, producer.headerExtensionIds)
+	}
+	if rembServer != nil {
+		rembServer.IncomingPacket(nowMs, packet, producer.headerExtensionIds)
+	}
+
+	return producer.ReceiveRtpPacket(packet)
+}
+
+// ReceiveRtcpPacket handles a RTCP packet received from the remote endpoint.
+func (transport *Transport) ReceiveRtcpPacket(packet rtcp.Packet) {
+	nowMs := uint64(time.Now().UnixMilli())
+
+	transport.mu.Lock()
+	tccClient := transport.tccClient
+	transport.mu.Unlock()
+
+	switch packet := packet.(type) {
+	case *rtcp.TransportFeedback:
+		// It calls back into the transport, so it is not locked meanwhile.
+		if tccClient != nil {
+			tccClient.ReceiveRtcpTransportFeedback(packet, nowMs)
+		}
+	}
+}
+
+func (transport *Transport) OnTransportCongestionControlClientBitrate(tccClient *TransportCongestionControlClient, availableBitrate uint32) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.availableOutgoingBitrate = availableBitrate
+
+	transport.distributeAvailableOutgoingBitrate()
+}
+
+func (transport *Transport) OnTransportCongestionControlServerSendRtcpPacket(tccServer *TransportCongestionControlServer, packet rtcp.Packet) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.listener.OnTransportSendRtcpPacket(transport, packet)
+}
+
+func (transport *Transport) OnRemoteBitrateEstimatorValue(estimator *RemoteBitrateEstimator, ssrcs []uint32, availableBitrate uint32) {
+	nowMs := uint64(time.Now().UnixMilli())
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.availableIncomingBitrate.Update(availableBitrate, nowMs)
+
+	packet := &rtcp.Remb{
+		Bitrate: uint64(transport.availableIncomingBitrate.GetValue()),
+		Ssrcs:   ssrcs,
+	}
+
+	transport.listener.OnTransportSendRtcpPacket(transport, packet)
+}
+
+// OnConsumerNeedBitrateChange redistributes the available outgoing bitrate
+// when the layers of a consumer may change.
+func (transport *Transport) OnConsumerNeedBitrateChange(consumer Consumer) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.distributeAvailableOutgoingBitrate()
+}
+
+// OnConsumerNeedZeroBitrate gives the bitrate of a consumer that stopped
+// sending to the other ones.
+func (transport *Transport) OnConsumerNeedZeroBitrate(consumer Consumer) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.distributeAvailableOutgoingBitrate()
+}
+
+// OnConsumerSendRtpPacket sends a packet forwarded by a consumer of this
+// transport.
+func (transport *Transport) OnConsumerSendRtpPacket(consumer Consumer, packet *RtpPacket) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.sendRtpPacket(consumer, packet)
+
+	transport.mayProbe()
+}
+
+// OnConsumerRetransmitRtpPacket sends a packet resent by a consumer of this
+// transport.
+func (transport *Transport) OnConsumerRetransmitRtpPacket(consumer Consumer, packet *RtpPacket) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.sendRtpPacket(consumer, packet)
+}
+
+// mayProbe sends a cluster of probation packets, when due, so the sent
+// bitrate reaches the probation target one and the estimate can grow to the
+// bitrate the consumers desire. Must be called with mu held.
+func (transport *Transport) mayProbe() {
+	if transport.probationTargetBitrate == 0 {
+		return
+	}
+
+	nowMs := uint64(time.Now().UnixMilli())
+
+	elapsedMs := nowMs - transport.lastProbationClusterAtMs
+	if elapsedMs < ProbationClusterIntervalMs {
+		return
+	}
+	transport.lastProbationClusterAtMs = nowMs
+
+	sendBitrate := transport.sendBitrate.GetRate(nowMs)
+	if sendBitrate >= transport.probationTargetBitrate {
+		return
+	}
+
+	// Make up for the bitrate missing since the previous cluster, not
+	// accounting for long pauses.
+	size := uint64(transport.probationTargetBitrate-sendBitrate) * min(elapsedMs, 2*ProbationClusterIntervalMs) / 8000
+
+	for size > 0 {
+		consumer, packet := transport.getProbationPacket(uint8(min(size, RtpProbationMaxPaddingSize)))
+		if packet == nil {
+			return
+		}
+		transport.sendRtpPacket(consumer, packet)
+		size -= min(size, packet.Size)
+	}
+}
+
+// getProbationPacket returns a probation packet on the probation SSRC, if
+// negotiated, or else the RTX padding packet of the first consumer able to
+// send one, with that consumer. Must be called with mu held.
+func (transport *Transport) getProbationPacket(paddingSize uint8) (Consumer, *RtpPacket) {
+	if transport.probationGenerator != nil {
+		return nil, transport.probationGenerator.GetNextPacket(paddingSize)
+	}
+
+	ids := make([]string, 0, len(transport.bitrateManagedConsumers))
+	for id := range transport.bitrateManagedConsumers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		consumer := transport.bitrateManagedConsumers[id]
+		if packet := consumer.GetRtxPaddingPacket(paddingSize); packet != nil {
+			return consumer, packet
+		}
+	}
+
+	return nil, nil
+}
+
+// sendRtpPacket stamps the send time and the transport-wide sequence number
+// of the packet, if the consumer negotiated them, and sends it. Must be called
+// with mu held.
+func (transport *Transport) sendRtpPacket(consumer Consumer, packet *RtpPacket) {
+	nowMs := uint64(time.Now().UnixMilli())
+
+	packet.UpdateAbsSendTime(nowMs)
+
+	if packet.UpdateTransportWideCc01(transport.transportWideCcSeq + 1) {
+		transport.transportWideCcSeq++
+
+		if transport.tccClient == nil {
+			transport.tccClient = NewTransportCongestionControlClient(transport, transport.initialAvailableOutgoingBitrate)
+		}
+		transport.tccClient.PacketSent(transport.transportWideCcSeq, packet.Size, nowMs)
+	}
+
+	transport.sendBitrate.Update(packet.Size, nowMs)
+
+	transport.listener.OnTransportSendRtpPacket(transport, consumer, packet)
+}
+
+// distributeAvailableOutgoingBitrate lets the consumers whose bitrate is
+// managed by the transport increase their layers one at a time until the
+// available outgoing bitrate is exhausted. Every consumer gets a layer in turn
+// first, then the remaining bitrate goes round-robin from the highest priority
+// ones, as many layers per round as their priority. Layers are chosen from
+// scratch every time, so they are lowered when the bitrate drops. Must be
+// called with mu held.
+func (transport *Transport) distributeAvailableOutgoingBitrate() {
+	consumers := make([]LayeredConsumer, 0, len(transport.bitrateManagedConsumers))
+	for _, consumer := range transport.bitrateManagedConsumers {
+		if consumer.GetBitratePriority() > 0 {
+			consumers = append(consumers, consumer)
+		}
+	}
+
+	// Nobody wants bitrate.
+	if len(consumers) == 0 {
+		transport.probationTargetBitrate = 0
+		return
+	}
+
+	// Highest priority first, then in id order.
+	slices.SortFunc(consumers, func(a, b LayeredConsumer) int {
+		if a.GetBitratePriority() != b.GetBitratePriority() {
+			return int(b.GetBitratePriority()) - int(a.GetBitratePriority())
+		}
+		return strings.Compare(a.Id(), b.Id())
+	})
+
+	availableBitrate := transport.availableOutgoingBitrate
+	baseAllocation := true
+
+	// Probe for the bitrate the consumers desire beyond the available one.
+	var desiredBitrate uint64
+	for _, consumer := range consumers {
+		desiredBitrate += uint64(consumer.GetDesiredBitrate())
+	}
+	transport.probationTargetBitrate = 0
+	if desiredBitrate > uint64(availableBitrate) {
+		transport.probationTargetBitrate = uint32(min(desiredBitrate,
+			uint64(probationMaxBitrateFactor*float64(availableBitrate))))
+	}
+
+	for availableBitrate > 0 {
+		previousAvailableBitrate := availableBitrate
+
+		for _, consumer := range consumers {
+			layers := uint8(1)
+			if !baseAllocation {
+				layers = consumer.GetBitratePriority()
+			}
+
+			for i := uint8(0); i < layers; i++ {
+				usedBitrate := consumer.IncreaseLayer(availableBitrate)
+				if usedBitrate == 0 {
+					break
+				}
+				availableBitrate -= usedBitrate
+			}
+		}
+
+		// No consumer could increase its layers.
+		if availableBitrate == previousAvailableBitrate {
+			break
+		}
+
+		baseAllocation = false
+	}
+
+	for _, consumer := range consumers {
+		consumer.ApplyLayers()
+	}
+}
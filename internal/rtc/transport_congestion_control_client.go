@@ -0,0 +1,233 @@
+package rtc
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+const (
+	// TransportCcDefaultInitialBitrate is the estimate used until feedbacks
+	// are received, if no initial available outgoing bitrate is given.
+	TransportCcDefaultInitialBitrate = 600000
+	// TransportCcMinBitrate is the lowest available outgoing bitrate.
+	TransportCcMinBitrate = 30000
+	// transportCcSentPacketMaxAgeMs is how long sent packets wait for their
+	// feedback.
+	transportCcSentPacketMaxAgeMs = 5000
+	// transportCcAckedBitrateWindowMs is the window the acknowledged bitrate
+	// is computed on.
+	transportCcAckedBitrateWindowMs = 1000
+	// Fractions of lost packets below which the loss-based estimate increases
+	// and above which it decreases.
+	transportCcLowLossThreshold  = 0.02
+	transportCcHighLossThreshold = 0.1
+	// transportCcMinLossPackets is the number of reported packets the loss
+	// fraction is computed on.
+	transportCcMinLossPackets = 20
+)
+
+type TransportCongestionControlClientListener interface {
+	// OnTransportCongestionControlClientBitrate is called when the available
+	// outgoing bitrate estimate changes.
+	OnTransportCongestionControlClientBitrate(tccClient *TransportCongestionControlClient, availableBitrate uint32)
+}
+
+type tccSentPacket struct {
+	size     uint64
+	sentAtMs uint64
+}
+
+// TransportCongestionControlClient estimates the available outgoing bitrate
+// from the transport-wide congestion control feedbacks of the sent packets,
+// as done by Google congestion control: a delay-based estimate, driven by the
+// trendline overuse detector, and a loss-based one, the lowest being the
+// available bitrate.
+type TransportCongestionControlClient struct {
+	listener    TransportCongestionControlClientListener
+	mu          sync.Mutex
+	sentPackets map[uint16]tccSentPacket
+	// sentSeqs holds the sequence numbers of sentPackets in sending order so
+	// the ones never reported can be forgotten.
+	sentSeqs []uint16
+	// ackedBitrate is the rate of the packets reported as received, known
+	// once a whole window has elapsed since the first one.
+	ackedBitrate               *RateCalculator
+	firstAckedAtMs             uint64
+	delayBasedBwe              *DelayBasedBwe
+	lossBasedBitrate           float64
+	lastLossBasedUpdateMs      uint64
+	lastLossBasedDecreaseMs    uint64
+	lossPacketsReported        int
+	lossPacketsLost            int
+	availableBitrate           uint32
+	lastFeedbackPacketCount    uint8
+	feedbackPacketCountStarted bool
+	closed                     bool
+	logger                     *slog.Logger
+}
+
+func NewTransportCongestionControlClient(listener TransportCongestionControlClientListener, initialAvailableBitrate uint32) *TransportCongestionControlClient {
+	if initialAvailableBitrate == 0 {
+		initialAvailableBitrate = TransportCcDefaultInitialBitrate
+	}
+	initialAvailableBitrate = max(initialAvailableBitrate, TransportCcMinBitrate)
+
+	return &TransportCongestionControlClient{
+		listener:         listener,
+		sentPackets:      make(map[uint16]tccSentPacket),
+		ackedBitrate:     NewRateCalculator(transportCcAckedBitrateWindowMs, 8000, 100),
+		delayBasedBwe:    NewDelayBasedBwe(initialAvailableBitrate),
+		lossBasedBitrate: float64(initialAvailableBitrate),
+		availableBitrate: initialAvailableBitrate,
+		logger:           slog.Default().With("typename", "TransportCongestionControlClient"),
+	}
+}
+
+// PacketSent records a packet sent with the given transport-wide sequence
+// number, to be matched with its feedback.
+func (c *TransportCongestionControlClient) PacketSent(wideSeqNumber uint16, size, nowMs uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	// Forget the packets never reported.
+	for len(c.sentSeqs) > 0 {
+		sentPacket, ok := c.sentPackets[c.sentSeqs[0]]
+		if ok && nowMs-sentPacket.sentAtMs < transportCcSentPacketMaxAgeMs {
+			break
+		}
+		if ok {
+			delete(c.sentPackets, c.sentSeqs[0])
+		}
+		c.sentSeqs = c.sentSeqs[1:]
+	}
+
+	c.sentPackets[wideSeqNumber] = tccSentPacket{
+		size:     size,
+		sentAtMs: nowMs,
+	}
+	c.sentSeqs = append(c.sentSeqs, wideSeqNumber)
+}
+
+// ReceiveRtcpTransportFeedback updates the estimates with the reported
+// packets.
+func (c *TransportCongestionControlClient) ReceiveRtcpTransportFeedback(feedback *rtcp.TransportFeedback, nowMs uint64) {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	if c.feedbackPacketCountStarted && feedback.FeedbackPacketCount == c.lastFeedbackPacketCount {
+		c.logger.Debug("ignoring duplicated feedback", "feedbackPacketCount", feedback.FeedbackPacketCount)
+		c.mu.Unlock()
+		return
+	}
+	c.feedbackPacketCountStarted = true
+	c.lastFeedbackPacketCount = feedback.FeedbackPacketCount
+
+	arrivalTimeUs := int64(feedback.ReferenceTime) * rtcp.TccReferenceTimeTickMs * 1000
+
+	for i, status := range feedback.Packets {
+		wideSeqNumber := feedback.BaseSequenceNumber + uint16(i)
+		sentPacket, ok := c.sentPackets[wideSeqNumber]
+
+		if !status.Received {
+			if ok {
+				c.lossPacketsReported++
+				c.lossPacketsLost++
+			}
+			continue
+		}
+
+		arrivalTimeUs += int64(status.Delta) * rtcp.TccDeltaTickUs
+
+		// Unknown or already reported packet.
+		if !ok {
+			continue
+		}
+		delete(c.sentPackets, wideSeqNumber)
+
+		c.lossPacketsReported++
+		if c.firstAckedAtMs == 0 {
+			c.firstAckedAtMs = nowMs
+		}
+		c.ackedBitrate.Update(sentPacket.size, nowMs)
+		c.delayBasedBwe.IncomingPacket(float64(sentPacket.sentAtMs), float64(arrivalTimeUs)/1000)
+	}
+
+	delayBasedBitrate := c.delayBasedBwe.Update(c.getAckedBitrate(nowMs), nowMs)
+	c.updateLossBasedBitrate(nowMs)
+
+	availableBitrate := uint32(max(min(delayBasedBitrate, c.lossBasedBitrate), TransportCcMinBitrate))
+	changed := availableBitrate != c.availableBitrate
+	c.availableBitrate = availableBitrate
+
+	c.mu.Unlock()
+
+	if changed {
+		c.listener.OnTransportCongestionControlClientBitrate(c, availableBitrate)
+	}
+}
+
+// Close stops the estimation, the packets sent and the feedbacks received
+// afterwards being ignored.
+func (c *TransportCongestionControlClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+}
+
+func (c *TransportCongestionControlClient) GetAvailableBitrate() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.availableBitrate
+}
+
+// updateLossBasedBitrate increases the loss-based estimate while the loss is
+// low and decreases it in proportion to the loss when it is high. Must be
+// called with mu held.
+func (c *TransportCongestionControlClient) updateLossBasedBitrate(nowMs uint64) {
+	if c.lossPacketsReported < transportCcMinLossPackets {
+		return
+	}
+
+	lossFraction := float64(c.lossPacketsLost) / float64(c.lossPacketsReported)
+	c.lossPacketsReported = 0
+	c.lossPacketsLost = 0
+
+	switch {
+	case lossFraction < transportCcLowLossThreshold:
+		if c.lastLossBasedUpdateMs != 0 {
+			ackedBitrate := c.getAckedBitrate(nowMs)
+			c.lossBasedBitrate = increaseBitrate(c.lossBasedBitrate, ackedBitrate, nowMs-c.lastLossBasedUpdateMs)
+		}
+	case lossFraction > transportCcHighLossThreshold:
+		if nowMs-c.lastLossBasedDecreaseMs >= bweMinDecreaseIntervalMs {
+			c.lossBasedBitrate = min(c.lossBasedBitrate, c.delayBasedBwe.GetBitrate()) * (1 - 0.5*lossFraction)
+			c.lastLossBasedDecreaseMs = nowMs
+
+			c.logger.Debug("high packet loss, decreasing bitrate", "lossFraction", lossFraction,
+				"bitrate", uint32(c.lossBasedBitrate))
+		}
+	}
+
+	c.lastLossBasedUpdateMs = nowMs
+}
+
+// getAckedBitrate returns the acknowledged bitrate, or zero if not known yet.
+// Must be called with mu held.
+func (c *TransportCongestionControlClient) getAckedBitrate(nowMs uint64) float64 {
+	if c.firstAckedAtMs == 0 || nowMs-c.firstAckedAtMs < transportCcAckedBitrateWindowMs {
+		return 0
+	}
+	return float64(c.ackedBitrate.GetRate(nowMs))
+}
@@ -0,0 +1,138 @@
+package rtc
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+type TestTransportCongestionControlClientListener struct {
+	sync.Mutex
+	bitrates []uint32
+}
+
+func (l *TestTransportCongestionControlClientListener) OnTransportCongestionControlClientBitrate(tccClient *TransportCongestionControlClient, availableBitrate uint32) {
+	l.Lock()
+	defer l.Unlock()
+	l.bitrates = append(l.bitrates, availableBitrate)
+}
+
+// runTestTransportCc sends packets of 1000 bytes every 10 ms through a
+// simulated network with the given one-way delay and loss, reporting them
+// with a TransportCongestionControlServer every 100 ms.
+func runTestTransportCc(t *testing.T, tccClient *TransportCongestionControlClient, rounds int, delayMs func(seq int) uint64, lost func(seq int) bool) {
+	serverListener := &TestTransportCongestionControlServerListener{}
+	tccServer := NewTransportCongestionControlServer(serverListener)
+	defer tccServer.Close()
+
+	nowMs := uint64(10000)
+	seq := 0
+	reported := 0
+
+	for round := 0; round < rounds; round++ {
+		for i := 0; i < 10; i++ {
+			tccClient.PacketSent(uint16(seq), 1000, nowMs)
+			if !lost(seq) {
+				tccServer.IncomingPacket(nowMs+delayMs(seq), createTestTransportCcPacket(t, uint16(seq)), testTransportCcHeaderExtensionIds)
+			}
+			seq++
+			nowMs += 10
+		}
+
+		flushTestFeedbacks(tccServer)
+
+		feedbacks := serverListener.getFeedbacks()
+		for _, feedback := range feedbacks[reported:] {
+			tccClient.ReceiveRtcpTransportFeedback(feedback, nowMs)
+		}
+		reported = len(feedbacks)
+	}
+}
+
+func TestTransportCongestionControlClient(t *testing.T) {
+	noLoss := func(seq int) bool { return false }
+
+	t.Run("increases the bitrate on a stable network", func(t *testing.T) {
+		listener := &TestTransportCongestionControlClientListener{}
+		tccClient := NewTransportCongestionControlClient(listener, 500000)
+
+		runTestTransportCc(t, tccClient, 50, func(seq int) uint64 { return 50 }, noLoss)
+
+		require.Greater(t, tccClient.GetAvailableBitrate(), uint32(500000))
+		require.NotEmpty(t, listener.bitrates)
+		require.Equal(t, tccClient.GetAvailableBitrate(), listener.bitrates[len(listener.bitrates)-1])
+	})
+
+	t.Run("decreases the bitrate when the delay grows", func(t *testing.T) {
+		listener := &TestTransportCongestionControlClientListener{}
+		tccClient := NewTransportCongestionControlClient(listener, 2000000)
+
+		runTestTransportCc(t, tccClient, 50, func(seq int) uint64 { return 50 + uint64(seq) }, noLoss)
+
+		// Decreased below the acknowledged bitrate of 800 kbps.
+		require.Less(t, tccClient.GetAvailableBitrate(), uint32(800000))
+	})
+
+	t.Run("decreases the bitrate on high loss", func(t *testing.T) {
+		listener := &TestTransportCongestionControlClientListener{}
+		tccClient := NewTransportCongestionControlClient(listener, 500000)
+
+		runTestTransportCc(t, tccClient, 20, func(seq int) uint64 { return 50 }, func(seq int) bool {
+			return seq%4 == 0
+		})
+
+		require.Less(t, tccClient.GetAvailableBitrate(), uint32(500000))
+		require.GreaterOrEqual(t, tccClient.GetAvailableBitrate(), uint32(TransportCcMinBitrate))
+	})
+
+	t.Run("ignores duplicated feedbacks", func(t *testing.T) {
+		listener := &TestTransportCongestionControlClientListener{}
+		tccClient := NewTransportCongestionControlClient(listener, 0)
+		require.EqualValues(t, TransportCcDefaultInitialBitrate, tccClient.GetAvailableBitrate())
+
+		feedback := &rtcp.TransportFeedback{
+			FeedbackPacketCount: 3,
+			Packets:             []rtcp.TransportFeedbackPacket{{Received: true}},
+		}
+		tccClient.PacketSent(0, 1000, 1000)
+		tccClient.ReceiveRtcpTransportFeedback(feedback, 1100)
+		require.Empty(t, tccClient.sentPackets)
+
+		tccClient.PacketSent(0, 1000, 1200)
+		tccClient.ReceiveRtcpTransportFeedback(feedback, 1300)
+		require.Len(t, tccClient.sentPackets, 1)
+	})
+
+	t.Run("forgets packets never reported", func(t *testing.T) {
+		tccClient := NewTransportCongestionControlClient(&TestTransportCongestionControlClientListener{}, 0)
+
+		tccClient.PacketSent(1, 1000, 1000)
+		tccClient.PacketSent(2, 1000, 2000)
+		tccClient.PacketSent(3, 1000, 1000+transportCcSentPacketMaxAgeMs)
+
+		require.Len(t, tccClient.sentPackets, 2)
+		require.Equal(t, []uint16{2, 3}, tccClient.sentSeqs)
+	})
+
+	t.Run("ignores packets and feedbacks once closed", func(t *testing.T) {
+		listener := &TestTransportCongestionControlClientListener{}
+		tccClient := NewTransportCongestionControlClient(listener, 500000)
+
+		for seq := uint16(0); seq < 20; seq++ {
+			tccClient.PacketSent(seq, 1000, 1000)
+		}
+		tccClient.Close()
+
+		tccClient.PacketSent(20, 1000, 1100)
+		require.Len(t, tccClient.sentPackets, 20)
+
+		// All the packets were lost.
+		tccClient.ReceiveRtcpTransportFeedback(&rtcp.TransportFeedback{
+			Packets: make([]rtcp.TransportFeedbackPacket, 20),
+		}, 1200)
+		require.Empty(t, listener.bitrates)
+		require.EqualValues(t, 500000, tccClient.GetAvailableBitrate())
+	})
+}
@@ -0,0 +1,215 @@
+package rtc
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+)
+
+const (
+	// TransportCcFeedbackSendInterval is the interval between transport-wide
+	// congestion control feedbacks.
+	TransportCcFeedbackSendInterval = 100 * time.Millisecond
+	// TransportCcFeedbackMaxPackets is the maximum number of packet statuses
+	// in a feedback, which keeps it below the MTU. A feedback is sent as soon
+	// as this number of statuses is pending.
+	TransportCcFeedbackMaxPackets = 300
+	// transportCcMaxMissingPackets is the largest gap in the transport-wide
+	// sequence numbers reported as lost, bigger ones restart the reporting.
+	transportCcMaxMissingPackets = 1 << 12
+)
+
+type TransportCongestionControlServerListener interface {
+	// OnTransportCongestionControlServerSendRtcpPacket is called with a
+	// feedback to send, from the goroutine of IncomingPacket or from the one
+	// of the feedback timer, so it must be safe for concurrent use.
+	OnTransportCongestionControlServerSendRtcpPacket(tccServer *TransportCongestionControlServer, packet rtcp.Packet)
+}
+
+// TransportCongestionControlServer records the arrival times of the packets
+// carrying a transport-wide sequence number and periodically reports them to
+// the sender in transport-wide congestion control feedbacks, as defined in
+// draft-holmer-rmcat-transport-wide-cc-extensions-01.
+type TransportCongestionControlServer struct {
+	listener TransportCongestionControlServerListener
+	mu       sync.Mutex
+	// packetArrivalTimes maps the unwrapped transport-wide sequence numbers of
+	// the packets not reported yet to their arrival time in ms.
+	packetArrivalTimes map[int64]uint64
+	started            bool
+	// nextSeq is the first unwrapped sequence number not reported yet and
+	// maxSeq the highest received one.
+	nextSeq             int64
+	maxSeq              int64
+	mediaSsrc           uint32
+	feedbackPacketCount uint8
+	closed              bool
+	// timer is created with the first packet.
+	timer  *SafeTimer
+	logger *slog.Logger
+}
+
+func NewTransportCongestionControlServer(listener TransportCongestionControlServerListener) *TransportCongestionControlServer {
+	tccServer := &TransportCongestionControlServer{
+		listener:           listener,
+		packetArrivalTimes: make(map[int64]uint64),
+		logger:             slog.Default().With("typename", "TransportCongestionControlServer"),
+	}
+
+	return tccServer
+}
+
+// IncomingPacket records the arrival time of the packet, if it carries a
+// transport-wide sequence number. The packet is read with the given header
+// extension ids, being received before its producer sets them.
+func (s *TransportCongestionControlServer) IncomingPacket(nowMs uint64, packet *RtpPacket, headerExtensionIds RtpHeaderExtensionIds) {
+	wideSeqNumber, ok := packet.readTransportWideCc01(headerExtensionIds.TransportWideCc01)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+
+	if !s.started {
+		s.started = true
+		s.nextSeq = int64(wideSeqNumber)
+		s.maxSeq = int64(wideSeqNumber)
+		s.timer = NewSafeTimer(TransportCcFeedbackSendInterval, s.onTimer)
+	}
+
+	// Unwrap the sequence number relatively to the highest received one.
+	seq := s.maxSeq + int64(int16(wideSeqNumber-uint16(s.maxSeq)))
+
+	switch {
+	// Already reported, as lost or as a duplicate.
+	case seq < s.nextSeq:
+		s.mu.Unlock()
+		return
+	// Too far ahead, report from it on.
+	case seq-s.nextSeq > transportCcMaxMissingPackets:
+		s.logger.Debug("too many missing packets, restarting feedback", "wideSeqNumber", wideSeqNumber)
+		clear(s.packetArrivalTimes)
+		s.nextSeq = seq
+		s.maxSeq = seq
+	case seq > s.maxSeq:
+		s.maxSeq = seq
+	}
+
+	s.packetArrivalTimes[seq] = nowMs
+	s.mediaSsrc = packet.GetSsrc()
+
+	var feedbacks []*rtcp.TransportFeedback
+	if s.maxSeq-s.nextSeq+1 >= TransportCcFeedbackMaxPackets {
+		feedbacks = s.createFeedbacks()
+	}
+
+	s.mu.Unlock()
+
+	s.sendFeedbacks(feedbacks)
+}
+
+func (s *TransportCongestionControlServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+func (s *TransportCongestionControlServer) onTimer() {
+	s.mu.Lock()
+
+	// Closed while the timer was firing.
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+
+	feedbacks := s.createFeedbacks()
+	s.timer.Reset(TransportCcFeedbackSendInterval)
+
+	s.mu.Unlock()
+
+	s.sendFeedbacks(feedbacks)
+}
+
+func (s *TransportCongestionControlServer) sendFeedbacks(feedbacks []*rtcp.TransportFeedback) {
+	for _, feedback := range feedbacks {
+		s.listener.OnTransportCongestionControlServerSendRtcpPacket(s, feedback)
+	}
+}
+
+// createFeedbacks reports all the pending packets, received or not. Must be
+// called with mu held.
+func (s *TransportCongestionControlServer) createFeedbacks() []*rtcp.TransportFeedback {
+	var feedbacks []*rtcp.TransportFeedback
+
+	for s.started && s.nextSeq <= s.maxSeq {
+		feedbacks = append(feedbacks, s.createFeedback())
+	}
+
+	return feedbacks
+}
+
+// createFeedback reports the pending packets fitting in a feedback. Must be
+// called with mu held.
+func (s *TransportCongestionControlServer) createFeedback() *rtcp.TransportFeedback {
+	// The reference time is the one of the first received packet, the highest
+	// one always being so.
+	seq := s.nextSeq
+	for ; seq < s.maxSeq; seq++ {
+		if _, ok := s.packetArrivalTimes[seq]; ok {
+			break
+		}
+	}
+	referenceTime := s.packetArrivalTimes[seq] / rtcp.TccReferenceTimeTickMs
+
+	feedback := &rtcp.TransportFeedback{
+		MediaSsrc:           s.mediaSsrc,
+		BaseSequenceNumber:  uint16(s.nextSeq),
+		ReferenceTime:       int32(referenceTime & 0xFFFFFF),
+		FeedbackPacketCount: s.feedbackPacketCount,
+	}
+
+	// Receive deltas are relative to the previous received packet, the first
+	// one to the reference time.
+	lastArrivalTimeUs := int64(referenceTime) * rtcp.TccReferenceTimeTickMs * 1000
+
+	for ; s.nextSeq <= s.maxSeq && len(feedback.Packets) < TransportCcFeedbackMaxPackets; s.nextSeq++ {
+		arrivalTimeMs, ok := s.packetArrivalTimes[s.nextSeq]
+		if !ok {
+			feedback.Packets = append(feedback.Packets, rtcp.TransportFeedbackPacket{})
+			continue
+		}
+
+		arrivalTimeUs := int64(arrivalTimeMs) * 1000
+		delta := (arrivalTimeUs - lastArrivalTimeUs) / rtcp.TccDeltaTickUs
+
+		// The delta does not fit, report the packet in the next feedback.
+		if delta < -1<<15 || delta >= 1<<15 {
+			break
+		}
+
+		feedback.Packets = append(feedback.Packets, rtcp.TransportFeedbackPacket{
+			Received: true,
+			Delta:    int16(delta),
+		})
+		lastArrivalTimeUs = arrivalTimeUs
+
+		delete(s.packetArrivalTimes, s.nextSeq)
+	}
+
+	s.feedbackPacketCount++
+
+	return feedback
+}
@@ -0,0 +1,157 @@
+package rtc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+type TestTransportCongestionControlServerListener struct {
+	sync.Mutex
+	feedbacks []*rtcp.TransportFeedback
+}
+
+func (l *TestTransportCongestionControlServerListener) OnTransportCongestionControlServerSendRtcpPacket(tccServer *TransportCongestionControlServer, packet rtcp.Packet) {
+	l.Lock()
+	defer l.Unlock()
+	l.feedbacks = append(l.feedbacks, packet.(*rtcp.TransportFeedback))
+}
+
+func (l *TestTransportCongestionControlServerListener) getFeedbacks() []*rtcp.TransportFeedback {
+	l.Lock()
+	defer l.Unlock()
+	return l.feedbacks
+}
+
+// testTransportCcHeaderExtensionIds are the ids the packets created by
+// createTestTransportCcPacket are read with.
+var testTransportCcHeaderExtensionIds = RtpHeaderExtensionIds{TransportWideCc01: 5}
+
+func createTestTransportCcPacket(t *testing.T, wideSeqNumber uint16) *RtpPacket {
+	return createTestRtpPacket(t, 1111, wideSeqNumber, 100, map[uint8][]byte{
+		5: {byte(wideSeqNumber >> 8), byte(wideSeqNumber)},
+	})
+}
+
+// flushTestFeedbacks sends the pending feedbacks without waiting for the
+// timer.
+func flushTestFeedbacks(tccServer *TransportCongestionControlServer) {
+	tccServer.mu.Lock()
+	feedbacks := tccServer.createFeedbacks()
+	tccServer.mu.Unlock()
+	tccServer.sendFeedbacks(feedbacks)
+}
+
+func TestTransportCongestionControlServer(t *testing.T) {
+	t.Run("reports received and lost packets", func(t *testing.T) {
+		listener := &TestTransportCongestionControlServerListener{}
+		tccServer := NewTransportCongestionControlServer(listener)
+		defer tccServer.Close()
+
+		tccServer.IncomingPacket(1000, createTestTransportCcPacket(t, 65535), testTransportCcHeaderExtensionIds)
+		tccServer.IncomingPacket(1010, createTestTransportCcPacket(t, 0), testTransportCcHeaderExtensionIds)
+		tccServer.IncomingPacket(1030, createTestTransportCcPacket(t, 2), testTransportCcHeaderExtensionIds)
+		// Reordered packet.
+		tccServer.IncomingPacket(1020, createTestTransportCcPacket(t, 3), testTransportCcHeaderExtensionIds)
+		// Packets without transport-wide sequence number are ignored.
+		tccServer.IncomingPacket(1030, createTestRtpPacket(t, 1111, 4, 100, nil), testTransportCcHeaderExtensionIds)
+
+		flushTestFeedbacks(tccServer)
+
+		feedbacks := listener.getFeedbacks()
+		require.Len(t, feedbacks, 1)
+		require.EqualValues(t, 1111, feedbacks[0].MediaSsrc)
+		require.EqualValues(t, 65535, feedbacks[0].BaseSequenceNumber)
+		require.EqualValues(t, 0, feedbacks[0].FeedbackPacketCount)
+		// 1000 ms in 64 ms units.
+		require.EqualValues(t, 15, feedbacks[0].ReferenceTime)
+		require.Equal(t, []rtcp.TransportFeedbackPacket{
+			{Received: true, Delta: (1000 - 960) * 4},
+			{Received: true, Delta: 10 * 4},
+			{},
+			{Received: true, Delta: 20 * 4},
+			{Received: true, Delta: -10 * 4},
+		}, feedbacks[0].Packets)
+
+		// Already reported packets are ignored.
+		tccServer.IncomingPacket(1040, createTestTransportCcPacket(t, 1), testTransportCcHeaderExtensionIds)
+		tccServer.IncomingPacket(1050, createTestTransportCcPacket(t, 4), testTransportCcHeaderExtensionIds)
+		flushTestFeedbacks(tccServer)
+
+		feedbacks = listener.getFeedbacks()
+		require.Len(t, feedbacks, 2)
+		require.EqualValues(t, 4, feedbacks[1].BaseSequenceNumber)
+		require.EqualValues(t, 1, feedbacks[1].FeedbackPacketCount)
+		require.Len(t, feedbacks[1].Packets, 1)
+
+		// The feedback must serialize.
+		_, err := feedbacks[1].Marshal()
+		require.NoError(t, err)
+	})
+
+	t.Run("sends a feedback once the maximum number of packets is pending", func(t *testing.T) {
+		listener := &TestTransportCongestionControlServerListener{}
+		tccServer := NewTransportCongestionControlServer(listener)
+		defer tccServer.Close()
+
+		for seq := uint16(0); seq < TransportCcFeedbackMaxPackets; seq++ {
+			tccServer.IncomingPacket(1000+uint64(seq), createTestTransportCcPacket(t, seq), testTransportCcHeaderExtensionIds)
+		}
+
+		feedbacks := listener.getFeedbacks()
+		require.Len(t, feedbacks, 1)
+		require.Len(t, feedbacks[0].Packets, TransportCcFeedbackMaxPackets)
+	})
+
+	t.Run("splits deltas not fitting in a feedback", func(t *testing.T) {
+		listener := &TestTransportCongestionControlServerListener{}
+		tccServer := NewTransportCongestionControlServer(listener)
+		defer tccServer.Close()
+
+		tccServer.IncomingPacket(1000, createTestTransportCcPacket(t, 1), testTransportCcHeaderExtensionIds)
+		tccServer.IncomingPacket(11000, createTestTransportCcPacket(t, 2), testTransportCcHeaderExtensionIds)
+		flushTestFeedbacks(tccServer)
+
+		feedbacks := listener.getFeedbacks()
+		require.Len(t, feedbacks, 2)
+		require.EqualValues(t, 1, feedbacks[0].BaseSequenceNumber)
+		require.Len(t, feedbacks[0].Packets, 1)
+		require.EqualValues(t, 2, feedbacks[1].BaseSequenceNumber)
+		require.EqualValues(t, 11000/64, feedbacks[1].ReferenceTime)
+	})
+
+	t.Run("sends feedbacks periodically", func(t *testing.T) {
+		listener := &TestTransportCongestionControlServerListener{}
+		tccServer := NewTransportCongestionControlServer(listener)
+		defer tccServer.Close()
+
+		tccServer.IncomingPacket(1000, createTestTransportCcPacket(t, 1), testTransportCcHeaderExtensionIds)
+		require.Eventually(t, func() bool {
+			return len(listener.getFeedbacks()) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		tccServer.IncomingPacket(1010, createTestTransportCcPacket(t, 2), testTransportCcHeaderExtensionIds)
+		require.Eventually(t, func() bool {
+			return len(listener.getFeedbacks()) == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("stops sending feedbacks once closed", func(t *testing.T) {
+		listener := &TestTransportCongestionControlServerListener{}
+		tccServer := NewTransportCongestionControlServer(listener)
+
+		tccServer.IncomingPacket(1000, createTestTransportCcPacket(t, 1), testTransportCcHeaderExtensionIds)
+		tccServer.Close()
+
+		// A timer callback in flight while closing does not re-arm the timer.
+		tccServer.onTimer()
+		require.False(t, tccServer.timer.IsActive())
+
+		tccServer.IncomingPacket(1010, createTestTransportCcPacket(t, 2), testTransportCcHeaderExtensionIds)
+		time.Sleep(2 * TransportCcFeedbackSendInterval)
+		require.Empty(t, listener.getFeedbacks())
+	})
+}
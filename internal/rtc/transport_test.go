@@ -0,0 +1,373 @@
+package rtc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup/internal/rtc/rtcp"
+
+	"github.com/stretchr/testify/require"
+)
+
+type TestTransportListener struct {
+	sync.Mutex
+	sentPackets     []*RtpPacket
+	sentRtcpPackets []rtcp.Packet
+}
+
+func (l *TestTransportListener) OnTransportProducerClosed(transport *Transport, producer *Producer) {}
+
+func (l *TestTransportListener) OnTransportConsumerClosed(transport *Transport, consumer Consumer) {}
+
+func (l *TestTransportListener) OnTransportSendRtpPacket(transport *Transport, consumer Consumer, packet *RtpPacket) {
+	l.sentPackets = append(l.sentPackets, packet.Clone())
+}
+
+func (l *TestTransportListener) OnTransportSendRtcpPacket(transport *Transport, packet rtcp.Packet) {
+	l.Lock()
+	defer l.Unlock()
+	l.sentRtcpPackets = append(l.sentRtcpPackets, packet)
+}
+
+func (l *TestTransportListener) getSentRtcpPackets() []rtcp.Packet {
+	l.Lock()
+	defer l.Unlock()
+	return l.sentRtcpPackets
+}
+
+func TestTransport(t *testing.T) {
+	t.Run("stamps abs-send-time and transport-wide sequence numbers", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{})
+
+		ids := RtpHeaderExtensionIds{AbsSendTime: 2, TransportWideCc01: 3}
+		for _, seq := range []uint16{1000, 1001} {
+			packet := createTestRtpPacket(t, 5555, seq, 100, nil)
+			packet.MapHeaderExtensions(ids, "")
+			transport.OnConsumerSendRtpPacket(nil, packet)
+		}
+
+		// Packets without the extensions are sent as they are.
+		transport.OnConsumerRetransmitRtpPacket(nil, createTestRtpPacket(t, 5555, 1002, 100, nil))
+
+		require.Len(t, listener.sentPackets, 3)
+		for i, packet := range listener.sentPackets[:2] {
+			wideSeqNumber, ok := packet.ReadTransportWideCc01()
+			require.True(t, ok)
+			require.EqualValues(t, i+1, wideSeqNumber)
+
+			absSendTime, ok := packet.ReadAbsSendTime()
+			require.True(t, ok)
+			require.NotZero(t, absSendTime)
+		}
+		require.False(t, listener.sentPackets[2].Extension)
+	})
+
+	t.Run("sends transport-wide congestion control feedbacks", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{})
+		defer transport.Close()
+
+		options := createTestVideoProducerOptions()
+		options.RtpParameters.HeaderExtensions = append(options.RtpParameters.HeaderExtensions,
+			RtpHeaderExtensionParameters{Uri: TransportWideCc01HeaderExtensionUri, Id: 5})
+		producer := NewProducer("p1", NewTestProducerListener(), options)
+
+		for _, seq := range []uint16{1, 2, 4} {
+			result := transport.ReceiveRtpPacket(producer, createTestRtpPacket(t, 1111, seq, 101, map[uint8][]byte{
+				5: {0x00, byte(seq)},
+			}))
+			require.Equal(t, ReceiveRtpPacketResultMedia, result)
+		}
+
+		require.Eventually(t, func() bool {
+			return len(listener.getSentRtcpPackets()) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		feedback := listener.getSentRtcpPackets()[0].(*rtcp.TransportFeedback)
+		require.EqualValues(t, 1, feedback.BaseSequenceNumber)
+		require.Len(t, feedback.Packets, 4)
+		require.False(t, feedback.Packets[2].Received)
+	})
+
+	t.Run("receives and sends packets concurrently", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{})
+		defer transport.Close()
+
+		options := createTestVideoProducerOptions()
+		options.RtpParameters.HeaderExtensions = append(options.RtpParameters.HeaderExtensions,
+			RtpHeaderExtensionParameters{Uri: TransportWideCc01HeaderExtensionUri, Id: 5})
+		producer := NewProducer("p1", NewTestProducerListener(), options)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ids := RtpHeaderExtensionIds{TransportWideCc01: 3}
+			for seq := uint16(0); seq < 100; seq++ {
+				packet := createTestRtpPacket(t, 5555, seq, 100, nil)
+				packet.MapHeaderExtensions(ids, "")
+				transport.OnConsumerSendRtpPacket(nil, packet)
+			}
+		}()
+
+		for seq := uint16(0); seq < 100; seq++ {
+			transport.ReceiveRtpPacket(producer, createTestRtpPacket(t, 1111, seq, 101, map[uint8][]byte{
+				5: {byte(seq >> 8), byte(seq)},
+			}))
+			transport.ReceiveRtcpPacket(&rtcp.TransportFeedback{
+				BaseSequenceNumber:  1,
+				FeedbackPacketCount: uint8(seq),
+				Packets:             make([]rtcp.TransportFeedbackPacket, 20),
+			})
+			transport.GetAvailableOutgoingBitrate()
+		}
+
+		wg.Wait()
+		require.Len(t, listener.sentPackets, 100)
+	})
+
+	t.Run("updates the available outgoing bitrate with feedbacks", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{InitialAvailableOutgoingBitrate: 500000})
+		require.EqualValues(t, 500000, transport.GetAvailableOutgoingBitrate())
+
+		ids := RtpHeaderExtensionIds{TransportWideCc01: 3}
+		for seq := uint16(0); seq < 20; seq++ {
+			packet := createTestRtpPacket(t, 5555, seq, 100, nil)
+			packet.MapHeaderExtensions(ids, "")
+			transport.OnConsumerSendRtpPacket(nil, packet)
+		}
+
+		// All the packets were lost.
+		transport.ReceiveRtcpPacket(&rtcp.TransportFeedback{
+			BaseSequenceNumber: 1,
+			Packets:            make([]rtcp.TransportFeedbackPacket, 20),
+		})
+		require.EqualValues(t, 250000, transport.GetAvailableOutgoingBitrate())
+	})
+
+	t.Run("sends smoothed REMB estimates without transport-wide congestion control", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{})
+		defer transport.Close()
+		require.Zero(t, transport.GetAvailableIncomingBitrate())
+
+		options := createTestVideoProducerOptions()
+		options.RtpParameters.HeaderExtensions = append(options.RtpParameters.HeaderExtensions,
+			RtpHeaderExtensionParameters{Uri: AbsSendTimeHeaderExtensionUri, Id: 3})
+		producer := NewProducer("p1", NewTestProducerListener(), options)
+
+		result := transport.ReceiveRtpPacket(producer, createTestAbsSendTimePacket(t, 1111, 1, 10000))
+		require.Equal(t, ReceiveRtpPacketResultMedia, result)
+		require.NotNil(t, transport.rembServer)
+		require.Nil(t, transport.tccServer)
+
+		transport.OnRemoteBitrateEstimatorValue(transport.rembServer, []uint32{1111}, 900000)
+		// Decreases are smoothed.
+		transport.OnRemoteBitrateEstimatorValue(transport.rembServer, []uint32{1111}, 500000)
+
+		packets := listener.getSentRtcpPackets()
+		require.Len(t, packets, 2)
+		for _, packet := range packets {
+			remb := packet.(*rtcp.Remb)
+			require.Equal(t, []uint32{1111}, remb.Ssrcs)
+			// The smoothed value decays by 5% per second.
+			require.InDelta(t, 900000, remb.Bitrate, 10000)
+		}
+		require.InDelta(t, 900000, transport.GetAvailableIncomingBitrate(), 10000)
+	})
+
+	t.Run("stops the congestion control estimators once closed", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{})
+
+		tccOptions := createTestVideoProducerOptions()
+		tccOptions.RtpParameters.HeaderExtensions = append(tccOptions.RtpParameters.HeaderExtensions,
+			RtpHeaderExtensionParameters{Uri: TransportWideCc01HeaderExtensionUri, Id: 5})
+		transport.ReceiveRtpPacket(NewProducer("p1", NewTestProducerListener(), tccOptions),
+			createTestRtpPacket(t, 1111, 1, 101, map[uint8][]byte{5: {0x00, 0x01}}))
+
+		rembOptions := createTestVideoProducerOptions()
+		rembOptions.RtpParameters.HeaderExtensions = append(rembOptions.RtpParameters.HeaderExtensions,
+			RtpHeaderExtensionParameters{Uri: AbsSendTimeHeaderExtensionUri, Id: 3})
+		transport.ReceiveRtpPacket(NewProducer("p2", NewTestProducerListener(), rembOptions),
+			createTestAbsSendTimePacket(t, 2222, 1, 10000))
+
+		packet := createTestRtpPacket(t, 5555, 1, 100, nil)
+		packet.MapHeaderExtensions(RtpHeaderExtensionIds{TransportWideCc01: 3}, "")
+		transport.OnConsumerSendRtpPacket(nil, packet)
+
+		transport.Close()
+
+		require.True(t, transport.tccServer.closed)
+		require.False(t, transport.tccServer.timer.IsActive())
+		require.True(t, transport.tccClient.closed)
+		require.True(t, transport.rembServer.closed)
+	})
+
+	t.Run("distributes the available outgoing bitrate across consumers by priority", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{})
+
+		streams := createTestSimulcastProducerRtpStreams(t)
+		var consumers []*SimulcastConsumer
+		for _, id := range []string{"c1", "c2"} {
+			options := createTestSimulcastConsumerOptions()
+			options.RtpParameters.HeaderExtensions = []RtpHeaderExtensionParameters{
+				{Uri: TransportWideCc01HeaderExtensionUri, Id: 5},
+			}
+			consumer := NewSimulcastConsumer(id, &TestConsumerListener{}, options)
+			transport.AddConsumer(consumer)
+			for idx, stream := range streams {
+				consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+			}
+			consumers = append(consumers, consumer)
+		}
+		consumers[1].SetPriority(2)
+
+		// Consumers not negotiating transport-wide sequence numbers manage
+		// their own layers.
+		consumer := NewSimulcastConsumer("c3", &TestConsumerListener{}, createTestSimulcastConsumerOptions())
+		transport.AddConsumer(consumer)
+		for idx, stream := range streams {
+			consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+		}
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}, consumer.GetTargetLayers())
+
+		// The lowest layer is the only one active long enough.
+		layerBitrate := streams[0].GetLayerBitrate(uint64(time.Now().UnixMilli()), 0, 0)
+		require.NotZero(t, layerBitrate)
+
+		// Only enough for the highest priority consumer.
+		transport.OnTransportCongestionControlClientBitrate(transport.tccClient, layerBitrate)
+		require.Nil(t, consumers[0].GetTargetLayers())
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, consumers[1].GetTargetLayers())
+
+		transport.OnTransportCongestionControlClientBitrate(transport.tccClient, 2*layerBitrate)
+		for _, consumer := range consumers {
+			require.Equal(t, &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, consumer.GetTargetLayers())
+		}
+
+		// The bitrate of a paused consumer goes to the other ones.
+		transport.OnTransportCongestionControlClientBitrate(transport.tccClient, layerBitrate)
+		require.Nil(t, consumers[0].GetTargetLayers())
+		consumers[1].Pause()
+		transport.OnConsumerNeedZeroBitrate(consumers[1])
+		require.Equal(t, &ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, consumers[0].GetTargetLayers())
+
+		// Layers are lowered when the bitrate drops.
+		transport.OnTransportCongestionControlClientBitrate(transport.tccClient, layerBitrate-1)
+		require.Nil(t, consumers[0].GetTargetLayers())
+
+		transport.RemoveConsumer(consumers[0])
+		require.NotContains(t, transport.bitrateManagedConsumers, "c1")
+	})
+
+	t.Run("probes for the bitrate desired by the consumers", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{
+			InitialAvailableOutgoingBitrate: 100000,
+			ProbationSsrc:                   1234,
+			ProbationPayloadType:            127,
+		})
+
+		options := createTestSimulcastConsumerOptions()
+		options.RtpParameters.HeaderExtensions = []RtpHeaderExtensionParameters{
+			{Uri: TransportWideCc01HeaderExtensionUri, Id: 5},
+		}
+		options.RtpParameters.Encodings[0].MaxBitrate = 1000000
+		consumer := NewSimulcastConsumer("c1", &TestConsumerListener{}, options)
+		transport.AddConsumer(consumer)
+		for idx, stream := range createTestSimulcastProducerRtpStreams(t) {
+			consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+		}
+		transport.OnConsumerNeedBitrateChange(consumer)
+
+		// Up to one and a half times the available bitrate.
+		require.EqualValues(t, 150000, transport.probationTargetBitrate)
+
+		packet := createTestRtpPacket(t, 5555, 1, 100, nil)
+		packet.MapHeaderExtensions(NewRtpHeaderExtensionIds(options.RtpParameters.HeaderExtensions), "")
+		transport.OnConsumerSendRtpPacket(consumer, packet)
+
+		// The media packet followed by a cluster of probation packets.
+		require.Greater(t, len(listener.sentPackets), 2)
+		var probationSize uint64
+		for i, packet := range listener.sentPackets {
+			wideSeqNumber, ok := packet.ReadTransportWideCc01()
+			require.True(t, ok)
+			require.EqualValues(t, i+1, wideSeqNumber)
+			if i == 0 {
+				require.EqualValues(t, 5555, packet.SSRC)
+				continue
+			}
+			require.EqualValues(t, 1234, packet.SSRC)
+			require.EqualValues(t, 127, packet.PayloadType)
+			require.Empty(t, packet.Payload)
+			probationSize += packet.Size
+		}
+		// 150 kbps during the 200 ms counted at most, in whole packets.
+		require.InDelta(t, 150000*200/8000, probationSize, 50)
+
+		// Not before the next cluster is due.
+		sent := len(listener.sentPackets)
+		transport.OnConsumerSendRtpPacket(consumer, packet.Clone())
+		require.Len(t, listener.sentPackets, sent+1)
+
+		// Nothing to probe for when the available bitrate is enough.
+		transport.OnTransportCongestionControlClientBitrate(transport.tccClient, 2000000)
+		require.Zero(t, transport.probationTargetBitrate)
+	})
+
+	t.Run("probes with RTX padding without a probation SSRC", func(t *testing.T) {
+		listener := &TestTransportListener{}
+		transport := NewTransport("t1", listener, &TransportOptions{InitialAvailableOutgoingBitrate: 100000})
+
+		options := createTestSimulcastConsumerOptions()
+		options.RtpParameters.Codecs = append(options.RtpParameters.Codecs, &RtpCodecParameters{
+			MimeType:    "video/rtx",
+			PayloadType: 101,
+			ClockRate:   90000,
+			Parameters:  RtpCodecSpecificParameters{Apt: 100},
+		})
+		options.RtpParameters.HeaderExtensions = []RtpHeaderExtensionParameters{
+			{Uri: MidHeaderExtensionUri, Id: 1},
+			{Uri: TransportWideCc01HeaderExtensionUri, Id: 5},
+		}
+		options.RtpParameters.Mid = "0"
+		options.RtpParameters.Encodings[0].Rtx = &RtpEncodingRtx{Ssrc: 5556}
+		options.RtpParameters.Encodings[0].MaxBitrate = 1000000
+		consumer := NewSimulcastConsumer("c1", &TestConsumerListener{}, options)
+		transport.AddConsumer(consumer)
+		for idx, stream := range createTestSimulcastProducerRtpStreams(t) {
+			consumer.ProducerNewRtpStream(stream, 9001+uint32(idx))
+		}
+		transport.OnConsumerNeedBitrateChange(consumer)
+		require.Nil(t, transport.probationGenerator)
+
+		packet := createTestRtpPacket(t, 5555, 1, 100, nil)
+		packet.MapHeaderExtensions(NewRtpHeaderExtensionIds(options.RtpParameters.HeaderExtensions), "0")
+		require.True(t, consumer.rtpStream.ReceivePacket(packet))
+		transport.OnConsumerSendRtpPacket(consumer, packet)
+
+		// The media packet followed by a cluster of RTX padding packets.
+		require.Greater(t, len(listener.sentPackets), 2)
+		for i, packet := range listener.sentPackets[1:] {
+			require.EqualValues(t, 5556, packet.SSRC)
+			require.EqualValues(t, 101, packet.PayloadType)
+			require.Empty(t, packet.Payload)
+			require.Equal(t, listener.sentPackets[1].SequenceNumber+uint16(i), packet.SequenceNumber)
+
+			wideSeqNumber, ok := packet.ReadTransportWideCc01()
+			require.True(t, ok)
+			require.EqualValues(t, i+2, wideSeqNumber)
+			mid, ok := packet.ReadMid()
+			require.True(t, ok)
+			require.Equal(t, "0", mid)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package rtc
+
+import "math"
+
+type BandwidthUsage int
+
+const (
+	BandwidthUsageNormal BandwidthUsage = iota
+	BandwidthUsageUnderusing
+	BandwidthUsageOverusing
+)
+
+const (
+	trendlineWindowSize             = 20
+	trendlineSmoothingCoef          = 0.9
+	trendlineThresholdGain          = 4.0
+	trendlineMaxNumDeltas           = 60
+	trendlineOverusingTimeThreshold = 10.0
+	trendlineMaxAdaptOffsetMs       = 15.0
+	trendlineInitialThreshold       = 12.5
+	trendlineMinThreshold           = 6.0
+	trendlineMaxThreshold           = 600.0
+	trendlineThresholdUpGain        = 0.0087
+	trendlineThresholdDownGain      = 0.039
+)
+
+type trendlinePoint struct {
+	arrivalTimeMs   float64
+	smoothedDelayMs float64
+}
+
+// TrendlineEstimator detects the overuse of the network by fitting a line to
+// the accumulated one-way delay variations of the last packet groups, as done
+// by the delay-based estimator of Google congestion control. The detection
+// threshold adapts to the delay variations.
+type TrendlineEstimator struct {
+	numOfDeltas        int
+	firstArrivalTimeMs float64
+	accumulatedDelayMs float64
+	smoothedDelayMs    float64
+	points             []trendlinePoint
+	prevTrend          float64
+	threshold          float64
+	lastUpdateMs       float64
+	timeOverusingMs    float64
+	overuseCounter     int
+	state              BandwidthUsage
+}
+
+func NewTrendlineEstimator() *TrendlineEstimator {
+	return &TrendlineEstimator{
+		firstArrivalTimeMs: -1,
+		threshold:          trendlineInitialThreshold,
+		lastUpdateMs:       -1,
+		timeOverusingMs:    -1,
+	}
+}
+
+// Update feeds the receive and send time deltas between two consecutive packet
+// groups, the arrival time being the one of the last group.
+func (te *TrendlineEstimator) Update(recvDeltaMs, sendDeltaMs, arrivalTimeMs float64) {
+	if te.numOfDeltas < trendlineMaxNumDeltas {
+		te.numOfDeltas++
+	}
+
+	if te.firstArrivalTimeMs < 0 {
+		te.firstArrivalTimeMs = arrivalTimeMs
+	}
+
+	te.accumulatedDelayMs += recvDeltaMs - sendDeltaMs
+	te.smoothedDelayMs = trendlineSmoothingCoef*te.smoothedDelayMs +
+		(1-trendlineSmoothingCoef)*te.accumulatedDelayMs
+
+	te.points = append(te.points, trendlinePoint{
+		arrivalTimeMs:   arrivalTimeMs - te.firstArrivalTimeMs,
+		smoothedDelayMs: te.smoothedDelayMs,
+	})
+	if len(te.points) > trendlineWindowSize {
+		te.points = te.points[1:]
+	}
+
+	trend := te.prevTrend
+	if len(te.points) == trendlineWindowSize {
+		if slope, ok := linearFitSlope(te.points); ok {
+			trend = slope
+		}
+	}
+
+	te.detect(trend, sendDeltaMs, arrivalTimeMs)
+}
+
+func (te *TrendlineEstimator) State() BandwidthUsage {
+	return te.state
+}
+
+func (te *TrendlineEstimator) detect(trend, sendDeltaMs, nowMs float64) {
+	if te.numOfDeltas < 2 {
+		te.state = BandwidthUsageNormal
+		return
+	}
+
+	modifiedTrend := float64(te.numOfDeltas) * trend * trendlineThresholdGain
+
+	switch {
+	case modifiedTrend > te.threshold:
+		if te.timeOverusingMs < 0 {
+			// Initialize the timer, assuming we have been overusing half of
+			// the time since the previous sample.
+			te.timeOverusingMs = sendDeltaMs / 2
+		} else {
+			te.timeOverusingMs += sendDeltaMs
+		}
+		te.overuseCounter++
+		if te.timeOverusingMs > trendlineOverusingTimeThreshold && te.overuseCounter > 1 && trend >= te.prevTrend {
+			te.timeOverusingMs = 0
+			te.overuseCounter = 0
+			te.state = BandwidthUsageOverusing
+		}
+	case modifiedTrend < -te.threshold:
+		te.timeOverusingMs = -1
+		te.overuseCounter = 0
+		te.state = BandwidthUsageUnderusing
+	default:
+		te.timeOverusingMs = -1
+		te.overuseCounter = 0
+		te.state = BandwidthUsageNormal
+	}
+
+	te.prevTrend = trend
+	te.updateThreshold(modifiedTrend, nowMs)
+}
+
+func (te *TrendlineEstimator) updateThreshold(modifiedTrend, nowMs float64) {
+	if te.lastUpdateMs < 0 {
+		te.lastUpdateMs = nowMs
+	}
+
+	// Do not adapt the threshold to spikes.
+	if math.Abs(modifiedTrend) > te.threshold+trendlineMaxAdaptOffsetMs {
+		te.lastUpdateMs = nowMs
+		return
+	}
+
+	gain := trendlineThresholdUpGain
+	if math.Abs(modifiedTrend) < te.threshold {
+		gain = trendlineThresholdDownGain
+	}
+
+	timeDeltaMs := min(nowMs-te.lastUpdateMs, 100)
+	te.threshold += gain * (math.Abs(modifiedTrend) - te.threshold) * timeDeltaMs
+	te.threshold = min(max(te.threshold, trendlineMinThreshold), trendlineMaxThreshold)
+	te.lastUpdateMs = nowMs
+}
+
+// linearFitSlope returns the slope of the least squares line of the points.
+func linearFitSlope(points []trendlinePoint) (float64, bool) {
+	var sumX, sumY float64
+	for _, point := range points {
+		sumX += point.arrivalTimeMs
+		sumY += point.smoothedDelayMs
+	}
+	avgX := sumX / float64(len(points))
+	avgY := sumY / float64(len(points))
+
+	var numerator, denominator float64
+	for _, point := range points {
+		x := point.arrivalTimeMs - avgX
+		numerator += x * (point.smoothedDelayMs - avgY)
+		denominator += x * x
+	}
+	if denominator == 0 {
+		return 0, false
+	}
+
+	return numerator / denominator, true
+}
@@ -0,0 +1,46 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrendlineEstimator(t *testing.T) {
+	// update feeds groups sent every 10 ms whose one-way delay grows by the
+	// given value.
+	update := func(te *TrendlineEstimator, count int, delayGrowthMs float64) {
+		arrivalTimeMs := 1000.0
+		for i := 0; i < count; i++ {
+			arrivalTimeMs += 10 + delayGrowthMs
+			te.Update(10+delayGrowthMs, 10, arrivalTimeMs)
+		}
+	}
+
+	t.Run("stable delay is normal", func(t *testing.T) {
+		te := NewTrendlineEstimator()
+		update(te, 100, 0)
+		require.Equal(t, BandwidthUsageNormal, te.State())
+	})
+
+	t.Run("growing delay is overusing", func(t *testing.T) {
+		te := NewTrendlineEstimator()
+		update(te, 100, 2)
+		require.Equal(t, BandwidthUsageOverusing, te.State())
+	})
+
+	t.Run("decreasing delay is underusing", func(t *testing.T) {
+		te := NewTrendlineEstimator()
+		update(te, 100, -2)
+		require.Equal(t, BandwidthUsageUnderusing, te.State())
+	})
+
+	t.Run("linear fit slope", func(t *testing.T) {
+		slope, ok := linearFitSlope([]trendlinePoint{{0, 1}, {1, 3}, {2, 5}})
+		require.True(t, ok)
+		require.InDelta(t, 2, slope, 1e-9)
+
+		_, ok = linearFitSlope([]trendlinePoint{{1, 1}, {1, 3}})
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,17 @@
+package util
+
+// AbsSendTimeWrapMs is the period after which abs-send-time values wrap
+// around, 64 seconds.
+const AbsSendTimeWrapMs = 64000
+
+// TimeMsToAbsSendTime converts time in milliseconds to the 24 bits 6.18 fixed
+// point seconds of the abs-send-time header extension.
+func TimeMsToAbsSendTime(ms uint64) uint32 {
+	return uint32(((ms<<18)+500)/1000) & 0xFFFFFF
+}
+
+// AbsSendTimeToTimeMs converts an abs-send-time value to milliseconds within
+// its 64 seconds period.
+func AbsSendTimeToTimeMs(absSendTime uint32) uint64 {
+	return (uint64(absSendTime&0xFFFFFF)*1000 + 1<<17) >> 18
+}
@@ -0,0 +1,17 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbsSendTime(t *testing.T) {
+	assert.EqualValues(t, 1<<18, TimeMsToAbsSendTime(1000))
+	assert.EqualValues(t, 1<<17, TimeMsToAbsSendTime(AbsSendTimeWrapMs+500))
+	assert.EqualValues(t, 1000, AbsSendTimeToTimeMs(1<<18))
+
+	for _, ms := range []uint64{0, 1, 999, 63999} {
+		assert.Equal(t, ms, AbsSendTimeToTimeMs(TimeMsToAbsSendTime(ms)))
+	}
+}
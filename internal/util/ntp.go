@@ -0,0 +1,30 @@
+package util
+
+// UnixNtpOffsetSec is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch (1970).
+const UnixNtpOffsetSec = 2208988800
+
+// Ntp is a 64 bits NTP timestamp.
+type Ntp struct {
+	Seconds   uint32
+	Fractions uint32
+}
+
+// TimeMsToNtp converts Unix time in milliseconds to a NTP timestamp.
+func TimeMsToNtp(ms uint64) Ntp {
+	return Ntp{
+		Seconds:   uint32(ms/1000 + UnixNtpOffsetSec),
+		Fractions: uint32(ms % 1000 * (1 << 32) / 1000),
+	}
+}
+
+// NtpToTimeMs converts a NTP timestamp to Unix time in milliseconds.
+func NtpToTimeMs(ntp Ntp) uint64 {
+	return (uint64(ntp.Seconds)-UnixNtpOffsetSec)*1000 + (uint64(ntp.Fractions)*1000+1<<31)>>32
+}
+
+// Compact returns the middle 32 bits of the timestamp, as used by RTCP LSR and
+// LastRR fields.
+func (n Ntp) Compact() uint32 {
+	return n.Seconds<<16 | n.Fractions>>16
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNtp(t *testing.T) {
+	ntp := TimeMsToNtp(1500)
+	assert.EqualValues(t, UnixNtpOffsetSec+1, ntp.Seconds)
+	assert.EqualValues(t, 1<<31, ntp.Fractions)
+	assert.EqualValues(t, 1500, NtpToTimeMs(ntp))
+
+	for _, ms := range []uint64{0, 1, 999, 1700000000123} {
+		assert.Equal(t, ms, NtpToTimeMs(TimeMsToNtp(ms)))
+	}
+
+	assert.EqualValues(t, 0x56789abc, Ntp{Seconds: 0x12345678, Fractions: 0x9abcdef0}.Compact())
+}